@@ -12,7 +12,6 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	_ "embed"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,8 +20,8 @@ import (
 	"math/big"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hyperledger/aries-framework-go/pkg/crypto/tinkcrypto"
@@ -41,11 +40,8 @@ import (
 	"github.com/piprate/json-gold/ld"
 	"github.com/square/go-jose/jwt"
 
-	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
-	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofbandv2"
-	"github.com/hyperledger/aries-framework-go/pkg/client/presentproof"
 	"github.com/hyperledger/aries-framework-go/pkg/common/log"
 	cryptoapi "github.com/hyperledger/aries-framework-go/pkg/crypto"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
@@ -65,8 +61,6 @@ import (
 	"github.com/hyperledger/aries-framework-go/spi/storage"
 )
 
-var pdBytes []byte
-
 const (
 	// issuer html templates
 	issuerHTML          = "./templates/issuer/issuer.html"
@@ -115,19 +109,60 @@ type openid4ciDemo struct {
 	Pin         string
 }
 
+// openid4vcIssuance is pre-authorized OpenID4VC issuance offered to the holder.
+type openid4vcIssuance struct {
+	openid4ciDemo
+	key string
+}
+
+// oidcIssuanceParams are the settings of OIDC issuance, given in the issuer page's form or through admin API.
+type oidcIssuanceParams struct {
+	WalletInitIssuanceURL string                     `json:"wallet_init_issuance_url"`
+	IssuerURL             string                     `json:"issuer_url"`
+	CredentialTypes       []string                   `json:"credential_types"`
+	ManifestIDs           []string                   `json:"manifest_ids"`
+	CredentialManifest    json.RawMessage            `json:"credential_manifest"`
+	Credentials           map[string]json.RawMessage `json:"credentials"`
+}
+
+// openid4vcIssuanceParams are the settings of pre-authorized OpenID4VC issuance, given in the issuer page's form
+// or through admin API.
+type openid4vcIssuanceParams struct {
+	IssuerURL            string                     `json:"issuer_url"`
+	CredentialType       string                     `json:"credential_type"`
+	CredentialsSupported json.RawMessage            `json:"credentials_supported"`
+	Credentials          map[string]json.RawMessage `json:"credentials"`
+}
+
 // waciIssuanceData contains state of WACI demo.
 type waciIssuanceData struct {
 	CredentialManifest json.RawMessage `json:"credential_manifest"`
-	CredentialResponse json.RawMessage `json:"credential_response"`
 	Credential         json.RawMessage `json:"credential"`
+	CredentialFormat   string          `json:"credential_format,omitempty"`
 }
 
 type adapterApp struct {
-	agent  *didComm
-	store  storage.Store
-	kms    kmsapi.KeyManager
-	vdr    vdrapi.Registry
-	crypto cryptoapi.Crypto
+	cfg              *adapterConfig
+	agent            *didComm
+	store            storage.Store
+	kms              kmsapi.KeyManager
+	vdr              vdrapi.Registry
+	crypto           cryptoapi.Crypto
+	statusChecker    *statusListChecker
+	verifierProfiles map[string]*verifierProfile
+	issuanceMu       sync.Mutex
+	basicMessageMu   sync.Mutex
+	transcripts      *transcriptRecorder
+	faults           *faultInjector
+	replay           *replayer
+	openAPI          *openAPI
+	metrics          *adapterMetrics
+}
+
+// presentationRequest contains challenge and domain sent to holder in WACI request-presentation.
+type presentationRequest struct {
+	Challenge string `json:"challenge"`
+	Domain    string `json:"domain"`
 }
 
 type vpToken struct {
@@ -154,67 +189,115 @@ type openid4vcShareRequestPayload struct {
 	Claims       claims `json:"claims"`
 }
 
-func startAdapterApp(agent *didComm, router *mux.Router) error {
+func startAdapterApp(agent *didComm, router *mux.Router, storeProvider storage.Provider, cfg *adapterConfig) error {
 	log.SetLevel("", arieslog.DEBUG)
 
-	prov := mem.NewProvider()
+	app, err := newAdapterApp(agent, storeProvider, cfg)
+	if err != nil {
+		return err
+	}
 
-	store, err := prov.OpenStore("verifier")
+	actionCh := make(chan service.DIDCommAction)
+
+	err = agent.DIDExchClient.RegisterActionEvent(actionCh)
 	if err != nil {
-		return fmt.Errorf("failed to create store : %w", err)
+		return fmt.Errorf("failed to register action events on didexchange-client : %w", err)
 	}
 
-	kmsProv, err := mockkms.NewProviderForKMS(prov, &noop.NoLock{})
+	err = agent.PresentProofClient.RegisterActionEvent(actionCh)
 	if err != nil {
-		return fmt.Errorf("failed to create kms provider : %w", err)
+		return fmt.Errorf("failed to register action events on present-proof-client : %w", err)
 	}
 
-	keyManager, err := localkms.New("local-lock://test/master/key/", kmsProv)
+	err = agent.IssueCredentialClient.RegisterActionEvent(actionCh)
 	if err != nil {
-		return fmt.Errorf("failed to create key manager : %w", err)
+		return fmt.Errorf("failed to register action events on issue-credential-client : %w", err)
 	}
 
-	edPriv := ed25519.PrivateKey(base58.Decode(pkBase58))
-	if len(edPriv) == 0 {
-		return fmt.Errorf("error converting bad public key")
+	err = agent.MediatorClient.RegisterActionEvent(actionCh)
+	if err != nil {
+		return fmt.Errorf("failed to register action events on mediator-client : %w", err)
 	}
 
-	_, _, err = keyManager.ImportPrivateKey(edPriv, kmsapi.ED25519Type, kmsapi.WithKeyID("dfiDkP85Xq5Cald-Q7nT4515MNAcguRJzJpD4CsepAg"))
+	go app.listenForDIDCommMsg(actionCh)
 
-	crypto, err := tinkcrypto.New()
+	stateCh := make(chan service.StateMsg, issuanceStateEventBufferSize)
+
+	err = agent.IssueCredentialClient.RegisterMsgEvent(stateCh)
 	if err != nil {
-		return fmt.Errorf("failed to create crypto : %w", err)
+		return fmt.Errorf("failed to register message events on issue-credential-client : %w", err)
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	go app.listenForIssuanceStates(stateCh)
 
-	vdr := vdrpkg.New(vdrpkg.WithVDR(key.New()), vdrpkg.WithVDR(&webVDR{
-		http: &http.Client{Transport: tr},
-		VDR:  web.New(),
-	}))
+	protocolStateCh := make(chan service.StateMsg, protocolEventBufferLen)
 
-	app := adapterApp{agent: agent, store: store, kms: keyManager, crypto: crypto, vdr: vdr}
+	for name, client := range map[string]service.Event{
+		"didexchange-client":      agent.DIDExchClient,
+		"present-proof-client":    agent.PresentProofClient,
+		"issue-credential-client": agent.IssueCredentialClient,
+	} {
+		err = client.RegisterMsgEvent(protocolStateCh)
+		if err != nil {
+			return fmt.Errorf("failed to register message events on %s : %w", name, err)
+		}
+	}
 
-	actionCh := make(chan service.DIDCommAction)
+	go agent.Inspector.listenForProtocolStates(protocolStateCh)
 
-	err = agent.DIDExchClient.RegisterActionEvent(actionCh)
-	if err != nil {
-		return fmt.Errorf("failed to register action events on didexchange-client : %w", err)
-	}
+	agent.Inspector.observe(app.transcripts.recordMessage)
+	agent.Messenger.setFaultInjector(app.faults)
+	router.Use(app.metrics.middleware, app.transcripts.middleware, app.faults.middleware, app.openAPI.middleware)
 
-	err = agent.PresentProofClient.RegisterActionEvent(actionCh)
-	if err != nil {
-		return fmt.Errorf("failed to register action events on present-proof-client : %w", err)
+	if app.replay != nil {
+		router.Use(app.replay.middleware)
 	}
 
-	err = agent.IssueCredentialClient.RegisterActionEvent(actionCh)
+	err = agent.MessageRegistrar.Register(
+		&didCommMessageService{
+			name:     "trust-ping",
+			msgTypes: []string{trustPingMsgType, trustPingMsgTypeV2},
+			handle:   app.handleTrustPing,
+		},
+		&didCommMessageService{
+			name:     "basic-message",
+			msgTypes: []string{basicMessageMsgType, basicMessageMsgTypeV2},
+			handle:   app.handleBasicMessage,
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to register action events on issue-credential-client : %w", err)
-	}
-
-	go listenForDIDCommMsg(actionCh, store)
+		return fmt.Errorf("failed to register message services : %w", err)
+	}
+
+	if agent.PublicDIDDocV2 != nil {
+		router.HandleFunc(didWebDocPath, servePublicDIDDoc(agent.PublicDIDDocV2)).Methods(http.MethodGet)
+	}
+
+	router.HandleFunc(healthzPath, healthz).Methods(http.MethodGet)
+	router.HandleFunc(readyzPath, readyz(readinessChecks(agent, cfg))).Methods(http.MethodGet)
+	router.HandleFunc("/mediator/invitation", app.mediatorInvitation).Methods(http.MethodGet)
+	router.HandleFunc(oobInvitationPath+"{id}", app.oobInvitation).Methods(http.MethodGet)
+	router.Handle(metricsPath, app.metrics.handler()).Methods(http.MethodGet)
+	router.HandleFunc(openAPIPath, app.openAPI.serveDocument).Methods(http.MethodGet)
+
+	// agent inspection routes
+	router.HandleFunc("/admin/connections", app.listConnections).Methods(http.MethodGet)
+	router.HandleFunc("/admin/protocols", app.listProtocolInstances).Methods(http.MethodGet)
+	router.HandleFunc("/admin/actions", app.listPendingActions).Methods(http.MethodGet)
+	router.HandleFunc("/admin/messages", app.listMessages).Methods(http.MethodGet)
+	router.HandleFunc("/admin/connections/{id}/basic-messages", app.sendBasicMessage).Methods(http.MethodPost)
+	router.HandleFunc("/admin/connections/{id}/basic-messages", app.listBasicMessages).Methods(http.MethodGet)
+	router.HandleFunc("/admin/sessions/{id}/transcript", app.sessionTranscript).Methods(http.MethodGet)
+	router.HandleFunc(waciIssuanceSessionPath, app.createWACIIssuanceSession).Methods(http.MethodPost)
+	router.HandleFunc(waciShareSessionPath, app.createWACIShareSession).Methods(http.MethodPost)
+	router.HandleFunc(oidcIssuanceSessionPath, app.createOIDCIssuanceSession).Methods(http.MethodPost)
+	router.HandleFunc(oidcShareSessionPath, app.createOIDCShareSession).Methods(http.MethodPost)
+	router.HandleFunc(openid4vcIssuanceSessionPath, app.createOpenID4VCIssuanceSession).Methods(http.MethodPost)
+	router.HandleFunc(openid4vcShareSessionPath, app.createOpenID4VCShareSession).Methods(http.MethodPost)
+	router.HandleFunc("/admin/faults", app.listFaultRules).Methods(http.MethodGet)
+	router.HandleFunc("/admin/faults", app.addFaultRule).Methods(http.MethodPost)
+	router.HandleFunc("/admin/faults", app.clearFaultRules).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/faults/{id}", app.removeFaultRule).Methods(http.MethodDelete)
 
 	// issuer routes
 	router.HandleFunc("/issuer", app.issuer)
@@ -222,6 +305,7 @@ func startAdapterApp(agent *didComm, router *mux.Router) error {
 	router.HandleFunc("/issuer/waci-issuance", app.waciIssuance)
 	router.HandleFunc("/issuer/waci-issuance-v2", app.waciIssuanceV2)
 	router.HandleFunc("/issuer/waci-issuance/{id}", app.waciIssuanceCallback)
+	router.HandleFunc("/issuer/waci-issuance/{id}/state", app.waciIssuanceState).Methods(http.MethodGet)
 	router.HandleFunc("/issuer/oidc", app.oidcIssuer)
 	router.HandleFunc("/issuer/oidc/login", app.oidcIssuerLogin)
 	router.HandleFunc("/issuer/oidc/issuance", app.initiateIssuance).Methods(http.MethodPost)
@@ -254,6 +338,89 @@ func startAdapterApp(agent *didComm, router *mux.Router) error {
 	return nil
 }
 
+// newAdapterApp creates adapter app state backed by given storage provider.
+func newAdapterApp(agent *didComm, prov storage.Provider, cfg *adapterConfig) (*adapterApp, error) {
+	store, err := prov.OpenStore("verifier")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store : %w", err)
+	}
+
+	// the agent keeps its keys in the "kms" store of the same provider.
+	kmsProv, err := mockkms.NewProviderForKMS(newPrefixedStoreProvider(prov, adapterStorePrefix), &noop.NoLock{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kms provider : %w", err)
+	}
+
+	keyManager, err := localkms.New("local-lock://test/master/key/", kmsProv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key manager : %w", err)
+	}
+
+	edPriv := ed25519.PrivateKey(base58.Decode(pkBase58))
+	if len(edPriv) == 0 {
+		return nil, fmt.Errorf("error converting bad public key")
+	}
+
+	_, _, err = keyManager.ImportPrivateKey(edPriv, kmsapi.ED25519Type, kmsapi.WithKeyID("dfiDkP85Xq5Cald-Q7nT4515MNAcguRJzJpD4CsepAg"))
+
+	crypto, err := tinkcrypto.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create crypto : %w", err)
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	vdr := vdrpkg.New(vdrpkg.WithVDR(key.New()), vdrpkg.WithVDR(&webVDR{
+		http: &http.Client{Transport: tr},
+		VDR:  web.New(),
+	}))
+
+	statusChecker, err := buildStatusListChecker(cfg, vdr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create status list checker : %w", err)
+	}
+
+	verifierProfiles, err := loadVerifierProfiles(vdr, cfg.VerifierProfilesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load verifier profiles : %w", err)
+	}
+
+	transcripts := newTranscriptRecorder()
+
+	if cfg.RecordDir != "" {
+		recordings, e := newRecordingStore(cfg.RecordDir)
+		if e != nil {
+			return nil, e
+		}
+
+		transcripts.recordTo(recordings)
+
+		logger.Infof("recording sessions in %s", cfg.RecordDir)
+	}
+
+	var replay *replayer
+
+	if cfg.ReplayFile != "" {
+		replay, err = newReplayer(cfg.ReplayFile)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Infof("replaying responses of session %s", replay.sessionID)
+	}
+
+	api, err := newOpenAPI(cfg.ExternalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &adapterApp{cfg: cfg, agent: agent, store: store, kms: keyManager, crypto: crypto, vdr: vdr,
+		statusChecker: statusChecker, verifierProfiles: verifierProfiles, transcripts: transcripts,
+		faults: newFaultInjector(transcripts), replay: replay, openAPI: api, metrics: newAdapterMetrics()}, nil
+}
+
 // issuer html template endpoints
 func (v *adapterApp) issuer(w http.ResponseWriter, r *http.Request) {
 	loadTemplate(w, issuerHTML, nil)
@@ -299,67 +466,189 @@ func (v *adapterApp) webWallet(w http.ResponseWriter, r *http.Request) {
 
 // data endpoints
 func (v *adapterApp) waciShare(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+	v.waciShareWithVersion(w, r, service.V1)
+}
 
-	// generate OOB invitation
-	inv, err := v.agent.OOBClient.CreateInvitation(nil,
-		outofband.WithAccept(transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1),
-		outofband.WithGoal("share-vp", "streamlined-vp"))
+func (v *adapterApp) waciShareV2(w http.ResponseWriter, r *http.Request) {
+	v.waciShareWithVersion(w, r, service.V2)
+}
+
+func (v *adapterApp) waciShareWithVersion(w http.ResponseWriter, r *http.Request, didCommVersion service.Version) {
+	if !parseForm(w, r) {
+		return
+	}
+
+	if err := v.validateVerifierProfile(r.FormValue("profile")); err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	invID, inv, err := v.createWACIShareInvitation(didCommVersion, isConnectionless(r), []byte(r.FormValue("pEx")),
+		r.FormValue("profile"))
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to create oob invitation : %s", err))
+		handleError(w, http.StatusInternalServerError, err.Error())
 
 		return
 	}
 
-	pdBytes = []byte(r.FormValue("pEx"))
+	v.waciInvitationRedirect(w, r, invID, inv)
+}
 
-	v.waciInvitationRedirect(w, r, inv)
+func (v *adapterApp) waciIssuance(w http.ResponseWriter, r *http.Request) {
+	v.waciIssuanceWithVersion(w, r, service.V1)
 }
 
-func (v *adapterApp) waciShareV2(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+func (v *adapterApp) waciIssuanceV2(w http.ResponseWriter, r *http.Request) {
+	v.waciIssuanceWithVersion(w, r, service.V2)
+}
 
-	// generate OOB V2 invitation
-	inv, err := v.agent.OOBV2Client.CreateInvitation(
-		outofbandv2.WithAccept(transport.MediaTypeDIDCommV2Profile, transport.MediaTypeAIP2RFC0587Profile),
-		outofbandv2.WithFrom(v.agent.OrbDIDV2), outofbandv2.WithGoal("share-vp", "streamlined-vp"))
+func (v *adapterApp) waciIssuanceWithVersion(w http.ResponseWriter, r *http.Request, didCommVersion service.Version) {
+	if !parseForm(w, r) {
+		return
+	}
+
+	invID, inv, err := v.createWACIIssuanceInvitation(didCommVersion, isConnectionless(r),
+		waciIssuanceDataFromRequest(r))
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to create oob invitation : %s", err))
+		handleError(w, http.StatusInternalServerError, err.Error())
 
 		return
 	}
 
-	pdBytes = []byte(r.FormValue("pEx"))
+	v.waciInvitationRedirect(w, r, invID, inv)
+}
 
-	v.waciInvitationRedirect(w, r, inv)
+// createWACIShareInvitation creates WACI share invitation for the presentation definition and returns its ID.
+func (v *adapterApp) createWACIShareInvitation(didCommVersion service.Version, connectionless bool,
+	pdBytes []byte, profileID string) (string, interface{}, error) {
+	var (
+		reqThID         string
+		reqPresentation service.DIDCommMsgMap
+		err             error
+	)
+
+	if connectionless {
+		reqThID, reqPresentation, err = v.attachPresentationRequest(pdBytes, profileID, didCommVersion)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to attach presentation request : %w", err)
+		}
+	}
+
+	accept := []string{transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1}
+	if didCommVersion == service.V2 {
+		accept = []string{transport.MediaTypeDIDCommV2Profile, transport.MediaTypeAIP2RFC0587Profile}
+	}
+
+	invID, inv, err := v.createWACIInvitation(didCommVersion, "share-vp", "streamlined-vp", accept,
+		reqPresentation)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = v.store.Put(getPresentationDefinitionKeyPrefix(invID), pdBytes)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save presentation definition : %w", err)
+	}
+
+	err = v.saveVerifierProfileID(invID, profileID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save verifier profile : %w", err)
+	}
+
+	v.transcripts.link(reqThID, invID)
+
+	return invID, inv, nil
 }
 
-func (v *adapterApp) waciIssuance(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+// createWACIIssuanceInvitation creates WACI issuance invitation for the credential and returns its ID.
+func (v *adapterApp) createWACIIssuanceInvitation(didCommVersion service.Version, connectionless bool,
+	waciData *waciIssuanceData) (string, interface{}, error) {
+	var (
+		offerThID string
+		offer     service.DIDCommMsgMap
+		err       error
+	)
 
-	// generate OOB invitation
-	inv, err := v.agent.OOBClient.CreateInvitation(nil,
-		outofband.WithGoal("issue-vc", "streamlined-vc"),
-		outofband.WithAccept(transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1))
+	if connectionless {
+		offerThID, offer, err = v.attachCredentialOffer(waciData, didCommVersion)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to attach credential offer : %w", err)
+		}
+	}
+
+	accept := []string{transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1}
+	if didCommVersion == service.V2 {
+		accept = []string{transport.MediaTypeDIDCommV2Profile}
+	}
+
+	invID, inv, err := v.createWACIInvitation(didCommVersion, "issue-vc", "streamlined-vc", accept, offer)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to create oob invitation : %s", err))
+		return "", nil, err
+	}
 
-		return
+	err = v.persistWACIIssuanceData(waciData, invID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to persist waci data : %w", err)
 	}
 
-	v.persistWACIIssuanceData(w, r, inv.ID)
-	v.waciInvitationRedirect(w, r, inv)
+	if offerThID != "" {
+		err = v.startConnectionlessWACIIssuance(invID, offerThID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to start connectionless waci issuance : %w", err)
+		}
+	}
+
+	return invID, inv, nil
 }
 
-func (v *adapterApp) waciIssuanceV2(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+// createWACIInvitation creates OOB invitation of the DIDComm version, with the first protocol message attached
+// in connectionless flows.
+func (v *adapterApp) createWACIInvitation(didCommVersion service.Version, goal, goalCode string, accept []string,
+	attachment service.DIDCommMsgMap) (string, interface{}, error) {
+	if didCommVersion == service.V2 {
+		opts := []outofbandv2.MessageOption{
+			outofbandv2.WithAccept(accept...),
+			outofbandv2.WithFrom(v.agent.PublicDIDV2), outofbandv2.WithGoal(goal, goalCode),
+		}
+
+		if attachment != nil {
+			opts = append(opts, outofbandv2.WithAttachments(invitationAttachmentV2(attachment)))
+		}
+
+		// generate OOB V2 invitation
+		inv, err := v.agent.OOBV2Client.CreateInvitation(opts...)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create oob invitation : %w", err)
+		}
 
-	// generate OOB V2 invitation
-	inv, err := v.agent.OOBV2Client.CreateInvitation(outofbandv2.WithAccept(transport.MediaTypeDIDCommV2Profile),
-		outofbandv2.WithFrom(v.agent.OrbDIDV2), outofbandv2.WithGoal("issue-vc", "streamlined-vc"))
+		return inv.ID, inv, nil
+	}
+
+	opts := []outofband.MessageOption{
+		outofband.WithAccept(accept...),
+		outofband.WithGoal(goal, goalCode),
+	}
+
+	if attachment != nil {
+		opts = append(opts, outofband.WithAttachments(invitationAttachmentV1(attachment)))
+	}
+
+	// generate OOB invitation
+	inv, err := v.agent.OOBClient.CreateInvitation(nil, opts...)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create oob invitation : %w", err)
+	}
+
+	return inv.ID, inv, nil
+}
+
+// mediatorInvitation returns OOB invitation for wallets to connect to the adapter and request mediation.
+func (v *adapterApp) mediatorInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, err := v.agent.OOBClient.CreateInvitation(nil,
+		outofband.WithGoal("request mediation", "request-mediate"),
+		outofband.WithLabel("mock-adapter-mediator"),
+		outofband.WithAccept(transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1))
 	if err != nil {
 		handleError(w, http.StatusInternalServerError,
 			fmt.Sprintf("failed to create oob invitation : %s", err))
@@ -367,51 +656,57 @@ func (v *adapterApp) waciIssuanceV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	v.persistWACIIssuanceData(w, r, inv.ID)
-	v.waciInvitationRedirect(w, r, inv)
+	writeJSON(w, inv)
 }
 
-func (v *adapterApp) waciInvitationRedirect(w http.ResponseWriter, r *http.Request, inv interface{}) {
-	r.ParseForm()
+// waciInvitationRedirect sends the browser to short URL of the invitation, which redirects it on to the wallet.
+// Clients asking for JSON get the invitation links instead, e.g. to render them as QR code.
+func (v *adapterApp) waciInvitationRedirect(w http.ResponseWriter, r *http.Request, invID string, inv interface{}) {
+	setTranscriptSession(r, invID)
 
-	invBytes, err := json.Marshal(inv)
+	oobInv, err := v.saveOOBInvitation(invID, r.FormValue("walletURL"), inv)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to marshal invitation : %s", err))
+		handleError(w, http.StatusInternalServerError, err.Error())
 
 		return
 	}
 
-	redirectURL := fmt.Sprintf("%s/waci?oob=%s", r.FormValue("walletURL"),
-		base64.URLEncoding.EncodeToString(invBytes))
+	invitationURL := v.oobInvitationURL(invID)
 
-	logger.Infof("waci redirect : url=%s oob-invitation=%s", redirectURL, string(invBytes))
+	logger.Infof("waci redirect : url=%s oob-invitation=%s", invitationURL, string(oobInv.Invitation))
 
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	if acceptsJSON(r) {
+		writeJSON(w, &waciInvitationLinks{
+			InvitationID:  invID,
+			InvitationURL: invitationURL,
+			DeepLink:      v.deepLink(invID, oobInv.WalletURL),
+		})
+
+		return
+	}
+
+	http.Redirect(w, r, invitationURL, http.StatusFound)
 }
 
-func (v *adapterApp) persistWACIIssuanceData(w http.ResponseWriter, r *http.Request, invID string) {
-	waciData, err := json.Marshal(&waciIssuanceData{
-		CredentialResponse: []byte(r.FormValue("response")),
-		CredentialManifest: []byte(r.FormValue("credManifest")),
-		Credential:         []byte(r.FormValue("credToIssue")),
-	})
+func (v *adapterApp) persistWACIIssuanceData(data *waciIssuanceData, invID string) error {
+	waciData, err := json.Marshal(data)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to persist waci data : %s", err))
-
-		return
+		return err
 	}
 
 	err = v.store.Put(getWACIIssuanceDataStoreKeyPrefix(invID), waciData)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to persist waci data : %s", err))
+		return err
+	}
 
-		return
+	err = v.startWACIIssuance(invID)
+	if err != nil {
+		return err
 	}
 
 	logger.Infof("waci redirect :data=%s invitationID=%s", string(waciData), invID)
+
+	return nil
 }
 
 func (v *adapterApp) waciShareCallback(w http.ResponseWriter, r *http.Request) {
@@ -426,7 +721,43 @@ func (v *adapterApp) waciShareCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	loadTemplate(w, waciVerifierHTML, map[string]interface{}{"Msg": "Successfully Received Presentation"})
+	result, err := v.readVerificationResult(id)
+	if err != nil {
+		if failure := v.readDIDCommFailure(id); failure != nil {
+			loadTemplate(w, waciVerifierHTML, map[string]interface{}{
+				"ErrMsg": fmt.Sprintf("ERROR: %s step failed : %s", failure.Step, failure.Reason),
+			})
+
+			return
+		}
+
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get verification result : %s", err))
+
+		return
+	}
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to marshal verification result : %s", err))
+
+		return
+	}
+
+	if !result.Verified {
+		loadTemplate(w, waciVerifierHTML, map[string]interface{}{
+			"ErrMsg":              "ERROR: presentation verification failed",
+			"VERIFICATION_RESULT": string(resultBytes),
+		})
+
+		return
+	}
+
+	loadTemplate(w, waciVerifierHTML, map[string]interface{}{
+		"Msg":                 "Successfully Received Presentation",
+		"VERIFICATION_RESULT": string(resultBytes),
+	})
 }
 
 func (v *adapterApp) waciIssuanceCallback(w http.ResponseWriter, r *http.Request) {
@@ -441,24 +772,74 @@ func (v *adapterApp) waciIssuanceCallback(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if failure := v.readDIDCommFailure(id); failure != nil {
+		loadTemplate(w, waciIssuerHTML, map[string]interface{}{
+			"ErrMsg": fmt.Sprintf("ERROR: %s step failed : %s", failure.Step, failure.Reason),
+		})
+
+		return
+	}
+
+	v.issuanceMu.Lock()
+	status, err := v.readWACIIssuanceStatus(id)
+	v.issuanceMu.Unlock()
+
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get WACI issuance state : %s", err))
+
+		return
+	}
+
+	if !status.succeeded() {
+		errMsg := fmt.Sprintf("ERROR: credential not issued, issuance is %s", status.State)
+		if status.Reason != "" {
+			errMsg += " : " + status.Reason
+		}
+
+		loadTemplate(w, waciIssuerHTML, map[string]interface{}{"ErrMsg": errMsg})
+
+		return
+	}
+
 	loadTemplate(w, waciIssuerHTML, map[string]interface{}{"Msg": "Successfully Sent Credential to holder"})
 }
 
 func (v *adapterApp) oidcShare(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+	if !parseForm(w, r) {
+		return
+	}
 
-	walletAuthURL := r.FormValue("walletAuthURL")
-	pdBytes = []byte(r.FormValue("pEx"))
+	if err := v.validateVerifierProfile(r.FormValue("profile")); err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
 
-	var pd *presexch.PresentationDefinition
-	err := json.Unmarshal(pdBytes, &pd)
+		return
+	}
+
+	state, redirectURL, err := v.createOIDCShareRequest(r.FormValue("walletAuthURL"), []byte(r.FormValue("pEx")),
+		r.FormValue("profile"))
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to unmarshal presentation definition : %s", err))
+		handleError(w, http.StatusInternalServerError, err.Error())
 
 		return
 	}
 
+	setTranscriptSession(r, state)
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// createOIDCShareRequest saves OIDC share session for the presentation definition, and returns its state with
+// the wallet's authorization URL asking for the presentation.
+func (v *adapterApp) createOIDCShareRequest(walletAuthURL string, pdBytes []byte,
+	profileID string) (string, string, error) {
+	var pd *presexch.PresentationDefinition
+
+	err := json.Unmarshal(pdBytes, &pd)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to unmarshal presentation definition : %w", err)
+	}
+
 	authClaims := &OIDCAuthClaims{
 		VPToken: &VPToken{
 			PresDef: pd,
@@ -467,10 +848,7 @@ func (v *adapterApp) oidcShare(w http.ResponseWriter, r *http.Request) {
 
 	claimsBytes, err := json.Marshal(authClaims)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to unmarshal invitation : %s", err))
-
-		return
+		return "", "", fmt.Errorf("failed to unmarshal invitation : %w", err)
 	}
 
 	state := uuid.NewString()
@@ -479,15 +857,12 @@ func (v *adapterApp) oidcShare(w http.ResponseWriter, r *http.Request) {
 	// construct wallet auth req with PEx
 	req, err := http.NewRequest("GET", walletAuthURL, nil)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to get interaction data : %s", err))
-
-		return
+		return "", "", fmt.Errorf("failed to get interaction data : %w", err)
 	}
 
 	q := req.URL.Query()
 	q.Add("client_id", "demo-verifier")
-	q.Add("redirect_uri", os.Getenv(demoExternalURLEnvKey)+"/verifier/oidc/share/cb")
+	q.Add("redirect_uri", v.cfg.ExternalURL+"/verifier/oidc/share/cb")
 	q.Add("scope", "openid")
 	q.Add("state", state)
 	q.Add("claims", string(claimsBytes))
@@ -500,13 +875,15 @@ func (v *adapterApp) oidcShare(w http.ResponseWriter, r *http.Request) {
 
 	err = v.store.Put(state, pdBytes)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to save state data : %s", err))
+		return "", "", fmt.Errorf("failed to save state data : %w", err)
+	}
 
-		return
+	err = v.saveVerifierProfileID(state, profileID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save verifier profile : %w", err)
 	}
 
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	return state, redirectURL, nil
 }
 
 func (v *adapterApp) oidcShareCallback(w http.ResponseWriter, r *http.Request) {
@@ -564,8 +941,10 @@ func (v *adapterApp) oidcShareCallback(w http.ResponseWriter, r *http.Request) {
 
 	logger.Infof("oidc share callback : _vp_token=%v vp_token=%s", string(presSubBytes), vpToken)
 
-	pres, err := verifiable.ParsePresentation([]byte(vpToken), verifiable.WithPresJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)), verifiable.WithPresDisabledProofCheck())
+	pres, err := v.parseVerifiedPresentation([]byte(vpToken))
 	if err != nil {
+		v.metrics.presentationReceived(protocolOIDC, false)
+
 		loadTemplate(w, oidcVerifierHTML,
 			map[string]interface{}{
 				"ErrMsg":                    fmt.Sprintf("ERROR: failed to validate presentation : %s", err),
@@ -578,19 +957,43 @@ func (v *adapterApp) oidcShareCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	result, err := v.verifyPresentation(pres, v.readVerifierProfileID(state))
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to verify presentation : %s", err))
+
+		return
+	}
+
+	v.metrics.presentationReceived(protocolOIDC, result.Verified)
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to marshal verification result : %s", err))
+
+		return
+	}
+
 	pres.JWT = ""
 
 	presBytes, _ := pres.MarshalJSON()
 
-	loadTemplate(w, oidcVerifierHTML,
-		map[string]interface{}{
-			"Msg":                       "Successfully Received Presentation",
-			"ID_TOKEN":                  "\n" + idToken,
-			"DECODED_VPDEF_IN_ID_TOKEN": string(presSubBytes),
-			"VP_TOKEN":                  string(vpToken),
-			"DECODED_VP_TOKEN":          string(presBytes),
-		},
-	)
+	data := map[string]interface{}{
+		"Msg":                       "Successfully Received Presentation",
+		"ID_TOKEN":                  "\n" + idToken,
+		"DECODED_VPDEF_IN_ID_TOKEN": string(presSubBytes),
+		"VP_TOKEN":                  string(vpToken),
+		"DECODED_VP_TOKEN":          string(presBytes),
+		"VERIFICATION_RESULT":       string(resultBytes),
+	}
+
+	if !result.Verified {
+		delete(data, "Msg")
+		data["ErrMsg"] = "ERROR: presentation verification failed"
+	}
+
+	loadTemplate(w, oidcVerifierHTML, data)
 }
 
 func (v *adapterApp) openid4vcShare(w http.ResponseWriter, r *http.Request) {
@@ -623,13 +1026,35 @@ func (v *adapterApp) openid4vcShare(w http.ResponseWriter, r *http.Request) {
 
 	claims := claims{VPToken: vpToken{PresentationDefinition: *pd}}
 
+	// sessions created through admin API pass their state, with the verifier profile saved already.
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		if err := v.validateVerifierProfile(r.URL.Query().Get("profile")); err != nil {
+			handleError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		var err error
+
+		state, err = v.createOpenID4VCShareState(r.URL.Query().Get("profile"))
+		if err != nil {
+			handleError(w, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+	}
+
+	setTranscriptSession(r, state)
+
 	requestObjectPayload, err := json.Marshal(&openid4vcShareRequestPayload{
 		IssuedAt:     time.Now().Unix(),
 		ResponseType: "id_token",
 		Scope:        "openid",
 		Nonce:        uuid.NewString(),
 		ClientId:     "did:ion:EiAv0eJ5cB0hGWVH5YbY-uw1K71EpOST6ztueEQzVCEc0A:eyJkZWx0YSI6eyJwYXRjaGVzIjpbeyJhY3Rpb24iOiJyZXBsYWNlIiwiZG9jdW1lbnQiOnsicHVibGljS2V5cyI6W3siaWQiOiJzaWdfY2FiNjVhYTAiLCJwdWJsaWNLZXlKd2siOnsiY3J2Ijoic2VjcDI1NmsxIiwia3R5IjoiRUMiLCJ4IjoiOG15MHFKUGt6OVNRRTkyRTlmRFg4ZjJ4bTR2X29ZMXdNTEpWWlQ1SzhRdyIsInkiOiIxb0xsVG5rNzM2RTNHOUNNUTh3WjJQSlVBM0phVnY5VzFaVGVGSmJRWTFFIn0sInB1cnBvc2VzIjpbImF1dGhlbnRpY2F0aW9uIiwiYXNzZXJ0aW9uTWV0aG9kIl0sInR5cGUiOiJFY2RzYVNlY3AyNTZrMVZlcmlmaWNhdGlvbktleTIwMTkifV0sInNlcnZpY2VzIjpbeyJpZCI6ImxpbmtlZGRvbWFpbnMiLCJzZXJ2aWNlRW5kcG9pbnQiOnsib3JpZ2lucyI6WyJodHRwczovL3N3ZWVwc3Rha2VzLmRpZC5taWNyb3NvZnQuY29tLyJdfSwidHlwZSI6IkxpbmtlZERvbWFpbnMifV19fV0sInVwZGF0ZUNvbW1pdG1lbnQiOiJFaUFwcmVTNy1Eczh5MDFnUzk2cE5iVnpoRmYxUlpvblZ3UkswbG9mZHdOZ2FBIn0sInN1ZmZpeERhdGEiOnsiZGVsdGFIYXNoIjoiRWlEMWRFdUVldERnMnhiVEs0UDZVTTNuWENKVnFMRE11M29IVWNMamtZMWFTdyIsInJlY292ZXJ5Q29tbWl0bWVudCI6IkVpREFkSzFWNkpja1BpY0RBcGFxV2IyZE95MFRNcmJKTmllNmlKVzk4Zk54bkEifX0",
-		RedirectURI:  os.Getenv(demoExternalURLEnvKey) + "/verifier/openid4vc/share/cb",
+		RedirectURI:  v.cfg.ExternalURL + "/verifier/openid4vc/share/cb",
+		State:        state,
 		Expiry:       time.Now().Unix() + 60*10,
 		Claims:       claims,
 	})
@@ -667,15 +1092,35 @@ func (v *adapterApp) openid4vcShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	v.transcripts.recordToken(state, "request_object", result)
+
 	w.Header().Set("Content-Type", "application/jwt")
 	w.WriteHeader(200)
 	w.Write([]byte(result))
 }
 
+// createOpenID4VCShareState starts OpenID4VC share session verifying presentations with the profile, and
+// returns its state.
+func (v *adapterApp) createOpenID4VCShareState(profileID string) (string, error) {
+	state := uuid.NewString()
+
+	err := v.saveVerifierProfileID(state, profileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to save verifier profile : %w", err)
+	}
+
+	return state, nil
+}
+
+// openid4vcShareRequestURI returns URI of the signed request object of OpenID4VC share session.
+func (v *adapterApp) openid4vcShareRequestURI(state string) string {
+	return v.cfg.ExternalURL + "/verifier/openid4vc/share?state=" + url.QueryEscape(state)
+}
+
 func (v *adapterApp) openid4vcShareCallback(w http.ResponseWriter, r *http.Request) {
 	idToken := r.FormValue("id_token")
 	if len(idToken) == 0 {
-		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to verify presentation: id_token is empty"))
+		handleError(w, http.StatusBadRequest, "failed to verify presentation: id_token is empty")
 		return
 	}
 
@@ -687,7 +1132,7 @@ func (v *adapterApp) openid4vcShareCallback(w http.ResponseWriter, r *http.Reque
 
 	vpToken := r.FormValue("vp_token")
 	if len(vpToken) == 0 {
-		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to verify presentation: vp_token is empty"))
+		handleError(w, http.StatusBadRequest, "failed to verify presentation: vp_token is empty")
 		return
 	}
 
@@ -700,88 +1145,121 @@ func (v *adapterApp) openid4vcShareCallback(w http.ResponseWriter, r *http.Reque
 	logger.Infof("oidc share callback: id_token=%s", idToken)
 	logger.Infof("oidc share callback: vp_token=%s", vpToken)
 
+	pres, err := v.parseVerifiedPresentation([]byte(vpToken))
+	if err != nil {
+		v.metrics.presentationReceived(protocolOpenID4VC, false)
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse presentation: %s", err))
+		return
+	}
+
+	result, err := v.verifyPresentation(pres, v.readVerifierProfileID(r.FormValue("state")))
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to verify presentation: %s", err))
+		return
+	}
+
+	v.metrics.presentationReceived(protocolOpenID4VC, result.Verified)
+
 	// TODO add validation
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
-	return
+	json.NewEncoder(w).Encode(result)
 }
 
 func (v *adapterApp) initiateIssuance(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+	if !parseForm(w, r) {
+		return
+	}
 
-	walletURL := r.FormValue("walletInitIssuanceURL")
-	credentialTypes := strings.Split(r.FormValue("credentialTypes"), ",")
-	manifestIDs := strings.Split(r.FormValue("manifestIDs"), ",")
-	issuerURL := r.FormValue("issuerURL")
-	credManifest := r.FormValue("credManifest")
-	credentials := r.FormValue("credsToIssue")
+	var credentials map[string]json.RawMessage
 
-	key := uuid.NewString()
-	issuer := issuerURL + "/" + key
-	issuerConf, err := json.MarshalIndent(&issuerConfiguration{
-		Issuer:                issuer,
-		AuthorizationEndpoint: issuer + "/issuer/oidc/authorize",
-		TokenEndpoint:         issuer + "/issuer/oidc/token",
-		CredentialEndpoint:    issuer + "/issuer/oidc/credential",
-		CredentialManifests:   []byte(credManifest),
-	}, "", "	")
+	err := json.Unmarshal([]byte(r.FormValue("credsToIssue")), &credentials)
 	if err != nil {
 		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to prepare issuer wellknown configuration : %s", err))
+			fmt.Sprintf("failed to parse credentials : %s", err))
 
 		return
 	}
 
-	err = v.store.Put(key, issuerConf)
+	key, walletURL, err := v.createOIDCIssuance(&oidcIssuanceParams{
+		WalletInitIssuanceURL: r.FormValue("walletInitIssuanceURL"),
+		IssuerURL:             r.FormValue("issuerURL"),
+		CredentialTypes:       strings.Split(r.FormValue("credentialTypes"), ","),
+		ManifestIDs:           strings.Split(r.FormValue("manifestIDs"), ","),
+		CredentialManifest:    []byte(r.FormValue("credManifest")),
+		Credentials:           credentials,
+	})
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to prepare server configuration : %s", err))
+		handleError(w, http.StatusInternalServerError, err.Error())
 
 		return
 	}
 
-	var credentialsToSave map[string]json.RawMessage
-	err = json.Unmarshal([]byte(credentials), &credentialsToSave)
-	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to parse credentials : %s", err))
+	setTranscriptSession(r, key)
 
-		return
-	}
+	http.Redirect(w, r, walletURL, http.StatusFound)
+}
 
-	for ct, credential := range credentialsToSave {
-		err = v.store.Put(getCredStoreKeyPrefix(key, ct), credential)
-		if err != nil {
-			handleError(w, http.StatusInternalServerError,
-				fmt.Sprintf("failed to server configuration : %s", err))
+// createOIDCIssuance prepares issuer configuration and credentials of OIDC issuance, and returns the session key
+// with the URL which initiates issuance in the wallet.
+func (v *adapterApp) createOIDCIssuance(params *oidcIssuanceParams) (string, string, error) {
+	key := uuid.NewString()
+	issuer := params.IssuerURL + "/" + key
 
-			return
-		}
+	v.metrics.offerCreated(protocolOIDC)
+
+	issuerConf, err := json.MarshalIndent(&issuerConfiguration{
+		Issuer:                issuer,
+		AuthorizationEndpoint: issuer + "/issuer/oidc/authorize",
+		TokenEndpoint:         issuer + "/issuer/oidc/token",
+		CredentialEndpoint:    issuer + "/issuer/oidc/credential",
+		CredentialManifests:   params.CredentialManifest,
+	}, "", "	")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to prepare issuer wellknown configuration : %w", err)
+	}
+
+	err = v.store.Put(key, issuerConf)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to prepare server configuration : %w", err)
 	}
 
-	u, err := url.Parse(walletURL)
+	err = v.saveIssuanceCredentials(key, params.Credentials)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to parse wallet init issuance URL : %s", err))
+		return "", "", err
+	}
 
-		return
+	u, err := url.Parse(params.WalletInitIssuanceURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse wallet init issuance URL : %w", err)
 	}
 
 	q := u.Query()
 	q.Set("issuer", issuer)
 
-	for _, credType := range credentialTypes {
+	for _, credType := range params.CredentialTypes {
 		q.Add("credential_type", credType)
 	}
 
-	for _, manifestID := range manifestIDs {
+	for _, manifestID := range params.ManifestIDs {
 		q.Add("manifest_id", manifestID)
 	}
 
 	u.RawQuery = q.Encode()
 
-	http.Redirect(w, r, u.String(), http.StatusFound)
+	return key, u.String(), nil
+}
+
+func (v *adapterApp) saveIssuanceCredentials(key string, credentials map[string]json.RawMessage) error {
+	for ct, credential := range credentials {
+		err := v.store.Put(getCredStoreKeyPrefix(key, ct), credential)
+		if err != nil {
+			return fmt.Errorf("failed to server configuration : %w", err)
+		}
+	}
+
+	return nil
 }
 
 func (v *adapterApp) wellKnownConfiguration(w http.ResponseWriter, r *http.Request) {
@@ -860,6 +1338,8 @@ func (v *adapterApp) issuerAuthorize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	v.transcripts.link(authState, mux.Vars(r)["id"])
+
 	authStateCookie := http.Cookie{
 		Name:    "state",
 		Value:   authState,
@@ -911,6 +1391,9 @@ func (v *adapterApp) issuerSendAuthorizeResponse(w http.ResponseWriter, r *http.
 	authCode := uuid.NewString()
 	v.store.Put(getAuthCodeKeyPrefix(authCode), []byte(stateCookie.Value))
 
+	v.transcripts.recordToken(stateCookie.Value, "authorization_code", authCode)
+	v.transcripts.link(authCode, stateCookie.Value)
+
 	redirectTo := fmt.Sprintf("%s?code=%s&state=%s", redirectURI, authCode, state)
 
 	// TODO process credential types or manifests from claims and prepare credential endpoint with credential to be issued.
@@ -949,7 +1432,7 @@ func (v *adapterApp) issuerTokenEndpoint(w http.ResponseWriter, r *http.Request)
 	}
 
 	if authRedirectURI := authRequest["redirect_uri"]; authRedirectURI != redirectURI {
-		sendOIDCErrorResponse(w, "request validation failed", http.StatusInternalServerError)
+		sendOIDCErrorResponse(w, "request validation failed", http.StatusBadRequest)
 		return
 	}
 
@@ -962,6 +1445,9 @@ func (v *adapterApp) issuerTokenEndpoint(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	v.transcripts.recordToken(mockIssuerID, "access_token", mockAccessToken)
+	v.transcripts.link(mockAccessToken, mockIssuerID)
+
 	response, err := json.Marshal(map[string]interface{}{
 		"token_type":   "Bearer",
 		"access_token": mockAccessToken,
@@ -1066,85 +1552,114 @@ func (v *adapterApp) issuerCredentialEndpoint(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	v.metrics.credentialIssued(protocolOIDC, oidcCredentialFormat(format))
+
 	w.Write(response)
 }
 
 func (v *adapterApp) openid4vcInitiatePreAuthorizedIssuance(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm()
+	if !parseForm(w, r) {
+		return
+	}
+
+	t, err := template.ParseFiles(openid4vcIssuerHTML)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to parse issuer html template : %s", err))
+		return
+	}
+
+	var credentials map[string]json.RawMessage
+	err = json.Unmarshal([]byte(r.FormValue("credsToIssue")), &credentials)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to parse credentials : %s", err))
+
+		return
+	}
+
+	issuance, err := v.createOpenID4VCIssuance(&openid4vcIssuanceParams{
+		IssuerURL:            r.FormValue("issuerURL"),
+		CredentialType:       r.FormValue("credentialType"),
+		CredentialsSupported: []byte(r.FormValue("credentialsSupported")),
+		Credentials:          credentials,
+	})
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	setTranscriptSession(r, issuance.key)
 
-	issuerURL := r.FormValue("issuerURL")
-	credType := r.FormValue("credentialType")
-	credentialsSupported := r.FormValue("credentialsSupported")
-	credentials := r.FormValue("credsToIssue")
+	err = t.Execute(w, openid4ciDemo{
+		InitiateUrl: issuance.InitiateUrl,
+		Pin:         issuance.Pin,
+	})
+	if err != nil {
+		logger.Errorf("failed to execute issuer html template : %s", err)
+	}
+}
 
+// createOpenID4VCIssuance prepares issuer configuration and credentials of pre-authorized OpenID4VC issuance,
+// and returns the initiate issuance URL with the PIN the holder has to enter.
+func (v *adapterApp) createOpenID4VCIssuance(params *openid4vcIssuanceParams) (*openid4vcIssuance, error) {
 	pinNumber, err := generateRandomNumber(6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate pin : %w", err)
+	}
+
 	preAuthCode := uuid.NewString()
 
 	authRequest, err := json.Marshal(map[string]string{
 		"code": preAuthCode,
 		"pin":  pinNumber,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pre-authorized code : %w", err)
+	}
+
 	err = v.store.Put(getPreAuthCodeKeyPrefix(preAuthCode), authRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save pre-authorized code : %w", err)
+	}
 
 	key := uuid.NewString()
-	issuer := issuerURL + "/" + key
+	issuer := params.IssuerURL + "/" + key
+
+	v.transcripts.recordToken(key, "pre-authorized_code", preAuthCode)
+	v.transcripts.recordToken(key, "user_pin", pinNumber)
+	v.transcripts.link(preAuthCode, key)
+	v.metrics.offerCreated(protocolOpenID4VC)
+
 	issuerConf, err := json.MarshalIndent(&openid4vcIssuerConfiguration{
 		Issuer:               issuer,
-		CredentialsSupported: []byte(credentialsSupported),
+		CredentialsSupported: params.CredentialsSupported,
 		CredentialEndpoint:   issuer + "/issuer/openid4vc/credential",
 		TokenEndpoint:        issuer + "/issuer/openid4vc/token",
 	}, "", "	")
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to prepare issuer wellknown configuration : %s", err))
-		return
-	}
-
-	t, err := template.ParseFiles(openid4vcIssuerHTML)
-	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to parse issuer html template : %s", err))
-		return
-	}
-
-	if err != nil {
-		logger.Errorf(fmt.Sprintf("execute html template: %s", err.Error()))
+		return nil, fmt.Errorf("failed to prepare issuer wellknown configuration : %w", err)
 	}
 
 	err = v.store.Put(key, issuerConf)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to prepare server configuration : %s", err))
-
-		return
+		return nil, fmt.Errorf("failed to prepare server configuration : %w", err)
 	}
 
-	var credentialsToSave map[string]json.RawMessage
-	err = json.Unmarshal([]byte(credentials), &credentialsToSave)
+	err = v.saveIssuanceCredentials(key, params.Credentials)
 	if err != nil {
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to parse credentials : %s", err))
-
-		return
-	}
-
-	for ct, credential := range credentialsToSave {
-		err = v.store.Put(getCredStoreKeyPrefix(key, ct), credential)
-		if err != nil {
-			handleError(w, http.StatusInternalServerError,
-				fmt.Sprintf("failed to server configuration : %s", err))
-
-			return
-		}
+		return nil, err
 	}
 
 	initiateUrl := "openid-initiate-issuance://?issuer=" + url.QueryEscape(issuer) + "&credential_type=" +
-		url.QueryEscape(credType) + "&pre-authorized_code=" + url.QueryEscape(preAuthCode) + "&user_pin_required=true"
+		url.QueryEscape(params.CredentialType) + "&pre-authorized_code=" + url.QueryEscape(preAuthCode) +
+		"&user_pin_required=true"
 
-	err = t.Execute(w, openid4ciDemo{
-		InitiateUrl: initiateUrl,
-		Pin:         pinNumber,
-	})
+	return &openid4vcIssuance{
+		openid4ciDemo: openid4ciDemo{InitiateUrl: initiateUrl, Pin: pinNumber},
+		key:           key,
+	}, nil
 }
 
 func (v *adapterApp) openid4vcIssuerTokenEndpoint(w http.ResponseWriter, r *http.Request) {
@@ -1162,8 +1677,6 @@ func (v *adapterApp) openid4vcIssuerTokenEndpoint(w http.ResponseWriter, r *http
 	authRqstBytes, err := v.store.Get(getPreAuthCodeKeyPrefix(code))
 	if err != nil {
 		sendOIDCErrorResponse(w, "invalid request", http.StatusBadRequest)
-		handleError(w, http.StatusInternalServerError,
-			fmt.Sprintf("failed to fetch auth state : %s", err))
 		return
 	}
 
@@ -1175,7 +1688,7 @@ func (v *adapterApp) openid4vcIssuerTokenEndpoint(w http.ResponseWriter, r *http
 	}
 
 	if userPin != authRequest["pin"] {
-		sendOIDCErrorResponse(w, "request validation failed", http.StatusInternalServerError)
+		sendOIDCErrorResponse(w, "request validation failed", http.StatusBadRequest)
 		return
 	}
 
@@ -1188,6 +1701,9 @@ func (v *adapterApp) openid4vcIssuerTokenEndpoint(w http.ResponseWriter, r *http
 		return
 	}
 
+	v.transcripts.recordToken(mockIssuerID, "access_token", mockAccessToken)
+	v.transcripts.link(mockAccessToken, mockIssuerID)
+
 	response, err := json.Marshal(map[string]interface{}{
 		"token_type":         "Bearer",
 		"access_token":       mockAccessToken,
@@ -1207,11 +1723,17 @@ func (v *adapterApp) openid4vcIssuerCredentialEndpoint(w http.ResponseWriter, r
 	setOIDCResponseHeaders(w)
 
 	request := map[string]interface{}{}
+
 	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		sendOIDCErrorResponse(w, "invalid request", http.StatusBadRequest)
+		return
+	}
 
 	proof, isValid := request["proof"].(map[string]interface{})
 	if !isValid {
 		sendOIDCErrorResponse(w, "couldn't read proof parameter", http.StatusBadRequest)
+		return
 	}
 
 	jwt := fmt.Sprintf("%v", proof["jwt"])
@@ -1302,162 +1824,269 @@ func (v *adapterApp) openid4vcIssuerCredentialEndpoint(w http.ResponseWriter, r
 		return
 	}
 
+	v.metrics.credentialIssued(protocolOpenID4VC, oidcCredentialFormat(fmt.Sprintf("%v", format)))
+
 	w.Write(response)
 }
 
-func listenForDIDCommMsg(actionCh chan service.DIDCommAction, store storage.Store) {
-	for action := range actionCh {
-		logger.Infof("received action message : type=%s", action.Message.Type())
-
-		switch action.Message.Type() {
-		case didexchange.RequestMsgType:
-			action.Continue(nil)
-		case presentproofsvc.ProposePresentationMsgTypeV2, presentproofsvc.ProposePresentationMsgTypeV3:
-			thID, err := action.Message.ThreadID()
-			if err != nil {
-				logger.Errorf("failed to get thread ID", err)
-				action.Stop(nil)
-			}
-
-			pd := presexch.PresentationDefinition{}
-			err = json.Unmarshal(pdBytes, &pd)
-			if err != nil {
-				logger.Errorf("failed to unmarshal presentation definition", err)
-				action.Stop(nil)
-			}
-
-			err = store.Put(thID, pdBytes)
-			if err != nil {
-				logger.Errorf("failed to save presentation definition", err)
-				action.Stop(nil)
-			}
-
-			continueArg := presentproof.WithRequestPresentation(&presentproof.RequestPresentation{
-				Comment: "Request Presentation",
-				Attachments: []decorator.GenericAttachment{
-					{
-						ID:        uuid.NewString(),
-						MediaType: "application/json",
-						Data: decorator.AttachmentData{
-							JSON: struct {
-								Challenge string                           `json:"challenge"`
-								Domain    string                           `json:"domain"`
-								PD        *presexch.PresentationDefinition `json:"presentation_definition"`
-							}{
-								Challenge: uuid.NewString(),
-								Domain:    uuid.NewString(),
-								PD:        &pd,
-							},
-						},
-					},
-				},
-				WillConfirm: true,
-			})
+// verifyDIDCommPresentation verifies proof of the presentation attached to present-proof message against
+// the challenge and domain sent in request-presentation, evaluates it against the presentation definition
+// and then checks credential status and verifier policy.
+func (v *adapterApp) verifyDIDCommPresentation(msg service.DIDCommMsg, thID string) (*verificationResult, error) {
+	presReq, err := v.readPresentationRequest(thID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation request : %w", err)
+	}
 
-			action.Continue(continueArg)
-		case presentproofsvc.PresentationMsgTypeV2, presentproofsvc.PresentationMsgTypeV3:
-			thID, err := action.Message.ThreadID()
-			if err != nil {
-				logger.Errorf("failed to get thread ID", err)
-				action.Stop(nil)
-			}
-
-			action.Continue(presentproofsvc.WithProperties(
-				map[string]interface{}{
-					"~web-redirect": &decorator.WebRedirect{
-						Status: "OK",
-						URL:    os.Getenv(demoExternalURLEnvKey) + "/verifier/waci-share/" + thID,
-					},
-				},
-			))
-		case issuecredential.ProposeCredentialMsgTypeV2, issuecredential.ProposeCredentialMsgTypeV3:
-			thID, err := action.Message.ThreadID()
-			if err != nil {
-				logger.Errorf("failed to get thread ID", err)
-				action.Stop(nil)
-			}
-
-			err = store.Put(thID, []byte(thID))
-			if err != nil {
-				logger.Errorf("failed to save interaction data", err)
-				action.Stop(nil)
-			}
-
-			var msgData map[string]interface{}
-			err = action.Message.Decode(&msgData)
-			if err != nil {
-				logger.Errorf("failed to decode propose credential message", err)
-				action.Stop(nil)
-			}
-
-			var invitationID string
-			if invID, ok := msgData["invitationID"]; ok {
-				invitationID, _ = invID.(string)
-			} else if invID, ok := msgData["pthid"]; ok {
-				invitationID, _ = invID.(string)
-			}
-
-			waciData, err := readWACIIssuanceData(store, invitationID, action.Message.ID())
-			if err != nil {
-				logger.Errorf("failed to get WACI issuance data", err)
-				action.Stop(nil)
-			}
-
-			vp, err := createResponseVP(waciData.CredentialResponse, waciData.Credential, false)
-			if err != nil {
-				logger.Errorf("failed to prepare response", err)
-				action.Stop(nil)
-			}
-
-			credResponseBytes, err := vp.MarshalJSON()
-			if err != nil {
-				logger.Errorf("failed to prepare response bytes", err)
-				action.Stop(nil)
-			}
-
-			offerCredMsg, err := createOfferCredentialMsg(waciData.CredentialManifest, credResponseBytes)
-			if err != nil {
-				logger.Errorf("failed to prepare offer credential message", err)
-				action.Stop(nil)
-			}
-
-			action.Continue(issuecredential.WithOfferCredential(offerCredMsg))
-		case issuecredential.RequestCredentialMsgTypeV2, issuecredential.RequestCredentialMsgTypeV3:
-			thID, err := action.Message.ThreadID()
-			if err != nil {
-				logger.Errorf("failed to get thread ID", err)
-				action.Stop(nil)
-			}
-
-			waciData, err := readWACIIssuanceData(store, thID, "")
-			if err != nil {
-				logger.Errorf("failed to get WACI issuance data", err)
-				action.Stop(nil)
-			}
-
-			vp, err := createResponseVP(waciData.CredentialResponse, waciData.Credential, true)
-			if err != nil {
-				logger.Errorf("failed to prepare response", err)
-				action.Stop(nil)
-			}
-
-			credResponseBytes, err := vp.MarshalJSON()
-			if err != nil {
-				logger.Errorf("failed to prepare response bytes", err)
-				action.Stop(nil)
-			}
-
-			issueCredMsg, err := createIssueCredentialMsg(credResponseBytes, os.Getenv(demoExternalURLEnvKey)+"/issuer/waci-issuance/"+thID)
-			if err != nil {
-				logger.Errorf("failed to prepare issue credential message", err)
-				action.Stop(nil)
-			}
-
-			action.Continue(issuecredential.WithIssueCredential(issueCredMsg))
-		default:
-			action.Stop(nil)
+	pdBytes, err := v.store.Get(thID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation definition : %w", err)
+	}
+
+	var pd presexch.PresentationDefinition
+
+	err = json.Unmarshal(pdBytes, &pd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal presentation definition : %w", err)
+	}
+
+	keyFetcher := verifiable.NewVDRKeyResolver(v.agent.VDRegistry).PublicKeyFetcher()
+	docLoader := ld.NewDefaultDocumentLoader(nil)
+
+	vp, err := parseDIDCommPresentation(msg, verifiable.WithPresPublicKeyFetcher(keyFetcher),
+		verifiable.WithPresJSONLDDocumentLoader(docLoader))
+	if err != nil {
+		return &verificationResult{Error: fmt.Sprintf("invalid presentation : %s", err)}, nil
+	}
+
+	err = checkPresentationChallenge(vp, presReq)
+	if err != nil {
+		return &verificationResult{Error: err.Error()}, nil
+	}
+
+	_, err = pd.Match(vp, docLoader, presexch.WithCredentialOptions(
+		verifiable.WithPublicKeyFetcher(keyFetcher), verifiable.WithJSONLDDocumentLoader(docLoader)))
+	if err != nil {
+		return &verificationResult{Error: fmt.Sprintf("presentation does not match presentation definition : %s", err)}, nil
+	}
+
+	return v.verifyPresentation(vp, v.readVerifierProfileID(thID))
+}
+
+// parseVerifiedPresentation parses presentation received over OIDC, verifying proofs of the presentation and of
+// its credentials, which trusted issuers and policy are then evaluated on.
+func (v *adapterApp) parseVerifiedPresentation(vpBytes []byte) (*verifiable.Presentation, error) {
+	keyFetcher := verifiable.NewVDRKeyResolver(v.vdr).PublicKeyFetcher()
+	docLoader := ld.NewDefaultDocumentLoader(nil)
+
+	vp, err := verifiable.ParsePresentation(vpBytes, verifiable.WithPresPublicKeyFetcher(keyFetcher),
+		verifiable.WithPresJSONLDDocumentLoader(docLoader))
+	if err != nil {
+		return nil, err
+	}
+
+	// presentations and credentials without proof are parsed without error.
+	if vp.JWT == "" && len(vp.Proofs) == 0 {
+		return nil, errors.New("presentation is missing proof")
+	}
+
+	creds, err := vp.MarshalledCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation credentials : %w", err)
+	}
+
+	for i, cred := range creds {
+		vc, e := verifiable.ParseCredential(cred, verifiable.WithPublicKeyFetcher(keyFetcher),
+			verifiable.WithJSONLDDocumentLoader(docLoader))
+		if e != nil {
+			return nil, fmt.Errorf("invalid credential %d : %w", i, e)
+		}
+
+		if vc.JWT == "" && len(vc.Proofs) == 0 {
+			return nil, fmt.Errorf("credential %d is missing proof", i)
+		}
+	}
+
+	return vp, nil
+}
+
+// verifyPresentation checks credential status and then evaluates verifier profile's trusted issuers and policy.
+func (v *adapterApp) verifyPresentation(vp *verifiable.Presentation, profileID string) (*verificationResult, error) {
+	result, err := v.statusChecker.checkPresentation(vp)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := v.verifierProfile(profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	violation, err := profile.evaluate(vp)
+	if err != nil {
+		return nil, err
+	}
+
+	if violation != nil {
+		logger.Infof("presentation rejected by policy rule : profile=%s rule=%s reason=%s",
+			profileID, violation.Rule, violation.Reason)
+
+		result.Verified = false
+		result.PolicyViolation = violation
+	}
+
+	return result, nil
+}
+
+func (v *adapterApp) saveVerifierProfileID(id, profileID string) error {
+	if err := v.validateVerifierProfile(profileID); err != nil {
+		return err
+	}
+
+	if profileID == "" {
+		profileID = defaultVerifierProfile
+	}
+
+	return v.store.Put(getVerifierProfileKeyPrefix(id), []byte(profileID))
+}
+
+// readVerifierProfileID returns verifier profile ID saved for the interaction, or the default profile.
+func (v *adapterApp) readVerifierProfileID(id string) string {
+	profileID, err := v.store.Get(getVerifierProfileKeyPrefix(id))
+	if err != nil {
+		return defaultVerifierProfile
+	}
+
+	return string(profileID)
+}
+
+func (v *adapterApp) saveVerificationResult(id string, result *verificationResult) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return v.store.Put(getVerificationResultKeyPrefix(id), resultBytes)
+}
+
+func (v *adapterApp) readVerificationResult(id string) (*verificationResult, error) {
+	resultBytes, err := v.store.Get(getVerificationResultKeyPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var result verificationResult
+
+	err = json.Unmarshal(resultBytes, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// parseDIDCommPresentation reads the presentation attached to present-proof message.
+func parseDIDCommPresentation(msg service.DIDCommMsg, opts ...verifiable.PresentationOpt,
+) (*verifiable.Presentation, error) {
+	var presentation presentproofsvc.PresentationParams
+
+	err := msg.Decode(&presentation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode presentation message : %w", err)
+	}
+
+	if len(presentation.Attachments) == 0 {
+		return nil, fmt.Errorf("presentation message is missing attachments")
+	}
+
+	vpBytes, err := presentation.Attachments[0].Data.Fetch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation attachment : %w", err)
+	}
+
+	return verifiable.ParsePresentation(vpBytes, opts...)
+}
+
+// checkPresentationChallenge checks that presentation proofs were created for given challenge and domain.
+func checkPresentationChallenge(vp *verifiable.Presentation, presReq *presentationRequest) error {
+	if vp.JWT != "" {
+		var claims struct {
+			Nonce    string      `json:"nonce"`
+			Audience interface{} `json:"aud"`
 		}
+
+		err := decodeJWTClaims(vp.JWT, &claims)
+		if err != nil {
+			return err
+		}
+
+		if claims.Nonce != presReq.Challenge || !containsValue(claims.Audience, presReq.Domain) {
+			return fmt.Errorf("presentation JWT nonce or audience does not match the request")
+		}
+
+		return nil
+	}
+
+	if len(vp.Proofs) == 0 {
+		return fmt.Errorf("presentation is missing proof")
+	}
+
+	for _, proof := range vp.Proofs {
+		if proof["challenge"] != presReq.Challenge {
+			return fmt.Errorf("presentation proof challenge does not match the request")
+		}
+
+		if proof["domain"] != presReq.Domain {
+			return fmt.Errorf("presentation proof domain does not match the request")
+		}
+	}
+
+	return nil
+}
+
+func (v *adapterApp) savePresentationRequest(thID string, presReq *presentationRequest) error {
+	presReqBytes, err := json.Marshal(presReq)
+	if err != nil {
+		return err
+	}
+
+	return v.store.Put(getPresentationRequestKeyPrefix(thID), presReqBytes)
+}
+
+func (v *adapterApp) readPresentationRequest(thID string) (*presentationRequest, error) {
+	presReqBytes, err := v.store.Get(getPresentationRequestKeyPrefix(thID))
+	if err != nil {
+		return nil, err
+	}
+
+	var presReq presentationRequest
+
+	err = json.Unmarshal(presReqBytes, &presReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &presReq, nil
+}
+
+// waciIssuanceDataFromRequest reads credential to issue and its format from the issuer's WACI form. Credential
+// manifest is only used by the credential manifest format.
+func waciIssuanceDataFromRequest(r *http.Request) *waciIssuanceData {
+	format := r.FormValue("credentialFormat")
+	if format == "" {
+		format = credentialFormatManifest
+	}
+
+	data := &waciIssuanceData{
+		Credential:       []byte(r.FormValue("credToIssue")),
+		CredentialFormat: format,
 	}
+
+	if format == credentialFormatManifest {
+		data.CredentialManifest = []byte(r.FormValue("credManifest"))
+	}
+
+	return data
 }
 
 func readWACIIssuanceData(store storage.Store, id string, newID string) (*waciIssuanceData, error) {
@@ -1483,9 +2112,7 @@ func readWACIIssuanceData(store storage.Store, id string, newID string) (*waciIs
 }
 
 func createOfferCredentialMsg(manifest, responseVP []byte) (*issuecredential.OfferCredentialParams, error) {
-	var credentialManifest cm.CredentialManifest
-
-	err := json.Unmarshal(manifest, &credentialManifest)
+	credentialManifest, err := parseCredentialManifest(manifest)
 	if err != nil {
 		return nil, err
 	}
@@ -1517,7 +2144,7 @@ func createOfferCredentialMsg(manifest, responseVP []byte) (*issuecredential.Off
 					JSON: struct {
 						Manifest cm.CredentialManifest `json:"credential_manifest,omitempty"`
 					}{
-						Manifest: credentialManifest,
+						Manifest: *credentialManifest,
 					},
 				},
 			},
@@ -1592,6 +2219,32 @@ func getWACIIssuanceDataStoreKeyPrefix(key string) string {
 	return fmt.Sprintf("waci_issuance_data_%s", key)
 }
 
+func getVerificationResultKeyPrefix(key string) string {
+	return fmt.Sprintf("verification_result_%s", key)
+}
+
+func getPresentationDefinitionKeyPrefix(key string) string {
+	return fmt.Sprintf("presentation_definition_%s", key)
+}
+
+func getPresentationRequestKeyPrefix(key string) string {
+	return fmt.Sprintf("presentation_request_%s", key)
+}
+
+func getVerifierProfileKeyPrefix(key string) string {
+	return fmt.Sprintf("verifier_profile_%s", key)
+}
+
+// oidcCredentialFormat returns the credential format issued for the format requested from credential endpoint.
+func oidcCredentialFormat(format string) string {
+	switch format {
+	case "jwt", "jwt_vc":
+		return "jwt_vc"
+	default:
+		return "ldp_vc"
+	}
+}
+
 func setOIDCResponseHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store")
@@ -1648,26 +2301,19 @@ func signPresentationWithED25519(vc *verifiable.Presentation) error {
 	return vc.AddLinkedDataProof(ldpContext, jsonld.WithDocumentLoader(ld.NewDefaultDocumentLoader(nil)))
 }
 
-func createResponseVP(response []byte, credential []byte, sign bool) (*verifiable.Presentation, error) {
-	presentation, err := verifiable.NewPresentation()
+// createResponseVP builds credential fulfillment of the credential manifest, which maps manifest's output
+// descriptor to the credential to issue.
+func createResponseVP(manifest []byte, credential []byte, sign bool) (*verifiable.Presentation, error) {
+	credentialManifest, err := parseCredentialManifest(manifest)
 	if err != nil {
 		return nil, err
 	}
 
-	presentation.Context = append(presentation.Context,
-		"https://identity.foundation/credential-manifest/response/v1")
-	presentation.Type = append(presentation.Type, "CredentialResponse")
-
-	presentation.CustomFields = make(map[string]interface{})
-
-	var responseMap map[string]interface{}
-	err = json.Unmarshal(response, &responseMap)
-	if err != nil {
-		return nil, err
+	if len(credentialManifest.OutputDescriptors) != 1 {
+		return nil, fmt.Errorf("credential manifest must have one output descriptor for the credential to issue, got %d",
+			len(credentialManifest.OutputDescriptors))
 	}
 
-	presentation.CustomFields = responseMap
-
 	cred, err := verifiable.ParseCredential(credential, verifiable.WithJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)),
 		verifiable.WithDisabledProofCheck())
 	if err != nil {
@@ -1681,7 +2327,16 @@ func createResponseVP(response []byte, credential []byte, sign bool) (*verifiabl
 		}
 	}
 
-	presentation.AddCredentials(cred)
+	presentation, err := verifiable.NewPresentation(verifiable.WithCredentials(cred))
+	if err != nil {
+		return nil, err
+	}
+
+	presentation, err = cm.PresentCredentialResponse(credentialManifest,
+		cm.WithExistingPresentationForPresentCredentialResponse(presentation))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential fulfillment : %w", err)
+	}
 
 	if sign {
 		err = signPresentationWithED25519(presentation)
@@ -0,0 +1,933 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	issuecredentialclient "github.com/hyperledger/aries-framework-go/pkg/client/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofbandv2"
+	"github.com/hyperledger/aries-framework-go/pkg/client/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messenger"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	presentproofsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/presexch"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/jsonld"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/suite"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/suite/ed25519signature2018"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/context"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWACIShare_ParallelSessions(t *testing.T) {
+	const sessions = 50
+
+	app := newTestAdapterApp(t)
+
+	actionCh := make(chan service.DIDCommAction)
+	defer close(actionCh)
+
+	go app.listenForDIDCommMsg(actionCh)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			pdID := fmt.Sprintf("pd-%d", i)
+
+			invID := startWACIShare(t, app, pdID)
+
+			thID := uuid.NewString()
+			continued := make(chan interface{}, 1)
+
+			actionCh <- service.DIDCommAction{
+				Message: service.DIDCommMsgMap{
+					"@id":   thID,
+					"@type": presentproofsvc.ProposePresentationMsgTypeV2,
+					"~thread": map[string]interface{}{
+						"thid":  thID,
+						"pthid": invID,
+					},
+				},
+				Continue: func(args interface{}) { continued <- args },
+				Stop:     func(err error) { t.Errorf("session %d stopped : %v", i, err) },
+			}
+
+			assert.NotNil(t, <-continued)
+
+			pdBytes, err := app.store.Get(thID)
+			assert.NoError(t, err)
+
+			var pd map[string]interface{}
+			assert.NoError(t, json.Unmarshal(pdBytes, &pd))
+			assert.Equal(t, pdID, pd["id"], "session %d received another session's presentation definition", i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestWACIShare_ConnectionBased(t *testing.T) {
+	app, ctx := newTestAdapterAppWithContext(t)
+
+	didExchClient, err := didexchange.New(ctx)
+	require.NoError(t, err)
+
+	app.agent.DIDExchClient = didExchClient
+
+	recorder, err := connection.NewRecorder(ctx)
+	require.NoError(t, err)
+
+	actionCh := make(chan service.DIDCommAction)
+	defer close(actionCh)
+
+	go app.listenForDIDCommMsg(actionCh)
+
+	invID := startWACIShare(t, app, "pd-v1")
+
+	// DIDComm V1 messages are sent over the connection established by accepting the invitation, without pthid.
+	require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+		ConnectionID: uuid.NewString(),
+		State:        "completed",
+		ThreadID:     uuid.NewString(),
+		InvitationID: invID,
+		MyDID:        "did:example:adapter",
+		TheirDID:     "did:example:wallet",
+	}))
+
+	thID := uuid.NewString()
+	continued := make(chan interface{}, 1)
+
+	actionCh <- service.DIDCommAction{
+		Message: service.DIDCommMsgMap{
+			"@id":     thID,
+			"@type":   presentproofsvc.ProposePresentationMsgTypeV2,
+			"~thread": map[string]interface{}{"thid": thID},
+		},
+		Continue: func(args interface{}) { continued <- args },
+		Stop: func(err error) {
+			t.Errorf("share stopped : %v", err)
+			continued <- nil
+		},
+		Properties: &connectionProperties{myDID: "did:example:adapter", theirDID: "did:example:wallet"},
+	}
+
+	require.NotNil(t, <-continued)
+
+	pdBytes, err := app.store.Get(thID)
+	require.NoError(t, err)
+
+	var pd map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(pdBytes, &pd))
+	require.Equal(t, "pd-v1", pd["id"])
+}
+
+// connectionProperties are properties of DIDComm V1 action, which identify the connection it was received over.
+type connectionProperties struct {
+	myDID    string
+	theirDID string
+}
+
+func (p *connectionProperties) MyDID() string {
+	return p.myDID
+}
+
+func (p *connectionProperties) TheirDID() string {
+	return p.theirDID
+}
+
+func (p *connectionProperties) All() map[string]interface{} {
+	return map[string]interface{}{"myDID": p.myDID, "theirDID": p.theirDID}
+}
+
+func TestWACIIssuance_StateTracking(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	issuanceEvent := func(stateID, msgType, thID, invID string, fields map[string]interface{}) service.StateMsg {
+		msg := service.DIDCommMsgMap{
+			"@id":     uuid.NewString(),
+			"@type":   msgType,
+			"~thread": map[string]interface{}{"thid": thID, "pthid": invID},
+		}
+
+		for k, v := range fields {
+			msg[k] = v
+		}
+
+		return service.StateMsg{
+			ProtocolName: issuecredential.Name,
+			Type:         service.PostState,
+			StateID:      stateID,
+			Msg:          msg,
+		}
+	}
+
+	t.Run("issued and acked", func(t *testing.T) {
+		invID, thID := uuid.NewString(), uuid.NewString()
+
+		require.NoError(t, app.startWACIIssuance(invID))
+
+		for _, msg := range []service.StateMsg{
+			issuanceEvent("proposal-received", issuecredential.ProposeCredentialMsgTypeV2, thID, invID, nil),
+			issuanceEvent("offer-sent", issuecredential.ProposeCredentialMsgTypeV2, thID, invID, nil),
+			issuanceEvent("request-received", issuecredential.RequestCredentialMsgTypeV2, thID, invID, nil),
+			issuanceEvent("credential-issued", issuecredential.RequestCredentialMsgTypeV2, thID, invID, nil),
+			issuanceEvent("done", issuecredential.AckMsgTypeV2, thID, "", nil),
+		} {
+			require.NoError(t, app.handleIssuanceState(msg))
+		}
+
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/issuer/waci-issuance/"+invID+"/state", nil),
+			map[string]string{"id": invID})
+		rr := httptest.NewRecorder()
+
+		app.waciIssuanceState(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var status waciIssuanceStatus
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+		require.Equal(t, thID, status.ThreadID)
+		require.Equal(t, waciIssuanceAcked, status.State)
+
+		var states []waciIssuanceState
+		for _, transition := range status.History {
+			states = append(states, transition.State)
+		}
+
+		require.Equal(t, []waciIssuanceState{
+			waciIssuanceInvited, waciIssuanceProposed, waciIssuanceOffered,
+			waciIssuanceRequested, waciIssuanceIssued, waciIssuanceAcked,
+		}, states)
+	})
+
+	t.Run("declined by holder", func(t *testing.T) {
+		invID, thID := uuid.NewString(), uuid.NewString()
+
+		require.NoError(t, app.startWACIIssuance(invID))
+		require.NoError(t, app.handleIssuanceState(
+			issuanceEvent("proposal-received", issuecredential.ProposeCredentialMsgTypeV2, thID, invID, nil)))
+		require.NoError(t, app.handleIssuanceState(
+			issuanceEvent("offer-sent", issuecredential.ProposeCredentialMsgTypeV2, thID, invID, nil)))
+		require.NoError(t, app.handleIssuanceState(
+			issuanceEvent("abandoning", issuecredential.ProblemReportMsgTypeV2, thID, "",
+				map[string]interface{}{"description": map[string]interface{}{"code": "rejected"}})))
+
+		status, err := app.readWACIIssuanceStatus(thID)
+		require.NoError(t, err)
+		require.Equal(t, waciIssuanceAbandoned, status.State)
+		require.Contains(t, status.Reason, "rejected")
+		require.False(t, status.succeeded())
+	})
+}
+
+func TestWACIIssuance_ConnectionBasedStateTracking(t *testing.T) {
+	app, ctx := newTestAdapterAppWithContext(t)
+
+	didExchClient, err := didexchange.New(ctx)
+	require.NoError(t, err)
+
+	app.agent.DIDExchClient = didExchClient
+
+	recorder, err := connection.NewRecorder(ctx)
+	require.NoError(t, err)
+
+	type session struct {
+		invID    string
+		thID     string
+		theirDID string
+	}
+
+	sessions := make([]*session, 2)
+
+	for i := range sessions {
+		sessions[i] = &session{invID: uuid.NewString(), thID: uuid.NewString(), theirDID: "did:example:wallet-" +
+			uuid.NewString()}
+
+		require.NoError(t, app.startWACIIssuance(sessions[i].invID))
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: uuid.NewString(),
+			State:        "completed",
+			ThreadID:     uuid.NewString(),
+			InvitationID: sessions[i].invID,
+			MyDID:        "did:example:adapter",
+			TheirDID:     sessions[i].theirDID,
+		}))
+	}
+
+	// DIDComm V1 messages have no pthid, the invitation is the one of the connection they were received over.
+	issuanceEvent := func(s *session, stateID, msgType string) service.StateMsg {
+		return service.StateMsg{
+			ProtocolName: issuecredential.Name,
+			Type:         service.PostState,
+			StateID:      stateID,
+			Msg: service.DIDCommMsgMap{
+				"@id":     uuid.NewString(),
+				"@type":   msgType,
+				"~thread": map[string]interface{}{"thid": s.thID},
+			},
+			Properties: &connectionProperties{myDID: "did:example:adapter", theirDID: s.theirDID},
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(sessions))
+
+	for i, s := range sessions {
+		wg.Add(1)
+
+		go func(i int, s *session) {
+			defer wg.Done()
+
+			events := []service.StateMsg{
+				issuanceEvent(s, "proposal-received", issuecredential.ProposeCredentialMsgTypeV2),
+				issuanceEvent(s, "offer-sent", issuecredential.ProposeCredentialMsgTypeV2),
+			}
+
+			// only the first session goes on to issue the credential.
+			if i == 0 {
+				events = append(events,
+					issuanceEvent(s, "request-received", issuecredential.RequestCredentialMsgTypeV2),
+					issuanceEvent(s, "credential-issued", issuecredential.RequestCredentialMsgTypeV2))
+			}
+
+			for _, event := range events {
+				if errs[i] = app.handleIssuanceState(event); errs[i] != nil {
+					return
+				}
+			}
+		}(i, s)
+	}
+
+	wg.Wait()
+
+	for _, e := range errs {
+		require.NoError(t, e)
+	}
+
+	expected := [][]waciIssuanceState{
+		{waciIssuanceInvited, waciIssuanceProposed, waciIssuanceOffered, waciIssuanceRequested, waciIssuanceIssued},
+		{waciIssuanceInvited, waciIssuanceProposed, waciIssuanceOffered},
+	}
+
+	for i, s := range sessions {
+		status, err := app.readWACIIssuanceStatus(s.invID)
+		require.NoError(t, err)
+		require.Equal(t, s.invID, status.InvitationID)
+		require.Equal(t, s.thID, status.ThreadID)
+		require.Equal(t, expected[i][len(expected[i])-1], status.State)
+
+		states := make([]waciIssuanceState, 0, len(status.History))
+		for _, transition := range status.History {
+			states = append(states, transition.State)
+		}
+
+		require.Equal(t, expected[i], states)
+	}
+
+	_, err = app.readWACIIssuanceStatus("")
+	require.Error(t, err, "issuance state was linked under empty invitation ID")
+
+	err = app.handleIssuanceState(service.StateMsg{
+		ProtocolName: issuecredential.Name,
+		Type:         service.PostState,
+		StateID:      "proposal-received",
+		Msg:          service.DIDCommMsgMap{"@id": uuid.NewString(), "@type": issuecredential.ProposeCredentialMsgTypeV2},
+	})
+	require.EqualError(t, err, "failed to find invitation of issue-credential thread")
+}
+
+func TestWACIShare_Connectionless(t *testing.T) {
+	app, ctx := newTestAdapterAppWithContext(t)
+
+	actionCh := make(chan service.DIDCommAction, 1)
+	require.NoError(t, app.agent.PresentProofClient.RegisterActionEvent(actionCh))
+
+	form := url.Values{
+		"walletURL":      {"https://wallet.example.com"},
+		"pEx":            {`{"id":"pd-1","input_descriptors":[]}`},
+		"connectionless": {"true"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/verifier/waci-share", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	app.waciShare(rr, req)
+	require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+
+	var inv outofband.Invitation
+
+	require.NoError(t, json.Unmarshal(resolveWACIInvitation(t, app, rr.Header().Get("Location")), &inv))
+	require.Len(t, inv.Requests, 1)
+
+	attachment, err := inv.Requests[0].Data.Fetch()
+	require.NoError(t, err)
+
+	reqMsg, err := service.ParseDIDCommMsgMap(attachment)
+	require.NoError(t, err)
+	require.Equal(t, presentproofsvc.RequestPresentationMsgTypeV2, reqMsg.Type())
+
+	thID, err := reqMsg.ThreadID()
+	require.NoError(t, err)
+
+	_, err = app.readPresentationRequest(thID)
+	require.NoError(t, err)
+
+	// the wallet answers attached request with presentation on the request's thread.
+	svc, err := ctx.Service(presentproofsvc.Name)
+	require.NoError(t, err)
+
+	_, err = svc.(service.InboundHandler).HandleInbound(service.DIDCommMsgMap{
+		"@id":     uuid.NewString(),
+		"@type":   presentproofsvc.PresentationMsgTypeV2,
+		"~thread": map[string]interface{}{"thid": thID},
+	}, service.NewDIDCommContext("did:example:verifier", "did:example:holder", nil))
+	require.NoError(t, err)
+
+	action := <-actionCh
+
+	actionThID, err := action.Message.ThreadID()
+	require.NoError(t, err)
+	require.Equal(t, thID, actionThID)
+}
+
+func TestWACIIssuance_Connectionless(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	app, ctx := newTestAdapterAppWithContext(t)
+
+	issueCredentialClient, err := issuecredentialclient.New(ctx)
+	require.NoError(t, err)
+
+	oobV2Client, err := outofbandv2.New(ctx)
+	require.NoError(t, err)
+
+	app.agent.IssueCredentialClient, app.agent.OOBV2Client = issueCredentialClient, oobV2Client
+	app.agent.PublicDIDV2 = didKey
+
+	sent := &capturingMessenger{sent: make(chan service.DIDCommMsgMap, 1)}
+	app.agent.Messenger.setMessenger(sent)
+
+	actionCh := make(chan service.DIDCommAction)
+	defer close(actionCh)
+
+	require.NoError(t, app.agent.IssueCredentialClient.RegisterActionEvent(actionCh))
+
+	go app.listenForDIDCommMsg(actionCh)
+
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			form := url.Values{
+				"walletURL":   {"https://wallet.example.com"},
+				"credToIssue": {string(newHolderTestCredential("did:example:holder"))},
+				"credManifest": {`{"id": "manifest-1", "version": "0.1.0", "issuer": {"id": "did:example:issuer"},
+					"output_descriptors": [{"id": "od-1", "schema": "https://example.com/schema"}]}`},
+				"connectionless": {"true"},
+			}
+
+			handler := app.waciIssuance
+			if version == "v2" {
+				handler = app.waciIssuanceV2
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/issuer/waci-issuance", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+
+			handler(rr, req)
+			require.Equal(t, http.StatusFound, rr.Code, rr.Body.String())
+
+			offer := invitationAttachment(t, resolveWACIInvitation(t, app, rr.Header().Get("Location")))
+			require.Contains(t, []string{issuecredential.OfferCredentialMsgTypeV2, issuecredential.OfferCredentialMsgTypeV3},
+				offer.Type())
+
+			thID, err := offer.ThreadID()
+			require.NoError(t, err)
+
+			// the wallet answers attached offer with request on the offer's thread, without proposing first.
+			svc, err := ctx.Service(issuecredential.Name)
+			require.NoError(t, err)
+
+			requestType := issuecredential.RequestCredentialMsgTypeV2
+			if version == "v2" {
+				requestType = issuecredential.RequestCredentialMsgTypeV3
+			}
+
+			_, err = svc.(service.InboundHandler).HandleInbound(service.DIDCommMsgMap{
+				"@id":     uuid.NewString(),
+				"id":      uuid.NewString(),
+				"@type":   requestType,
+				"type":    requestType,
+				"~thread": map[string]interface{}{"thid": thID},
+				"thid":    thID,
+			}, service.NewDIDCommContext("did:example:issuer", "did:example:holder", nil))
+			require.NoError(t, err)
+
+			select {
+			case issued := <-sent.sent:
+				require.Contains(t, issued.Type(), "issue-credential")
+				require.Nil(t, app.readDIDCommFailure(thID))
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "credential was not issued", "failure: %+v", app.readDIDCommFailure(thID))
+			}
+		})
+	}
+}
+
+func TestParseVerifiedPresentation(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	app := newTestAdapterApp(t)
+
+	credential := func(t *testing.T, sign bool) *verifiable.Credential {
+		t.Helper()
+
+		vc, err := verifiable.ParseCredential(newHolderTestCredential("did:example:holder"),
+			verifiable.WithJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)))
+		require.NoError(t, err)
+
+		if sign {
+			require.NoError(t, signCredentialWithED25519(vc))
+		}
+
+		return vc
+	}
+
+	presentation := func(t *testing.T, sign bool, vcs ...*verifiable.Credential) []byte {
+		t.Helper()
+
+		vp, err := verifiable.NewPresentation(verifiable.WithCredentials(vcs...))
+		require.NoError(t, err)
+
+		if sign {
+			require.NoError(t, signPresentationWithED25519(vp))
+		}
+
+		vpBytes, err := vp.MarshalJSON()
+		require.NoError(t, err)
+
+		return vpBytes
+	}
+
+	t.Run("signed presentation of signed credentials", func(t *testing.T) {
+		vp, err := app.parseVerifiedPresentation(presentation(t, true, credential(t, true)))
+		require.NoError(t, err)
+		require.Len(t, vp.Credentials(), 1)
+	})
+
+	t.Run("unsigned presentation", func(t *testing.T) {
+		_, err := app.parseVerifiedPresentation(presentation(t, false, credential(t, true)))
+		require.EqualError(t, err, "presentation is missing proof")
+	})
+
+	t.Run("unsigned credential", func(t *testing.T) {
+		_, err := app.parseVerifiedPresentation(presentation(t, true, credential(t, false)))
+		require.EqualError(t, err, "credential 0 is missing proof")
+	})
+
+	t.Run("credential changed after signing", func(t *testing.T) {
+		vc := credential(t, true)
+		vc.Issuer.ID = "did:example:other"
+
+		_, err := app.parseVerifiedPresentation(presentation(t, true, vc))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid credential 0")
+	})
+}
+
+func TestVerifyDIDCommPresentation(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	app := newTestAdapterApp(t)
+
+	const (
+		anyCredentialPD = `{"id": "pd-1", "input_descriptors": [{"id": "any",
+			"schema": [{"uri": "https://www.w3.org/2018/credentials#VerifiableCredential"}]}]}`
+		licensePD = `{"id": "pd-2", "input_descriptors": [{"id": "license",
+			"schema": [{"uri": "https://www.w3.org/2018/credentials#VerifiableCredential"}],
+			"constraints": {"fields": [{"path": ["$.credentialSubject.licenseNumber"]}]}}]}`
+	)
+
+	startShare := func(t *testing.T, pd string) (string, *presentationRequest) {
+		t.Helper()
+
+		thID := uuid.NewString()
+		presReq := &presentationRequest{Challenge: uuid.NewString(), Domain: uuid.NewString()}
+
+		require.NoError(t, app.saveWACIShareData(thID, []byte(pd), defaultVerifierProfile, presReq))
+
+		return thID, presReq
+	}
+
+	// presentationMsg returns presentation message of credential submitted for any credential definition, with
+	// proof of given challenge and domain.
+	presentationMsg := func(t *testing.T, challenge, domain string) service.DIDCommMsgMap {
+		t.Helper()
+
+		docLoader := ld.NewDefaultDocumentLoader(nil)
+
+		vc, err := verifiable.ParseCredential(newHolderTestCredential("did:example:holder"),
+			verifiable.WithJSONLDDocumentLoader(docLoader))
+		require.NoError(t, err)
+		require.NoError(t, signCredentialWithED25519(vc))
+
+		var pd presexch.PresentationDefinition
+
+		require.NoError(t, json.Unmarshal([]byte(anyCredentialPD), &pd))
+
+		vp, err := pd.CreateVP([]*verifiable.Credential{vc}, docLoader, verifiable.WithDisabledProofCheck(),
+			verifiable.WithJSONLDDocumentLoader(docLoader))
+		require.NoError(t, err)
+
+		created := time.Now()
+
+		require.NoError(t, vp.AddLinkedDataProof(&verifiable.LinkedDataProofContext{
+			SignatureType:           "Ed25519Signature2018",
+			SignatureRepresentation: verifiable.SignatureProofValue,
+			Suite: ed25519signature2018.New(suite.WithSigner(
+				&edd25519Signer{ed25519.PrivateKey(base58.Decode(pkBase58))})),
+			VerificationMethod: kid,
+			Purpose:            "authentication",
+			Created:            &created,
+			Challenge:          challenge,
+			Domain:             domain,
+		}, jsonld.WithDocumentLoader(docLoader)))
+
+		return service.NewDIDCommMsgMap(presentproofsvc.PresentationV2{
+			Type: presentproofsvc.PresentationMsgTypeV2,
+			PresentationsAttach: []decorator.Attachment{{
+				ID:       uuid.NewString(),
+				MimeType: "application/ld+json",
+				Data:     decorator.AttachmentData{JSON: vp},
+			}},
+		})
+	}
+
+	t.Run("presentation for the request", func(t *testing.T) {
+		thID, presReq := startShare(t, anyCredentialPD)
+
+		result, err := app.verifyDIDCommPresentation(presentationMsg(t, presReq.Challenge, presReq.Domain), thID)
+		require.NoError(t, err)
+		require.True(t, result.Verified, result.Error)
+	})
+
+	tests := []struct {
+		name   string
+		pd     string
+		msg    func(presReq *presentationRequest) service.DIDCommMsgMap
+		result string
+	}{
+		{
+			name: "wrong challenge",
+			pd:   anyCredentialPD,
+			msg: func(presReq *presentationRequest) service.DIDCommMsgMap {
+				return presentationMsg(t, uuid.NewString(), presReq.Domain)
+			},
+			result: "presentation proof challenge does not match the request",
+		},
+		{
+			name: "missing challenge",
+			pd:   anyCredentialPD,
+			msg: func(presReq *presentationRequest) service.DIDCommMsgMap {
+				return presentationMsg(t, "", presReq.Domain)
+			},
+			result: "presentation proof challenge does not match the request",
+		},
+		{
+			name: "domain mismatch",
+			pd:   anyCredentialPD,
+			msg: func(presReq *presentationRequest) service.DIDCommMsgMap {
+				return presentationMsg(t, presReq.Challenge, "https://other.example.com")
+			},
+			result: "presentation proof domain does not match the request",
+		},
+		{
+			name: "presentation not matching definition",
+			pd:   licensePD,
+			msg: func(presReq *presentationRequest) service.DIDCommMsgMap {
+				return presentationMsg(t, presReq.Challenge, presReq.Domain)
+			},
+			result: "presentation does not match presentation definition",
+		},
+		{
+			name: "message without presentation",
+			pd:   anyCredentialPD,
+			msg: func(*presentationRequest) service.DIDCommMsgMap {
+				return service.NewDIDCommMsgMap(presentproofsvc.PresentationV2{Type: presentproofsvc.PresentationMsgTypeV2})
+			},
+			result: "invalid presentation : presentation message is missing attachments",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thID, presReq := startShare(t, test.pd)
+
+			result, err := app.verifyDIDCommPresentation(test.msg(presReq), thID)
+			require.NoError(t, err)
+			require.False(t, result.Verified)
+			require.Contains(t, result.Error, test.result)
+		})
+	}
+
+	t.Run("unknown thread", func(t *testing.T) {
+		_, err := app.verifyDIDCommPresentation(presentationMsg(t, "", ""), uuid.NewString())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to read presentation request")
+	})
+}
+
+func TestCheckPresentationChallenge(t *testing.T) {
+	presReq := &presentationRequest{Challenge: "challenge-1", Domain: "https://verifier.example.com"}
+
+	jwtPresentation := func(claims map[string]interface{}) *verifiable.Presentation {
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+
+		return &verifiable.Presentation{
+			JWT: "eyJhbGciOiJFZERTQSJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl",
+		}
+	}
+
+	tests := []struct {
+		name string
+		vp   *verifiable.Presentation
+		err  string
+	}{
+		{
+			name: "proof for the request",
+			vp: &verifiable.Presentation{Proofs: []verifiable.Proof{
+				{"challenge": "challenge-1", "domain": "https://verifier.example.com"},
+			}},
+		},
+		{
+			name: "one of proofs for another challenge",
+			vp: &verifiable.Presentation{Proofs: []verifiable.Proof{
+				{"challenge": "challenge-1", "domain": "https://verifier.example.com"},
+				{"challenge": "challenge-2", "domain": "https://verifier.example.com"},
+			}},
+			err: "presentation proof challenge does not match the request",
+		},
+		{
+			name: "proof without challenge",
+			vp:   &verifiable.Presentation{Proofs: []verifiable.Proof{{"domain": "https://verifier.example.com"}}},
+			err:  "presentation proof challenge does not match the request",
+		},
+		{
+			name: "proof for another domain",
+			vp: &verifiable.Presentation{Proofs: []verifiable.Proof{
+				{"challenge": "challenge-1", "domain": "https://other.example.com"},
+			}},
+			err: "presentation proof domain does not match the request",
+		},
+		{
+			name: "presentation without proof",
+			vp:   &verifiable.Presentation{},
+			err:  "presentation is missing proof",
+		},
+		{
+			name: "JWT for the request",
+			vp:   jwtPresentation(map[string]interface{}{"nonce": "challenge-1", "aud": "https://verifier.example.com"}),
+		},
+		{
+			name: "JWT with audience list",
+			vp: jwtPresentation(map[string]interface{}{
+				"nonce": "challenge-1", "aud": []string{"https://other.example.com", "https://verifier.example.com"},
+			}),
+		},
+		{
+			name: "JWT with another nonce",
+			vp:   jwtPresentation(map[string]interface{}{"nonce": "challenge-2", "aud": "https://verifier.example.com"}),
+			err:  "presentation JWT nonce or audience does not match the request",
+		},
+		{
+			name: "JWT without audience",
+			vp:   jwtPresentation(map[string]interface{}{"nonce": "challenge-1"}),
+			err:  "presentation JWT nonce or audience does not match the request",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkPresentationChallenge(test.vp, presReq)
+			if test.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+// startWACIShare calls WACI share endpoint and returns ID of the OOB invitation in wallet redirect.
+// Safe to call from goroutines other than the test's one.
+func startWACIShare(t *testing.T, app *adapterApp, pdID string) string {
+	t.Helper()
+
+	form := url.Values{
+		"walletURL": {"https://wallet.example.com"},
+		"pEx":       {fmt.Sprintf(`{"id":"%s","input_descriptors":[]}`, pdID)},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/verifier/waci-share", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+
+	app.waciShare(rr, req)
+
+	if !assert.Equal(t, http.StatusFound, rr.Code, rr.Body.String()) {
+		return ""
+	}
+
+	var inv outofband.Invitation
+
+	assert.NoError(t, json.Unmarshal(resolveWACIInvitation(t, app, rr.Header().Get("Location")), &inv))
+
+	return inv.ID
+}
+
+// resolveWACIInvitation fetches invitation by its short URL, as a wallet scanning QR code would.
+func resolveWACIInvitation(t *testing.T, app *adapterApp, invitationURL string) []byte {
+	t.Helper()
+
+	invitationURL = strings.TrimPrefix(invitationURL, app.cfg.ExternalURL)
+
+	if !assert.True(t, strings.HasPrefix(invitationURL, oobInvitationPath), invitationURL) {
+		return nil
+	}
+
+	req := httptest.NewRequest(http.MethodGet, invitationURL, nil)
+	req.Header.Set("Accept", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": strings.TrimPrefix(invitationURL, oobInvitationPath)})
+
+	rr := httptest.NewRecorder()
+
+	app.oobInvitation(rr, req)
+
+	if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+		return nil
+	}
+
+	return rr.Body.Bytes()
+}
+
+// invitationAttachment returns the protocol message attached to OOB invitation of either DIDComm version.
+func invitationAttachment(t *testing.T, invitation []byte) service.DIDCommMsgMap {
+	t.Helper()
+
+	var inv struct {
+		RequestsV1 []*decorator.Attachment   `json:"request~attach"`
+		RequestsV2 []*decorator.AttachmentV2 `json:"attachments"`
+	}
+
+	require.NoError(t, json.Unmarshal(invitation, &inv))
+
+	var data decorator.AttachmentData
+
+	switch {
+	case len(inv.RequestsV1) == 1:
+		data = inv.RequestsV1[0].Data
+	case len(inv.RequestsV2) == 1:
+		data = inv.RequestsV2[0].Data
+	default:
+		require.FailNow(t, "invitation without attachment", string(invitation))
+	}
+
+	msgBytes, err := data.Fetch()
+	require.NoError(t, err)
+
+	msg, err := service.ParseDIDCommMsgMap(msgBytes)
+	require.NoError(t, err)
+
+	return msg
+}
+
+func newTestAdapterConfig() *adapterConfig {
+	return &adapterConfig{
+		ExternalURL:         "https://adapter.example.com",
+		DIDCommExternalHost: "https://adapter.example.com:8095",
+		StatusListCacheTTL:  defaultStatusListCacheTTL,
+		DatabaseType:        databaseTypeMemOption,
+		DIDCommV2DIDMethod:  didMethodWebOption,
+	}
+}
+
+func newTestAdapterApp(t *testing.T) *adapterApp {
+	t.Helper()
+
+	app, _ := newTestAdapterAppWithContext(t)
+
+	return app
+}
+
+func newTestAdapterAppWithContext(t *testing.T) (*adapterApp, *context.Provider) {
+	t.Helper()
+
+	recorder := &recordingMessenger{inspector: newAgentInspector()}
+
+	framework, err := aries.New(aries.WithStoreProvider(mem.NewProvider()), aries.WithMessengerHandler(recorder))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, framework.Close()) })
+
+	ctx, err := framework.Context()
+	require.NoError(t, err)
+
+	msgr, err := messenger.NewMessenger(ctx)
+	require.NoError(t, err)
+
+	recorder.setMessenger(msgr)
+
+	oobClient, err := outofband.New(ctx)
+	require.NoError(t, err)
+
+	presentProofClient, err := presentproof.New(ctx)
+	require.NoError(t, err)
+
+	app, err := newAdapterApp(&didComm{
+		OOBClient:          oobClient,
+		PresentProofClient: presentProofClient,
+		VDRegistry:         ctx.VDRegistry(),
+		Messenger:          recorder,
+	}, mem.NewProvider(), newTestAdapterConfig())
+	require.NoError(t, err)
+
+	return app, ctx
+}
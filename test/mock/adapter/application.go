@@ -0,0 +1,182 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/cm"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/presexch"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/piprate/json-gold/ld"
+)
+
+var (
+	// errCredentialApplicationRequired is returned when credential manifest has presentation definition, but the
+	// holder did not send credential application.
+	errCredentialApplicationRequired = errors.New("credential application is required by credential manifest")
+	// errCredentialApplicationRejected is returned when credential application does not meet credential manifest.
+	errCredentialApplicationRejected = errors.New("credential application rejected")
+)
+
+// checkCredentialApplication validates the holder's credential application, when the session's credential manifest
+// has presentation definition. The application can come with propose-credential or request-credential, and is
+// required by the time credential is requested.
+func (v *adapterApp) checkCredentialApplication(msg service.DIDCommMsg, thID string, waciData *waciIssuanceData,
+	required bool) error {
+	if waciData.CredentialFormat == credentialFormatLDProof {
+		return nil
+	}
+
+	manifest, err := parseCredentialManifest(waciData.CredentialManifest)
+	if err != nil {
+		return err
+	}
+
+	if manifest.PresentationDefinition == nil {
+		return nil
+	}
+
+	applicationBytes, err := attachmentByFormat(msg, cm.CredentialApplicationAttachmentFormat)
+	if err != nil {
+		return err
+	}
+
+	if applicationBytes == nil {
+		if !required {
+			return nil
+		}
+
+		if _, err = v.store.Get(getCredentialApplicationKeyPrefix(thID)); err == nil {
+			return nil
+		}
+
+		return errCredentialApplicationRequired
+	}
+
+	err = v.validateCredentialApplication(applicationBytes, manifest)
+	if err != nil {
+		return fmt.Errorf("%w : %s", errCredentialApplicationRejected, err)
+	}
+
+	return v.store.Put(getCredentialApplicationKeyPrefix(thID), applicationBytes)
+}
+
+// validateCredentialApplication checks that the application is made for the manifest, and that its presentation
+// submission satisfies the manifest's presentation definition.
+func (v *adapterApp) validateCredentialApplication(applicationBytes []byte, manifest *cm.CredentialManifest) error {
+	var envelope struct {
+		Application *rawCredentialApplication `json:"credential_application"`
+	}
+
+	err := json.Unmarshal(applicationBytes, &envelope)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal credential application : %w", err)
+	}
+
+	if envelope.Application == nil {
+		return errors.New("missing 'credential_application'")
+	}
+
+	application := cm.CredentialApplication(*envelope.Application)
+
+	err = application.ValidateAgainstCredentialManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	keyFetcher := verifiable.NewVDRKeyResolver(v.agent.VDRegistry).PublicKeyFetcher()
+	docLoader := ld.NewDefaultDocumentLoader(nil)
+
+	vp, err := verifiable.ParsePresentation(applicationBytes, verifiable.WithPresPublicKeyFetcher(keyFetcher),
+		verifiable.WithPresJSONLDDocumentLoader(docLoader))
+	if err != nil {
+		return fmt.Errorf("invalid credential application presentation : %w", err)
+	}
+
+	_, err = manifest.PresentationDefinition.Match(vp, docLoader, presexch.WithCredentialOptions(
+		verifiable.WithPublicKeyFetcher(keyFetcher), verifiable.WithJSONLDDocumentLoader(docLoader)))
+	if err != nil {
+		return fmt.Errorf("presentation submission does not match presentation definition : %w", err)
+	}
+
+	return nil
+}
+
+// rawCredentialManifest and rawCredentialApplication decode cm types without their UnmarshalJSON methods, which recurse
+// infinitely with newer encoding/json. Decoded values are validated explicitly.
+type (
+	rawCredentialManifest    cm.CredentialManifest
+	rawCredentialApplication cm.CredentialApplication
+)
+
+// parseCredentialManifest unmarshals and validates credential manifest.
+func parseCredentialManifest(manifestBytes []byte) (*cm.CredentialManifest, error) {
+	var decoded rawCredentialManifest
+
+	err := json.Unmarshal(manifestBytes, &decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credential manifest : %w", err)
+	}
+
+	manifest := cm.CredentialManifest(decoded)
+
+	err = manifest.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential manifest : %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// attachmentByFormat returns data of issue-credential message's attachment in the given format, or nil if the
+// message has no such attachment.
+func attachmentByFormat(msg service.DIDCommMsg, format string) ([]byte, error) {
+	var raw struct {
+		Formats        []issuecredential.Format `json:"formats,omitempty"`
+		FiltersAttach  []decorator.Attachment   `json:"filters~attach,omitempty"`
+		RequestsAttach []decorator.Attachment   `json:"requests~attach,omitempty"`
+		Attachments    []decorator.AttachmentV2 `json:"attachments,omitempty"`
+	}
+
+	err := msg.Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s message : %w", msg.Type(), err)
+	}
+
+	formats := map[string]string{}
+	for _, f := range raw.Formats {
+		formats[f.AttachID] = f.Format
+	}
+
+	attachments := decorator.V1AttachmentsToGeneric(append(raw.FiltersAttach, raw.RequestsAttach...))
+	attachments = append(attachments, decorator.V2AttachmentsToGeneric(raw.Attachments)...)
+
+	for _, attachment := range attachments {
+		if attachment.Format != format && formats[attachment.ID] != format {
+			continue
+		}
+
+		data, err := attachment.Data.Fetch()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s attachment : %w", format, err)
+		}
+
+		return data, nil
+	}
+
+	return nil, nil
+}
+
+func getCredentialApplicationKeyPrefix(key string) string {
+	return fmt.Sprintf("credential_application_%s", key)
+}
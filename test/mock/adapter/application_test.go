@@ -0,0 +1,87 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/cm"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestWithPD = `{
+	"id": "manifest-1",
+	"issuer": {"id": "did:example:issuer"},
+	"output_descriptors": [{"id": "prc_output", "schema": "https://w3id.org/citizenship/v1"}],
+	"presentation_definition": {
+		"id": "pd-1",
+		"input_descriptors": [{"id": "drivers_license", "schema": [{"uri": "https://example.org/dl/v1"}]}]
+	}
+}`
+
+func TestCheckCredentialApplication(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	requestMsg := func(application interface{}) service.DIDCommMsgMap {
+		msg := service.DIDCommMsgMap{
+			"@id":   uuid.NewString(),
+			"@type": issuecredential.RequestCredentialMsgTypeV2,
+		}
+
+		if application != nil {
+			msg["formats"] = []interface{}{map[string]interface{}{
+				"attach_id": "a1", "format": cm.CredentialApplicationAttachmentFormat,
+			}}
+			msg["requests~attach"] = []interface{}{map[string]interface{}{
+				"@id": "a1", "data": map[string]interface{}{"json": application},
+			}}
+		}
+
+		return msg
+	}
+
+	t.Run("manifest without presentation definition", func(t *testing.T) {
+		waciData := &waciIssuanceData{
+			CredentialManifest: json.RawMessage(`{"id":"manifest-2","issuer":{"id":"did:example:issuer"},` +
+				`"output_descriptors":[{"id":"prc_output","schema":"https://w3id.org/citizenship/v1"}]}`),
+		}
+
+		require.NoError(t, app.checkCredentialApplication(requestMsg(nil), uuid.NewString(), waciData, true))
+	})
+
+	waciData := &waciIssuanceData{CredentialManifest: json.RawMessage(testManifestWithPD)}
+
+	t.Run("application is optional with proposal", func(t *testing.T) {
+		require.NoError(t, app.checkCredentialApplication(requestMsg(nil), uuid.NewString(), waciData, false))
+	})
+
+	t.Run("application is required with request", func(t *testing.T) {
+		err := app.checkCredentialApplication(requestMsg(nil), uuid.NewString(), waciData, true)
+		require.ErrorIs(t, err, errCredentialApplicationRequired)
+	})
+
+	t.Run("application for another manifest", func(t *testing.T) {
+		err := app.checkCredentialApplication(requestMsg(map[string]interface{}{
+			"@context": []string{"https://www.w3.org/2018/credentials/v1"},
+			"type":     []string{"VerifiablePresentation", "CredentialApplication"},
+			"credential_application": map[string]interface{}{
+				"id": "application-1", "manifest_id": "manifest-3",
+			},
+		}), uuid.NewString(), waciData, true)
+		require.ErrorIs(t, err, errCredentialApplicationRejected)
+		require.Contains(t, err.Error(), "manifest")
+	})
+
+	t.Run("ld-proof format does not use manifest", func(t *testing.T) {
+		require.NoError(t, app.checkCredentialApplication(requestMsg(nil), uuid.NewString(),
+			&waciIssuanceData{CredentialFormat: credentialFormatLDProof}, true))
+	})
+}
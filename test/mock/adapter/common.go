@@ -78,6 +78,18 @@ func handleError(w http.ResponseWriter, statusCode int, msg string) {
 	}
 }
 
+// parseForm parses form of the request, answering it with 400 if the form is malformed.
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseForm()
+	if err != nil {
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse form : %s", err))
+
+		return false
+	}
+
+	return true
+}
+
 // ErrorResponse to send error message in the response.
 type ErrorResponse struct {
 	Message string `json:"errMessage,omitempty"`
@@ -0,0 +1,286 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/spf13/cobra"
+	"github.com/trustbloc/wallet/test/mock/common/config"
+	"github.com/trustbloc/wallet/test/mock/common/tlsmode"
+)
+
+// adapterConfig is the configuration of the adapter, validated at startup.
+type adapterConfig struct {
+	Port                  string
+	ExternalURL           string
+	DIDCommInternalHost   string
+	DIDCommExternalHost   string
+	DIDCommWSInternalHost string
+	DIDCommWSExternalHost string
+	TLSMode               string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSHostnames          []string
+	TLSAutoDir            string
+	TLSCACerts            []string
+	OrbDomain             string
+	ContextProviderURL    string
+	KeyType               kms.KeyType
+	KeyAgreementType      kms.KeyType
+	StatusListCacheTTL    time.Duration
+	VerifierProfilesFile  string
+	DatabaseType          string
+	DatabaseURL           string
+	DatabasePrefix        string
+	DIDCommV2DIDMethod    string
+	RecordDir             string
+	ReplayFile            string
+
+	settings []*config.Setting
+}
+
+func (c *adapterConfig) options() []*config.Option {
+	return []*config.Option{
+		{
+			Name: demoPortFlagName, Env: demoPortEnvKey, Required: true,
+			Usage: "Port to serve the adapter's HTTPS endpoints on.",
+			Set:   config.Port(&c.Port),
+		},
+		{
+			Name: demoExternalURLFlagName, Env: demoExternalURLEnvKey, LegacyEnv: legacyExternalURLEnvKey, Required: true,
+			Usage: "External URL of the adapter, used in redirects, invitations and did:web.",
+			Set:   config.URL(&c.ExternalURL, "http", "https"),
+		},
+		{
+			Name: didCommInternalHostFlagName, Env: didCommInternalHostEnvKey, Required: true,
+			Usage: "Address (host:port) to listen on for DIDComm messages over HTTP.",
+			Set:   config.HostPort(&c.DIDCommInternalHost),
+		},
+		{
+			Name: didCommExternalHostFlagName, Env: didCommExternalHostEnvKey, Required: true,
+			Usage: "External URL of the DIDComm HTTP endpoint.",
+			Set:   config.URL(&c.DIDCommExternalHost, "http", "https"),
+		},
+		{
+			Name: didCommWSInternalHostFlagName, Env: didCommWSInternalHostEnvKey,
+			Usage: "Address (host:port) to listen on for DIDComm messages over WebSocket.",
+			Set:   config.HostPort(&c.DIDCommWSInternalHost),
+		},
+		{
+			Name: didCommWSExternalHostFlagName, Env: didCommWSExternalHostEnvKey,
+			Usage: "External URL of the DIDComm WebSocket endpoint.",
+			Set:   config.URL(&c.DIDCommWSExternalHost, "ws", "wss"),
+		},
+		{
+			Name: tlsModeFlagName, Env: tlsModeEnvKey, Default: tlsmode.File,
+			Usage: fmt.Sprintf("TLS mode of the HTTPS and DIDComm endpoints: %s serves plain HTTP, %s uses the "+
+				"certificate files and %s generates certificate from a local CA.", tlsmode.Plain, tlsmode.File,
+				tlsmode.Auto),
+			Set: config.OneOf(&c.TLSMode, tlsmode.Plain, tlsmode.File, tlsmode.Auto),
+		},
+		{
+			Name: tlsCertFileFlagName, Env: tlsCertFileEnvKey,
+			Usage: "TLS certificate file of the HTTPS and DIDComm endpoints, required in file TLS mode. Changes are " +
+				"picked up by the HTTPS endpoint, DIDComm endpoints read the file at startup only.",
+			Set: config.String(&c.TLSCertFile),
+		},
+		{
+			Name: tlsKeyFileFlagName, Env: tlsKeyFileEnvKey,
+			Usage: "TLS private key file of the HTTPS and DIDComm endpoints, required in file TLS mode. Changes are " +
+				"picked up by the HTTPS endpoint, DIDComm endpoints read the file at startup only.",
+			Set: config.String(&c.TLSKeyFile),
+		},
+		{
+			Name: tlsHostnamesFlagName, Env: tlsHostnamesEnvKey,
+			Usage: "Comma-separated list of hostnames and IPs of the certificate generated in auto TLS mode. " +
+				"Defaults to hosts of the external URLs and localhost.",
+			Set: config.List(&c.TLSHostnames),
+		},
+		{
+			Name: tlsAutoDirFlagName, Env: tlsAutoDirEnvKey, Default: tlsmode.DefaultAutoDir,
+			Usage: "Directory of the local CA and certificate generated in auto TLS mode.",
+			Set:   config.String(&c.TLSAutoDir),
+		},
+		{
+			Name: tlsCACertsFlagName, Env: tlsCACertsEnvKey,
+			Usage: "Comma-separated list of CA certificate files.",
+			Set:   config.List(&c.TLSCACerts),
+		},
+		{
+			Name: orbDomainFlagName, Env: orbDomainEnvKey,
+			Usage: "URL of the orb domain used to create and resolve did:orb.",
+			Set:   config.URL(&c.OrbDomain, "http", "https"),
+		},
+		{
+			Name: contextProviderFlagName, Env: contextProviderEnvKey,
+			Usage: "URL of the JSON-LD context provider.",
+			Set:   config.URL(&c.ContextProviderURL, "http", "https"),
+		},
+		{
+			Name: keyTypeFlagName, Env: keyTypeEnvKey,
+			Usage: "Key type of the agent's authentication keys.",
+			Set:   setKeyType(&c.KeyType, keyTypes),
+		},
+		{
+			Name: keyAgreementTypeFlagName, Env: keyAgreementTypeEnvKey,
+			Usage: "Key type of the agent's key agreement keys.",
+			Set:   setKeyType(&c.KeyAgreementType, keyAgreementTypes),
+		},
+		{
+			Name: statusListCacheTTLFlagName, Env: statusListCacheTTLEnvKey,
+			Default: defaultStatusListCacheTTL.String(),
+			Usage:   "How long fetched status list credentials are cached, e.g. 30s or 5m.",
+			Set:     config.Duration(&c.StatusListCacheTTL),
+		},
+		{
+			Name: verifierProfilesFileFlagName, Env: verifierProfilesFileEnvKey,
+			Usage: "JSON file with verifier profiles.",
+			Set:   config.String(&c.VerifierProfilesFile),
+		},
+		{
+			Name: databaseTypeFlagName, Env: databaseTypeEnvKey, Default: databaseTypeMemOption,
+			Usage: fmt.Sprintf("Database type, one of %s, %s, %s.", databaseTypeMemOption, databaseTypeLevelDBOption,
+				databaseTypeMongoDBOption),
+			Set: config.OneOf(&c.DatabaseType, databaseTypeMemOption, databaseTypeLevelDBOption,
+				databaseTypeMongoDBOption),
+		},
+		{
+			Name: databaseURLFlagName, Env: databaseURLEnvKey,
+			Usage: "Database URL, or directory of the leveldb database.",
+			Set:   config.String(&c.DatabaseURL),
+		},
+		{
+			Name: databasePrefixFlagName, Env: databasePrefixEnvKey,
+			Usage: "Prefix of the database names.",
+			Set:   config.String(&c.DatabasePrefix),
+		},
+		{
+			Name: didCommV2DIDMethodFlagName, Env: didCommV2DIDMethodEnvKey, Default: didMethodOrbOption,
+			Usage: fmt.Sprintf("DID method of the adapter's DIDComm V2 DID, one of %s, %s. Use %s to run without an Orb"+
+				" domain, the DID document is then served by the adapter under its external URL.", didMethodOrbOption,
+				didMethodWebOption, didMethodWebOption),
+			Set: setDIDV2Method(&c.DIDCommV2DIDMethod),
+		},
+		{
+			Name: recordDirFlagName, Env: recordDirEnvKey,
+			Usage: "Directory to record sessions in, one file per session with every response the adapter sent.",
+			Set:   config.String(&c.RecordDir),
+		},
+		{
+			Name: replayFileFlagName, Env: replayFileEnvKey,
+			Usage: "Recorded session, or session transcript in JSON, to replay the responses of.",
+			Set:   config.String(&c.ReplayFile),
+		},
+	}
+}
+
+func (c *adapterConfig) addFlags(cmd *cobra.Command) {
+	config.AddFlags(cmd, c.options())
+}
+
+// load resolves and validates all options, reporting every invalid or missing value at once.
+func (c *adapterConfig) load(cmd *cobra.Command) error {
+	settings, errs, err := config.Load(cmd, c.options())
+	if err != nil {
+		return err
+	}
+
+	c.settings = settings
+
+	for _, setting := range settings {
+		if setting.Legacy {
+			logger.Warnf("%s is deprecated, use %s instead", legacyExternalURLEnvKey, demoExternalURLEnvKey)
+		}
+	}
+
+	if (c.DIDCommWSInternalHost == "") != (c.DIDCommWSExternalHost == "") {
+		errs = append(errs, fmt.Sprintf("%s and %s must be set together", didCommWSInternalHostFlagName,
+			didCommWSExternalHostFlagName))
+	}
+
+	if c.TLSMode == tlsmode.File && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Sprintf("%s and %s are required for %s '%s'", tlsCertFileFlagName,
+			tlsKeyFileFlagName, tlsModeFlagName, tlsmode.File))
+	}
+
+	if c.TLSMode != tlsmode.File && (c.TLSCertFile != "" || c.TLSKeyFile != "") {
+		errs = append(errs, fmt.Sprintf("%s and %s can only be used with %s '%s'", tlsCertFileFlagName,
+			tlsKeyFileFlagName, tlsModeFlagName, tlsmode.File))
+	}
+
+	if c.DatabaseType == databaseTypeMongoDBOption && c.DatabaseURL == "" {
+		errs = append(errs, fmt.Sprintf("%s is required for database type '%s'", databaseURLFlagName,
+			databaseTypeMongoDBOption))
+	}
+
+	if c.RecordDir != "" && c.ReplayFile != "" {
+		errs = append(errs, fmt.Sprintf("%s and %s can't be used together", recordDirFlagName, replayFileFlagName))
+	}
+
+	return config.Invalid(errs)
+}
+
+// autoTLSHostnames returns hostnames of the certificate generated in auto TLS mode.
+func (c *adapterConfig) autoTLSHostnames() []string {
+	if len(c.TLSHostnames) > 0 {
+		return c.TLSHostnames
+	}
+
+	hostnames := []string{}
+
+	for _, rawURL := range []string{c.ExternalURL, c.DIDCommExternalHost, c.DIDCommWSExternalHost, "https://localhost"} {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Hostname() == "" || contains(hostnames, u.Hostname()) {
+			continue
+		}
+
+		hostnames = append(hostnames, u.Hostname())
+	}
+
+	return append(hostnames, "127.0.0.1")
+}
+
+// redacted returns the effective config with passwords in URLs redacted, one setting per line.
+func (c *adapterConfig) redacted() string {
+	return config.Redacted(c.settings)
+}
+
+func setKeyType(target *kms.KeyType, types map[string]kms.KeyType) func(string) error {
+	return func(value string) error {
+		keyType, ok := types[value]
+		if !ok {
+			names := make([]string, 0, len(types))
+			for name := range types {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			return fmt.Errorf("expected one of %s", strings.Join(names, ", "))
+		}
+
+		*target = keyType
+
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
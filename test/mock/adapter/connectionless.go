@@ -0,0 +1,143 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+)
+
+// invitationAttachmentDIDPrefix marks the recipient of a protocol message which is attached to OOB invitation
+// instead of being sent.
+//
+// aries protocol clients create the first message of a protocol only by sending it over a connection, and there's
+// no connection yet when the message goes into the invitation. The message is therefore "sent" to a placeholder
+// connection whose DID has this prefix, and recordingMessenger.Send keeps it instead of handing it to the outbound
+// dispatcher. A URN is used, so that the placeholder can't resolve nor collide with a real DID, and a message which
+// ever slipped past the messenger would fail to send rather than reach anyone. The protocol service keeps state on
+// the message's thread, and the wallet's reply, coming from its own DID, is matched to it by thread ID alone.
+const invitationAttachmentDIDPrefix = "urn:waci:oob-attachment:"
+
+// isConnectionless tells whether WACI invitation should carry the first protocol message, so that the wallet
+// answers it directly instead of proposing presentation or credential first.
+func isConnectionless(r *http.Request) bool {
+	connectionless, _ := strconv.ParseBool(r.FormValue("connectionless")) //nolint:errcheck
+
+	return connectionless
+}
+
+// attachPresentationRequest creates request-presentation to be attached to WACI share invitation, and saves what
+// is needed to verify the presentation which the wallet sends back on the request's thread.
+func (v *adapterApp) attachPresentationRequest(pdBytes []byte, profileID string,
+	didCommVersion service.Version) (string, service.DIDCommMsgMap, error) {
+	reqPresentation, presReq, err := newWACIPresentationRequest(pdBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	thID, msg, err := v.captureInvitationAttachment(didCommVersion, func(conn *connection.Record) (string, error) {
+		return v.agent.PresentProofClient.SendRequestPresentation(reqPresentation, conn)
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create request presentation : %w", err)
+	}
+
+	err = v.saveWACIShareData(thID, pdBytes, profileID, presReq)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return thID, msg, nil
+}
+
+// attachCredentialOffer creates offer-credential to be attached to WACI issuance invitation, and returns the
+// offer's thread ID which is linked to the invitation once the invitation is created.
+func (v *adapterApp) attachCredentialOffer(waciData *waciIssuanceData,
+	didCommVersion service.Version) (string, service.DIDCommMsgMap, error) {
+	offer, err := newWACICredentialOffer(waciData)
+	if err != nil {
+		return "", nil, err
+	}
+
+	thID, msg, err := v.captureInvitationAttachment(didCommVersion, func(conn *connection.Record) (string, error) {
+		return v.agent.IssueCredentialClient.SendOffer(offer, conn)
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create offer credential : %w", err)
+	}
+
+	v.metrics.offerCreated(protocolWACI)
+
+	return thID, msg, nil
+}
+
+// startConnectionlessWACIIssuance links thread of the attached offer to the invitation, so that the wallet's
+// request-credential is answered with the invitation's credential.
+func (v *adapterApp) startConnectionlessWACIIssuance(invitationID, thID string) error {
+	_, err := readWACIIssuanceData(v.store, invitationID, thID)
+	if err != nil {
+		return fmt.Errorf("failed to save WACI issuance data : %w", err)
+	}
+
+	err = v.store.Put(thID, []byte(thID))
+	if err != nil {
+		return fmt.Errorf("failed to save interaction data : %w", err)
+	}
+
+	v.transcripts.link(thID, invitationID)
+
+	return v.linkWACIIssuanceThread(invitationID, thID)
+}
+
+// captureInvitationAttachment runs the first message of a protocol through the agent's protocol service, so that
+// the service expects the wallet's reply on the message's thread, and captures the message instead of sending it.
+func (v *adapterApp) captureInvitationAttachment(didCommVersion service.Version,
+	send func(conn *connection.Record) (string, error)) (string, service.DIDCommMsgMap, error) {
+	conn := &connection.Record{
+		TheirDID:       invitationAttachmentDIDPrefix + uuid.NewString(),
+		DIDCommVersion: didCommVersion,
+	}
+
+	if didCommVersion == service.V2 {
+		conn.MyDID = v.agent.PublicDIDV2
+	}
+
+	thID, err := send(conn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg, ok := v.agent.Messenger.takeAttachment(conn.TheirDID)
+	if !ok {
+		return "", nil, errors.New("protocol message was not captured")
+	}
+
+	return thID, msg, nil
+}
+
+func invitationAttachmentV1(msg service.DIDCommMsgMap) *decorator.Attachment {
+	return &decorator.Attachment{
+		ID:       uuid.NewString(),
+		MimeType: "application/json",
+		Data:     decorator.AttachmentData{JSON: msg},
+	}
+}
+
+func invitationAttachmentV2(msg service.DIDCommMsgMap) *decorator.AttachmentV2 {
+	return &decorator.AttachmentV2{
+		ID:        uuid.NewString(),
+		MediaType: "application/json",
+		Data:      decorator.AttachmentData{JSON: msg},
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const (
+	didMethodOrbOption = "orb"
+	didMethodWebOption = "web"
+
+	// didWebDocPath is where the adapter serves its own did:web document.
+	didWebDocPath = "/didcomm/did.json"
+)
+
+// publicDIDV2 is the adapter's DID used as sender of OOB V2 invitations.
+type publicDIDV2 struct {
+	ID     string          `json:"id"`
+	Method string          `json:"method"`
+	Doc    json.RawMessage `json:"doc,omitempty"`
+}
+
+// setDIDV2Method accepts DID method usable for DIDComm V2. Without an Orb domain, did:web served by the adapter
+// itself is the only method wallets can resolve: did:peer:2 isn't resolved by aries, and did:key documents have no
+// DIDComm service.
+func setDIDV2Method(target *string) func(string) error {
+	return func(method string) error {
+		switch method {
+		case didMethodOrbOption, didMethodWebOption:
+			*target = method
+
+			return nil
+		default:
+			return fmt.Errorf("unsupported DID method, expected one of %s, %s", didMethodOrbOption, didMethodWebOption)
+		}
+	}
+}
+
+// loadOrCreatePublicDIDV2 reuses public DID created by a previous run with the same method, so that it survives
+// restarts when persistent storage is used.
+func loadOrCreatePublicDIDV2(storeProvider storage.Provider, cfg *adapterConfig, orbVDR vdr.VDR,
+	km kms.KeyManager) (*publicDIDV2, error) {
+	method := cfg.DIDCommV2DIDMethod
+
+	store, err := storeProvider.OpenStore(didCommStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open didcomm store : %w", err)
+	}
+
+	didBytes, err := store.Get(publicDIDV2StoreKey)
+	if err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+		return nil, fmt.Errorf("failed to get public DID : %w", err)
+	}
+
+	if err == nil {
+		var existing publicDIDV2
+
+		err = json.Unmarshal(didBytes, &existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal public DID : %w", err)
+		}
+
+		if existing.Method == method {
+			return &existing, nil
+		}
+	}
+
+	var publicDID *publicDIDV2
+
+	switch method {
+	case didMethodWebOption:
+		publicDID, err = createWebDIDV2(cfg, km)
+	default:
+		publicDID = &publicDIDV2{Method: didMethodOrbOption}
+		publicDID.ID, err = createPublicDIDV2(cfg, orbVDR, km)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	didBytes, err = json.Marshal(publicDID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public DID : %w", err)
+	}
+
+	err = store.Put(publicDIDV2StoreKey, didBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save public DID : %w", err)
+	}
+
+	return publicDID, nil
+}
+
+// createWebDIDV2 creates did:web document to be served by the adapter under its external URL.
+func createWebDIDV2(cfg *adapterConfig, km kms.KeyManager) (*publicDIDV2, error) {
+	externalURL, err := url.Parse(cfg.ExternalURL)
+	if err != nil || externalURL.Host == "" {
+		return nil, fmt.Errorf("%s must be a valid URL to create did:web", demoExternalURLFlagName)
+	}
+
+	didID := "did:web:" + strings.ReplaceAll(externalURL.Host, ":", "%3A")
+
+	// did:web:host:a:b resolves to https://host/a/b/did.json
+	docDir := strings.TrimSuffix(externalURL.Path, "/") + strings.TrimSuffix(didWebDocPath, "/did.json")
+
+	for _, segment := range strings.Split(strings.Trim(docDir, "/"), "/") {
+		didID += ":" + segment
+	}
+
+	// without configured key types, keys are those the agent creates by default.
+	keyCfg := *cfg
+
+	if keyCfg.KeyType == "" {
+		keyCfg.KeyType = kms.ED25519Type
+	}
+
+	if keyCfg.KeyAgreementType == "" {
+		keyCfg.KeyAgreementType = kms.X25519ECDHKWType
+	}
+
+	didDoc, err := buildDIDDocV2(&keyCfg, km)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DID doc: %w", err)
+	}
+
+	didDoc.ID = didID
+	didDoc.Context = []string{did.ContextV1}
+
+	// verification methods are listed in the document and referenced from their relationships.
+	var authentication, keyAgreement []did.Verification
+
+	for _, v := range didDoc.Authentication {
+		vm, err := absoluteVerificationMethod(v.VerificationMethod, didID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create verification method : %w", err)
+		}
+
+		didDoc.VerificationMethod = append(didDoc.VerificationMethod, *vm)
+		authentication = append(authentication, *did.NewReferencedVerification(vm, did.Authentication))
+	}
+
+	for _, v := range didDoc.KeyAgreement {
+		vm, err := absoluteVerificationMethod(v.VerificationMethod, didID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create verification method : %w", err)
+		}
+
+		didDoc.VerificationMethod = append(didDoc.VerificationMethod, *vm)
+		keyAgreement = append(keyAgreement, *did.NewReferencedVerification(vm, did.KeyAgreement))
+	}
+
+	didDoc.Authentication, didDoc.KeyAgreement = authentication, keyAgreement
+
+	for i := range didDoc.Service {
+		didDoc.Service[i].ID = didID + "#" + didDoc.Service[i].ID
+	}
+
+	docBytes, err := didDoc.JSONBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal did:web document : %w", err)
+	}
+
+	return &publicDIDV2{ID: didID, Method: didMethodWebOption, Doc: docBytes}, nil
+}
+
+// absoluteVerificationMethod rebuilds verification method with ID and controller of given DID, as relative IDs
+// are kept relative when the document is marshalled.
+func absoluteVerificationMethod(vm did.VerificationMethod, didID string) (*did.VerificationMethod, error) {
+	id := vm.ID
+
+	if strings.HasPrefix(id, "#") {
+		id = didID + id
+	} else if !strings.HasPrefix(id, "did:") {
+		id = didID + "#" + id
+	}
+
+	return did.NewVerificationMethodFromJWK(id, vm.Type, didID, vm.JSONWebKey())
+}
+
+// servePublicDIDDoc serves the adapter's did:web document.
+func servePublicDIDDoc(doc []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/did+json")
+
+		if _, err := w.Write(doc); err != nil {
+			logger.Errorf("failed to write DID document : %s", err)
+		}
+	}
+}
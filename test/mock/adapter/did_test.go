@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreatePublicDIDV2(t *testing.T) {
+	cfg := newTestAdapterConfig()
+	storeProvider := mem.NewProvider()
+
+	framework, err := aries.New(aries.WithStoreProvider(storeProvider))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, framework.Close()) })
+
+	ctx, err := framework.Context()
+	require.NoError(t, err)
+
+	publicDID, err := loadOrCreatePublicDIDV2(storeProvider, cfg, nil, ctx.KMS())
+	require.NoError(t, err)
+	require.Equal(t, "did:web:adapter.example.com:didcomm", publicDID.ID)
+	require.NotEmpty(t, publicDID.Doc)
+
+	reloaded, err := loadOrCreatePublicDIDV2(storeProvider, cfg, nil, ctx.KMS())
+	require.NoError(t, err)
+	require.Equal(t, publicDID, reloaded)
+
+	cfg.KeyType = kms.ECDSAP256TypeDER
+
+	_, err = loadOrCreatePublicDIDV2(storeProvider, cfg, nil, ctx.KMS())
+	require.NoError(t, err, "stored DID is reused regardless of key types")
+}
+
+func TestWebDIDV2(t *testing.T) {
+	cfg := newTestAdapterConfig()
+	cfg.ExternalURL = "https://adapter.example.com:8094"
+	cfg.KeyType, cfg.KeyAgreementType = kms.ED25519Type, kms.X25519ECDHKWType
+
+	framework, err := aries.New(aries.WithStoreProvider(mem.NewProvider()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, framework.Close()) })
+
+	ctx, err := framework.Context()
+	require.NoError(t, err)
+
+	publicDID, err := createWebDIDV2(cfg, ctx.KMS())
+	require.NoError(t, err)
+	require.Equal(t, "did:web:adapter.example.com%3A8094:didcomm", publicDID.ID)
+
+	doc, err := did.ParseDocument(publicDID.Doc)
+	require.NoError(t, err)
+	require.Equal(t, publicDID.ID+"#key-2", doc.KeyAgreement[0].VerificationMethod.ID)
+
+	_, err = ctx.KMS().Get(doc.KeyAgreement[0].VerificationMethod.JSONWebKey().KeyID)
+	require.NoError(t, err)
+}
+
+func TestSetDIDV2Method(t *testing.T) {
+	var method string
+
+	require.NoError(t, setDIDV2Method(&method)(didMethodWebOption))
+	require.Equal(t, didMethodWebOption, method)
+
+	// wallets can't resolve the adapter's did:peer:2 or find a DIDComm service in its did:key document.
+	for _, unsupported := range []string{"peer", "key"} {
+		require.EqualError(t, setDIDV2Method(&method)(unsupported), "unsupported DID method, expected one of orb, web")
+	}
+}
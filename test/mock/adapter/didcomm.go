@@ -12,19 +12,21 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/google/uuid"
 	"github.com/hyperledger/aries-framework-go-ext/component/vdr/orb"
-	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
 	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
 	"github.com/hyperledger/aries-framework-go/pkg/client/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/client/mediator"
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofbandv2"
 	"github.com/hyperledger/aries-framework-go/pkg/client/presentproof"
 	"github.com/hyperledger/aries-framework-go/pkg/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messaging/msghandler"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messenger"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
 	arieshttp "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/http"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/ws"
 	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
 	"github.com/hyperledger/aries-framework-go/pkg/doc/jose/jwk"
 	"github.com/hyperledger/aries-framework-go/pkg/doc/jose/jwk/jwksupport"
@@ -34,19 +36,32 @@ import (
 	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
 	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
 	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/defaults"
+	ariescontext "github.com/hyperledger/aries-framework-go/pkg/framework/context"
 	"github.com/hyperledger/aries-framework-go/pkg/kms"
 	"github.com/hyperledger/aries-framework-go/pkg/vdr/web"
 	"github.com/hyperledger/aries-framework-go/spi/storage"
 	tlsutils "github.com/trustbloc/edge-core/pkg/utils/tls"
 )
 
+const (
+	didCommStoreName    = "adapter_didcomm"
+	publicDIDV2StoreKey = "public_did_v2"
+)
+
 type didComm struct {
+	Context               *ariescontext.Provider
 	OOBClient             *outofband.Client
 	OOBV2Client           *outofbandv2.Client
 	DIDExchClient         *didexchange.Client
 	PresentProofClient    *presentproof.Client
 	IssueCredentialClient *issuecredential.Client
-	OrbDIDV2              string
+	MediatorClient        *mediator.Client
+	Inspector             *agentInspector
+	Messenger             *recordingMessenger
+	MessageRegistrar      *msghandler.Registrar
+	VDRegistry            vdr.Registry
+	PublicDIDV2           string
+	PublicDIDDocV2        []byte
 }
 
 var (
@@ -70,15 +85,23 @@ var (
 	}
 )
 
-func startAriesAgent() (*didComm, error) {
-	storeProvider := mem.NewProvider()
-
+func startAriesAgent(storeProvider storage.Provider, cfg *adapterConfig) (*didComm, error) {
 	var opts []aries.Option
 	opts = append(opts, aries.WithStoreProvider(storeProvider))
 
-	opts = append(opts, defaults.WithInboundHTTPAddr(os.Getenv(didCommInternalHostEnvKey),
-		os.Getenv(didCommExternalHostEnvKey), os.Getenv(tlsCertFileEnvKey),
-		os.Getenv(tlsKeyFileEnvKey)))
+	inspector := newAgentInspector()
+	recorder := &recordingMessenger{inspector: inspector}
+
+	opts = append(opts, aries.WithMessengerHandler(recorder))
+
+	msgRegistrar := msghandler.NewRegistrar()
+
+	opts = append(opts, aries.WithMessageServiceProvider(msgRegistrar))
+
+	// aries inbound transports load the certificate files once, unlike the adapter's HTTPS endpoint they don't
+	// pick up renewed certificates.
+	opts = append(opts, defaults.WithInboundHTTPAddr(cfg.DIDCommInternalHost, cfg.DIDCommExternalHost,
+		cfg.TLSCertFile, cfg.TLSKeyFile))
 
 	tlsConfig := &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS12}
 
@@ -91,49 +114,55 @@ func startAriesAgent() (*didComm, error) {
 		return nil, fmt.Errorf("http outbound transport initialization failed: %w", err)
 	}
 
-	opts = append(opts, aries.WithOutboundTransports(outbound))
+	opts = append(opts, aries.WithOutboundTransports(outbound, ws.NewOutbound()))
 
-	vdri, err := orb.New(nil,
-		orb.WithTLSConfig(tlsConfig),
-		orb.WithDomain(os.Getenv(orbDomainEnvKey)),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to init orb VDR: %w", err)
+	// WebSocket inbound lets wallets without a public endpoint receive replies over the same connection.
+	if cfg.DIDCommWSInternalHost != "" {
+		opts = append(opts, defaults.WithInboundWSAddr(cfg.DIDCommWSInternalHost, cfg.DIDCommWSExternalHost,
+			cfg.TLSCertFile, cfg.TLSKeyFile, 0))
 	}
 
-	var (
-		keyT    kms.KeyType
-		keyAgrT kms.KeyType
-	)
+	// orb is optional unless used for the adapter's own DID, so that the adapter can run without external services.
+	var vdri vdr.VDR
+
+	if cfg.DIDCommV2DIDMethod == didMethodOrbOption || cfg.OrbDomain != "" {
+		vdri, err = orb.New(nil,
+			orb.WithTLSConfig(tlsConfig),
+			orb.WithDomain(cfg.OrbDomain),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init orb VDR: %w", err)
+		}
+
+		opts = append(opts, aries.WithVDR(vdri))
+	}
 
 	// add "didcomm/aip2;env=rfc587" & "didcomm/v2" media type profiles.
 	opts = append(opts, aries.WithMediaTypeProfiles([]string{
 		transport.MediaTypeDIDCommV2Profile, transport.MediaTypeAIP2RFC0587Profile,
 		transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1}))
 
-	// if keyTypeEnvKey and KeyAgreementTypeEnvKey are found, then override agent's options
-	if kt, ok := os.LookupEnv(keyTypeEnvKey); ok {
-		keyT = keyTypes[kt]
-		opts = append(opts, aries.WithKeyType(keyT))
+	// if key types are configured, then override agent's options
+	if cfg.KeyType != "" {
+		opts = append(opts, aries.WithKeyType(cfg.KeyType))
 	}
 
-	if kt, ok := os.LookupEnv(keyAgreementTypeEnvKey); ok {
-		keyAgrT = keyAgreementTypes[kt]
-		opts = append(opts, aries.WithKeyAgreementType(keyAgrT))
+	if cfg.KeyAgreementType != "" {
+		opts = append(opts, aries.WithKeyAgreementType(cfg.KeyAgreementType))
 	}
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
 
-	opts = append(opts, aries.WithVDR(vdri), aries.WithVDR(&webVDR{
+	opts = append(opts, aries.WithVDR(&webVDR{
 		http: &http.Client{Transport: tr},
 		VDR:  web.New(),
 	}))
 
-	if ctxURL := os.Getenv(contextProviderEnvKey); ctxURL != "" {
-		if caCerts := os.Getenv(tlsCACertsEnvKey); caCerts != "" {
-			rootCAs, err := tlsutils.GetCertPool(true, []string{caCerts})
+	if ctxURL := cfg.ContextProviderURL; ctxURL != "" {
+		if len(cfg.TLSCACerts) > 0 {
+			rootCAs, err := tlsutils.GetCertPool(true, cfg.TLSCACerts)
 			if err != nil {
 				panic("failed to setup root ca, " + err.Error())
 			}
@@ -159,15 +188,28 @@ func startAriesAgent() (*didComm, error) {
 		return nil, fmt.Errorf("failed to get aries context : %w", err)
 	}
 
+	msgr, err := messenger.NewMessenger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create messenger : %w", err)
+	}
+
+	recorder.setMessenger(msgr)
+
 	// out-of-band client
 	oobClient, err := outofband.New(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create oob-client : %w", err)
 	}
 
-	publicDIDV2, err := createPublicDIDV2(vdri, ctx.KMS(), keyT, keyAgrT)
+	publicDIDV2, err := loadOrCreatePublicDIDV2(storeProvider, cfg, vdri, ctx.KMS())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create orb DID for OOB V2 invitations: %w", err)
+		return nil, fmt.Errorf("failed to create %s DID for OOB V2 invitations: %w", cfg.DIDCommV2DIDMethod, err)
+	}
+
+	// did:web documents are served by the adapter, orb ones are published to the Orb domain.
+	var publicDIDDocV2 []byte
+	if publicDIDV2.Method == didMethodWebOption {
+		publicDIDDocV2 = publicDIDV2.Doc
 	}
 
 	// out-of-band v2 client
@@ -194,18 +236,31 @@ func startAriesAgent() (*didComm, error) {
 		return nil, fmt.Errorf("failed to create issuecredential-client: %w", err)
 	}
 
+	// mediator client, wallets may use the adapter as their router and pick up messages from it.
+	mediatorClient, err := mediator.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mediator-client: %w", err)
+	}
+
 	return &didComm{
+		Context:               ctx,
 		OOBClient:             oobClient,
 		OOBV2Client:           oobV2Client,
 		DIDExchClient:         didExClient,
 		PresentProofClient:    presentProofClient,
 		IssueCredentialClient: issueCredentialClient,
-		OrbDIDV2:              publicDIDV2,
+		MediatorClient:        mediatorClient,
+		Inspector:             inspector,
+		Messenger:             recorder,
+		MessageRegistrar:      msgRegistrar,
+		VDRegistry:            ctx.VDRegistry(),
+		PublicDIDV2:           publicDIDV2.ID,
+		PublicDIDDocV2:        publicDIDDocV2,
 	}, nil
 }
 
-func createPublicDIDV2(vdri vdr.VDR, km kms.KeyManager, keyType, keyAgreementType kms.KeyType) (string, error) {
-	didDoc, err := buildDIDDocV2(km, keyType, keyAgreementType)
+func createPublicDIDV2(cfg *adapterConfig, vdri vdr.VDR, km kms.KeyManager) (string, error) {
+	didDoc, err := buildDIDDocV2(cfg, km)
 	if err != nil {
 		return "", fmt.Errorf("failed to create DID doc: %w", err)
 	}
@@ -238,17 +293,17 @@ func newKey(km kms.KeyManager) (crypto.PublicKey, error) {
 	return ed25519.PublicKey(bits), nil
 }
 
-func buildDIDDocV2(km kms.KeyManager, keyType, keyAgreementType kms.KeyType) (*did.Doc, error) {
+func buildDIDDocV2(cfg *adapterConfig, km kms.KeyManager) (*did.Doc, error) {
 	didDoc := did.Doc{}
 
-	auth, err := createVerification("#key-1", km, keyType, did.Authentication)
+	auth, err := createVerification("#key-1", km, cfg.KeyType, did.Authentication)
 	if err != nil {
 		return nil, fmt.Errorf("creating did doc Authentication: %w", err)
 	}
 
 	didDoc.Authentication = append(didDoc.Authentication, *auth)
 
-	kagr, err := createVerification("#key-2", km, keyAgreementType, did.KeyAgreement)
+	kagr, err := createVerification("#key-2", km, cfg.KeyAgreementType, did.KeyAgreement)
 	if err != nil {
 		return nil, fmt.Errorf("creating did doc KeyAgreement: %w", err)
 	}
@@ -256,15 +311,25 @@ func buildDIDDocV2(km kms.KeyManager, keyType, keyAgreementType kms.KeyType) (*d
 	didDoc.KeyAgreement = append(didDoc.KeyAgreement, *kagr)
 
 	didDoc.Service = []did.Service{{
-		ID: uuid.NewString(),
-		ServiceEndpoint: model.NewDIDCommV2Endpoint(
-			[]model.DIDCommV2Endpoint{{URI: os.Getenv(didCommExternalHostEnvKey)}}),
-		Type: "DIDCommMessaging",
+		ID:              uuid.NewString(),
+		ServiceEndpoint: model.NewDIDCommV2Endpoint(didCommV2Endpoints(cfg)),
+		Type:            "DIDCommMessaging",
 	}}
 
 	return &didDoc, nil
 }
 
+// didCommV2Endpoints returns the adapter's DIDComm V2 endpoints, WebSocket one included for wallets without
+// inbound endpoint.
+func didCommV2Endpoints(cfg *adapterConfig) []model.DIDCommV2Endpoint {
+	endpoints := []model.DIDCommV2Endpoint{{URI: cfg.DIDCommExternalHost}}
+	if cfg.DIDCommWSExternalHost != "" {
+		endpoints = append(endpoints, model.DIDCommV2Endpoint{URI: cfg.DIDCommWSExternalHost})
+	}
+
+	return endpoints
+}
+
 func createVerification(id string, km kms.KeyManager, kt kms.KeyType, relationship did.VerificationRelationship,
 ) (*did.Verification, error) {
 	vm, err := createVerificationMethod(id, km, kt)
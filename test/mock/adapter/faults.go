@@ -0,0 +1,658 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+)
+
+const (
+	faultBodyTruncate      = "truncate"
+	faultBodyCorrupt       = "corrupt"
+	faultBodyFlipSignature = "flip-signature"
+
+	maxFaultLatency = time.Minute
+
+	injectedFaultDescription = "injected fault"
+)
+
+var errFaultDropped = errors.New("dropped by injected fault")
+
+// faultRule makes the adapter misbehave on an HTTP route or DIDComm protocol step, in one session or in all of
+// them. Matching requests are delayed by the latency, then answered with the status or OAuth error instead of
+// the handler's response, or get the handler's response with the body mutated. Matching outbound DIDComm messages
+// are delayed, get credential signatures flipped, or are dropped.
+type faultRule struct {
+	ID string `json:"id"`
+	// Session is ID of the session, or any ID, state, code or thread linked to it. Empty applies to all sessions.
+	Session string `json:"session,omitempty"`
+	// Route is path template of the route, e.g. /{id}/issuer/openid4vc/token, or the request path.
+	Route string `json:"route,omitempty"`
+	// Step is type of outbound DIDComm message, or its last segments, e.g. present-proof/3.0/request-presentation.
+	Step       string `json:"step,omitempty"`
+	Latency    string `json:"latency,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	OAuthError string `json:"oauth_error,omitempty"`
+	Body       string `json:"body,omitempty"`
+	Drop       bool   `json:"drop,omitempty"`
+	// Times is how many times the rule applies before it is removed. Zero applies it until it is removed.
+	Times   int       `json:"times,omitempty"`
+	Applied int       `json:"applied"`
+	Created time.Time `json:"created"`
+
+	latency time.Duration
+}
+
+// injectedFault is a fault recorded on the session it was injected in.
+type injectedFault struct {
+	RuleID  string   `json:"rule_id"`
+	Route   string   `json:"route,omitempty"`
+	Step    string   `json:"step,omitempty"`
+	Actions []string `json:"actions"`
+}
+
+// faultInjector keeps fault rules set at runtime through admin API, and applies them to HTTP requests and
+// outbound DIDComm messages. Rules are matched in the order they were added.
+type faultInjector struct {
+	transcripts *transcriptRecorder
+
+	mu    sync.Mutex
+	rules []*faultRule
+}
+
+func newFaultInjector(transcripts *transcriptRecorder) *faultInjector {
+	return &faultInjector{transcripts: transcripts}
+}
+
+func (r *faultRule) validate() error { //nolint:gocyclo
+	if (r.Route == "") == (r.Step == "") {
+		return errors.New("either route or step is required")
+	}
+
+	if r.Latency != "" {
+		latency, err := time.ParseDuration(r.Latency)
+		if err != nil || latency <= 0 || latency > maxFaultLatency {
+			return fmt.Errorf("latency must be positive duration up to %s", maxFaultLatency)
+		}
+
+		r.latency = latency
+	}
+
+	if r.Status != 0 && http.StatusText(r.Status) == "" {
+		return fmt.Errorf("unknown HTTP status %d", r.Status)
+	}
+
+	switch r.Body {
+	case "", faultBodyTruncate, faultBodyCorrupt, faultBodyFlipSignature:
+	default:
+		return fmt.Errorf("body must be one of %s, %s, %s", faultBodyTruncate, faultBodyCorrupt,
+			faultBodyFlipSignature)
+	}
+
+	if r.Times < 0 {
+		return errors.New("times must not be negative")
+	}
+
+	if r.Step != "" {
+		if r.Status != 0 || r.OAuthError != "" || (r.Body != "" && r.Body != faultBodyFlipSignature) {
+			return fmt.Errorf("DIDComm step rules support latency, drop and body %s only", faultBodyFlipSignature)
+		}
+	} else {
+		if r.Drop {
+			return errors.New("drop is supported for DIDComm step rules only")
+		}
+
+		if r.Body != "" && (r.Status != 0 || r.OAuthError != "") {
+			return errors.New("body can't be combined with status or oauth_error")
+		}
+	}
+
+	if r.latency == 0 && r.Status == 0 && r.OAuthError == "" && r.Body == "" && !r.Drop {
+		return errors.New("at least one of latency, status, oauth_error, body or drop is required")
+	}
+
+	return nil
+}
+
+// actions describes what the rule does, for the session's transcript.
+func (r *faultRule) actions() []string {
+	var actions []string
+
+	if r.latency > 0 {
+		actions = append(actions, "latency "+r.latency.String())
+	}
+
+	if r.OAuthError != "" {
+		actions = append(actions, "oauth_error "+r.OAuthError)
+	}
+
+	if r.Status != 0 {
+		actions = append(actions, fmt.Sprintf("status %d", r.Status))
+	}
+
+	if r.Body != "" {
+		actions = append(actions, "body "+r.Body)
+	}
+
+	if r.Drop {
+		actions = append(actions, "drop")
+	}
+
+	return actions
+}
+
+func (f *faultInjector) add(rule *faultRule) error {
+	err := rule.validate()
+	if err != nil {
+		return err
+	}
+
+	rule.ID = uuid.NewString()
+	rule.Applied = 0
+	rule.Created = time.Now()
+
+	f.mu.Lock()
+	f.rules = append(f.rules, rule)
+	f.mu.Unlock()
+
+	return nil
+}
+
+func (f *faultInjector) list() []*faultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules := make([]*faultRule, 0, len(f.rules))
+
+	for _, rule := range f.rules {
+		r := *rule
+		rules = append(rules, &r)
+	}
+
+	return rules
+}
+
+func (f *faultInjector) remove(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *faultInjector) clear() {
+	f.mu.Lock()
+	f.rules = nil
+	f.mu.Unlock()
+}
+
+func (f *faultInjector) active() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.rules) > 0
+}
+
+// match returns copy of the first rule which matches and applies to the session of one of the keys, and counts
+// it as applied.
+func (f *faultInjector) match(matches func(rule *faultRule) bool, sessionKeys []string) *faultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, rule := range f.rules {
+		if !matches(rule) || !f.inSession(rule, sessionKeys) {
+			continue
+		}
+
+		rule.Applied++
+
+		if rule.Times > 0 && rule.Applied >= rule.Times {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+		}
+
+		r := *rule
+
+		return &r
+	}
+
+	return nil
+}
+
+func (f *faultInjector) inSession(rule *faultRule, sessionKeys []string) bool {
+	if rule.Session == "" {
+		return true
+	}
+
+	for _, key := range sessionKeys {
+		if f.transcripts.sameSession(rule.Session, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// record logs the fault, and records it on the session of the first known key. Otherwise, it is recorded under
+// the rule's session or the pending key, e.g. thread ID which is linked to its session later.
+func (f *faultInjector) record(rule *faultRule, actions, sessionKeys []string, pendingKey string) {
+	session := ""
+
+	for _, key := range sessionKeys {
+		if key != "" && f.transcripts.known(key) {
+			session = key
+
+			break
+		}
+	}
+
+	if session == "" {
+		session = rule.Session
+	}
+
+	if session == "" {
+		session = pendingKey
+	}
+
+	logger.Warnf("injected fault : rule=%s route=%s step=%s actions=%s session=%s", rule.ID, rule.Route,
+		rule.Step, strings.Join(actions, ", "), session)
+
+	if session == "" {
+		return
+	}
+
+	f.transcripts.record(session, &transcriptEntry{
+		Kind: transcriptEntryFault,
+		Time: time.Now(),
+		Fault: &injectedFault{
+			RuleID:  rule.ID,
+			Route:   rule.Route,
+			Step:    rule.Step,
+			Actions: actions,
+		},
+	})
+}
+
+// middleware applies fault rules to HTTP requests. Admin requests are never faulted, so that rules can always be
+// removed.
+func (f *faultInjector) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") || !f.active() {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		var requestBody []byte
+
+		if r.Body != nil {
+			var err error
+
+			requestBody, err = io.ReadAll(r.Body)
+			if err != nil {
+				logger.Errorf("failed to read request body for fault rules : %s", err)
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		sessionKeys := requestSessionKeys(r, requestBody)
+
+		rule := f.match(func(rule *faultRule) bool {
+			return rule.Route != "" && (rule.Route == route || rule.Route == r.URL.Path)
+		}, sessionKeys)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		actions := f.serveFaulty(w, r, next, rule)
+
+		f.record(rule, actions, append([]string{transcriptSessionID(r)}, sessionKeys...), "")
+	})
+}
+
+// serveFaulty serves the request with the rule applied, and returns the applied actions.
+func (f *faultInjector) serveFaulty(w http.ResponseWriter, r *http.Request, next http.Handler,
+	rule *faultRule) []string {
+	actions := rule.actions()
+
+	if !sleepContext(r.Context(), rule.latency) {
+		return append(actions, "request canceled")
+	}
+
+	switch {
+	case rule.OAuthError != "":
+		status := rule.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+
+		setOIDCResponseHeaders(w)
+		w.WriteHeader(status)
+
+		err := json.NewEncoder(w).Encode(map[string]string{
+			"error":             rule.OAuthError,
+			"error_description": injectedFaultDescription,
+		})
+		if err != nil {
+			logger.Errorf("failed to write response : %s", err)
+		}
+	case rule.Status != 0:
+		handleError(w, rule.Status, injectedFaultDescription)
+	case rule.Body != "":
+		buffered := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+
+		next.ServeHTTP(buffered, r)
+
+		body, mutated := mutateBody(buffered.body.Bytes(), rule.Body)
+		if !mutated {
+			actions = append(actions, "nothing to mutate")
+		}
+
+		for k, v := range buffered.header {
+			w.Header()[k] = v
+		}
+
+		w.Header().Del("Content-Length")
+		w.WriteHeader(buffered.status)
+
+		if _, err := w.Write(body); err != nil {
+			logger.Errorf("failed to write response : %s", err)
+		}
+	default:
+		next.ServeHTTP(w, r)
+	}
+
+	return actions
+}
+
+// outboundMessage applies fault rules to outbound DIDComm message, and tells whether to drop it.
+func (f *faultInjector) outboundMessage(msg service.DIDCommMsgMap) bool {
+	if f == nil || !f.active() {
+		return false
+	}
+
+	// a message starting a new thread has no thread decorator, its ID is the thread ID.
+	thID, err := msg.ThreadID()
+	if err != nil || thID == "" {
+		thID = msg.ID()
+	}
+
+	sessionKeys := []string{thID, msg.ParentThreadID()}
+
+	msgType := msg.Type()
+
+	rule := f.match(func(rule *faultRule) bool {
+		return rule.Step != "" && (rule.Step == msgType || strings.HasSuffix(msgType, "/"+rule.Step))
+	}, sessionKeys)
+	if rule == nil {
+		return false
+	}
+
+	actions := rule.actions()
+
+	time.Sleep(rule.latency)
+
+	if rule.Body == faultBodyFlipSignature && !flipSignatures(map[string]interface{}(msg)) {
+		actions = append(actions, "nothing to mutate")
+	}
+
+	f.record(rule, actions, sessionKeys, thID)
+
+	return rule.Drop
+}
+
+// bufferedResponse keeps the handler's response, to be mutated before it is sent.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// mutateBody truncates, corrupts or flips credential signatures in the body, and tells whether it changed.
+func mutateBody(body []byte, mutation string) ([]byte, bool) {
+	if len(body) == 0 {
+		return body, false
+	}
+
+	switch mutation {
+	case faultBodyTruncate:
+		return body[:len(body)/2], true
+	case faultBodyCorrupt:
+		corrupted := append([]byte{}, body...)
+		corrupted[len(corrupted)/2] = 0
+
+		return corrupted, true
+	case faultBodyFlipSignature:
+		var doc interface{}
+
+		if err := json.Unmarshal(body, &doc); err != nil {
+			jwt, ok := flipJWTSignature(strings.TrimSpace(string(body)))
+
+			return []byte(jwt), ok
+		}
+
+		// wrapped, so that signatures are flipped in top level arrays and strings too.
+		wrapper := map[string]interface{}{"": doc}
+		if !flipSignatures(wrapper) {
+			return body, false
+		}
+
+		mutated, err := json.Marshal(wrapper[""])
+		if err != nil {
+			return body, false
+		}
+
+		return mutated, true
+	}
+
+	return body, false
+}
+
+// flipSignatures flips a bit in every signature of linked data proofs and JWTs found in the document.
+func flipSignatures(doc map[string]interface{}) bool {
+	flipped := false
+
+	for key, value := range doc {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if key == "proof" && flipProofSignature(v) {
+				flipped = true
+
+				continue
+			}
+
+			flipped = flipSignatures(v) || flipped
+		case []interface{}:
+			for i, item := range v {
+				switch it := item.(type) {
+				case map[string]interface{}:
+					if key == "proof" && flipProofSignature(it) {
+						flipped = true
+
+						continue
+					}
+
+					flipped = flipSignatures(it) || flipped
+				case string:
+					if jwt, ok := flipJWTSignature(it); ok {
+						v[i], flipped = jwt, true
+					}
+				}
+			}
+		case string:
+			if jwt, ok := flipJWTSignature(v); ok {
+				doc[key], flipped = jwt, true
+			}
+		}
+	}
+
+	return flipped
+}
+
+func flipProofSignature(proof map[string]interface{}) bool {
+	if jws, ok := proof["jws"].(string); ok {
+		if flipped, ok := flipJWTSignature(jws); ok {
+			proof["jws"] = flipped
+
+			return true
+		}
+	}
+
+	if value, ok := proof["proofValue"].(string); ok && value != "" {
+		if strings.HasPrefix(value, "z") {
+			if sig := base58.Decode(value[1:]); len(sig) > 0 {
+				sig[0] ^= 1
+				proof["proofValue"] = "z" + base58.Encode(sig)
+
+				return true
+			}
+		}
+
+		if sig, err := base64.RawURLEncoding.DecodeString(value); err == nil && len(sig) > 0 {
+			sig[0] ^= 1
+			proof["proofValue"] = base64.RawURLEncoding.EncodeToString(sig)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// flipJWTSignature flips a bit in the signature of compact JWS, including detached JWS of linked data proofs.
+func flipJWTSignature(value string) (string, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[2] == "" { //nolint:gomnd
+		return value, false
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || !json.Valid(header) || !strings.Contains(string(header), `"alg"`) {
+		return value, false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) == 0 {
+		return value, false
+	}
+
+	sig[0] ^= 1
+
+	return parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig), true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (v *adapterApp) listFaultRules(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, v.faults.list())
+}
+
+func (v *adapterApp) addFaultRule(w http.ResponseWriter, r *http.Request) {
+	var rule faultRule
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&rule)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode fault rule : %s", err))
+
+		return
+	}
+
+	err = v.faults.add(&rule)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("invalid fault rule : %s", err))
+
+		return
+	}
+
+	logger.Infof("added fault rule : id=%s route=%s step=%s session=%s actions=%s", rule.ID, rule.Route,
+		rule.Step, rule.Session, strings.Join(rule.actions(), ", "))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err = json.NewEncoder(w).Encode(&rule); err != nil {
+		logger.Errorf("failed to write response : %s", err)
+	}
+}
+
+func (v *adapterApp) removeFaultRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !v.faults.remove(id) {
+		handleError(w, http.StatusNotFound, fmt.Sprintf("no fault rule %s", id))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (v *adapterApp) clearFaultRules(w http.ResponseWriter, _ *http.Request) {
+	v.faults.clear()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,253 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	presentproofsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/presentproof"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWS = "eyJhbGciOiJFZERTQSJ9..c2lnbmF0dXJl"
+
+func TestFaultInjector_HTTP(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	router := mux.NewRouter()
+	router.Use(app.transcripts.middleware, app.faults.middleware)
+	router.HandleFunc("/admin/faults", app.listFaultRules).Methods(http.MethodGet)
+	router.HandleFunc("/admin/faults", app.addFaultRule).Methods(http.MethodPost)
+	router.HandleFunc("/admin/faults", app.clearFaultRules).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/faults/{id}", app.removeFaultRule).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/issuer/openid4vc/credential", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"format":     "ldp_vc",
+			"credential": map[string]interface{}{"id": "vc-1", "proof": map[string]interface{}{"jws": testJWS}},
+		})
+	})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		return rr
+	}
+
+	addRule := func(t *testing.T, rule string) *faultRule {
+		t.Helper()
+
+		rr := serve(http.MethodPost, "/admin/faults", rule)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+		var added faultRule
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &added))
+		require.NotEmpty(t, added.ID)
+
+		return &added
+	}
+
+	sessionID := uuid.NewString()
+	app.transcripts.recordToken(sessionID, "pre-authorized_code", "code-1")
+	app.transcripts.link("code-1", sessionID)
+
+	credentialPath := "/" + sessionID + "/issuer/openid4vc/credential"
+
+	t.Run("status", func(t *testing.T) {
+		addRule(t, `{"route": "/{id}/issuer/openid4vc/credential", "status": 503, "times": 1}`)
+
+		rr := serve(http.MethodPost, credentialPath, "")
+		require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		require.Contains(t, rr.Body.String(), injectedFaultDescription)
+
+		// the rule was applied as many times as configured.
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, credentialPath, "").Code)
+	})
+
+	t.Run("OAuth error with latency", func(t *testing.T) {
+		rule := addRule(t, `{"route": "`+credentialPath+`", "oauth_error": "invalid_token", "latency": "20ms"}`)
+
+		start := time.Now()
+		rr := serve(http.MethodPost, credentialPath, "")
+		require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.JSONEq(t, `{"error": "invalid_token", "error_description": "injected fault"}`, rr.Body.String())
+
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/faults/"+rule.ID, "").Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/faults/"+rule.ID, "").Code)
+	})
+
+	t.Run("body mutations", func(t *testing.T) {
+		original := serve(http.MethodPost, credentialPath, "").Body.String()
+
+		addRule(t, `{"route": "/{id}/issuer/openid4vc/credential", "body": "truncate", "times": 1}`)
+		require.Equal(t, original[:len(original)/2], serve(http.MethodPost, credentialPath, "").Body.String())
+
+		addRule(t, `{"route": "/{id}/issuer/openid4vc/credential", "body": "corrupt", "times": 1}`)
+		require.False(t, json.Valid(serve(http.MethodPost, credentialPath, "").Body.Bytes()))
+
+		addRule(t, `{"route": "/{id}/issuer/openid4vc/credential", "body": "flip-signature", "times": 1}`)
+
+		var resp struct {
+			Credential struct {
+				Proof struct {
+					JWS string `json:"jws"`
+				} `json:"proof"`
+			} `json:"credential"`
+		}
+
+		require.NoError(t, json.Unmarshal(serve(http.MethodPost, credentialPath, "").Body.Bytes(), &resp))
+		require.NotEqual(t, testJWS, resp.Credential.Proof.JWS)
+		require.True(t, strings.HasPrefix(resp.Credential.Proof.JWS, "eyJhbGciOiJFZERTQSJ9.."))
+	})
+
+	t.Run("session rule", func(t *testing.T) {
+		addRule(t, `{"route": "/{id}/issuer/openid4vc/credential", "session": "code-1", "status": 500}`)
+
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, "/other/issuer/openid4vc/credential", "").Code)
+		require.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, credentialPath, "").Code)
+
+		rr := serve(http.MethodGet, "/admin/faults", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var rules []*faultRule
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rules))
+		require.Len(t, rules, 1)
+		require.Equal(t, 1, rules[0].Applied)
+
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/faults", "").Code)
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, credentialPath, "").Code)
+	})
+
+	t.Run("faults are recorded on the session", func(t *testing.T) {
+		session, ok := app.transcripts.get(sessionID)
+		require.True(t, ok)
+
+		var faults []*injectedFault
+
+		for _, entry := range session.Entries {
+			if entry.Kind == transcriptEntryFault {
+				faults = append(faults, entry.Fault)
+			}
+		}
+
+		require.Len(t, faults, 6)
+		require.Equal(t, []string{"status 503"}, faults[0].Actions)
+		require.Equal(t, []string{"latency 20ms", "oauth_error invalid_token"}, faults[1].Actions)
+		require.Equal(t, []string{"body flip-signature"}, faults[4].Actions)
+		require.Equal(t, "/{id}/issuer/openid4vc/credential", faults[5].Route)
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for rule, msg := range map[string]string{
+			`{"status": 500}`: "either route or step is required",
+			`{"route": "/a", "step": "b", "status": 500}`: "either route or step is required",
+			`{"route": "/a"}`:                                    "at least one of",
+			`{"route": "/a", "latency": "forever"}`:              "latency must be positive duration",
+			`{"route": "/a", "status": 999}`:                     "unknown HTTP status 999",
+			`{"route": "/a", "body": "shuffle"}`:                 "body must be one of",
+			`{"route": "/a", "body": "truncate", "status": 500}`: "body can't be combined",
+			`{"route": "/a", "drop": true}`:                      "drop is supported for DIDComm step rules only",
+			`{"step": "b", "status": 500}`:                       "DIDComm step rules support",
+			`{"route": "/a", "status": 500, "times": -1}`:        "times must not be negative",
+			`{"route": "/a", "code": 500}`:                       "unknown field",
+		} {
+			rr := serve(http.MethodPost, "/admin/faults", rule)
+			require.Equal(t, http.StatusBadRequest, rr.Code, rule)
+			require.Contains(t, rr.Body.String(), msg, rule)
+		}
+	})
+}
+
+func TestFaultInjector_DIDComm(t *testing.T) {
+	transcripts := newTranscriptRecorder()
+	faults := newFaultInjector(transcripts)
+
+	inspector := newAgentInspector()
+	inspector.observe(transcripts.recordMessage)
+
+	msgr := &recordingMessenger{inspector: inspector}
+	msgr.setFaultInjector(faults)
+
+	invID := uuid.NewString()
+
+	require.NoError(t, faults.add(&faultRule{Step: "present-proof/2.0/request-presentation", Drop: true,
+		Session: invID}))
+
+	newRequest := func(thID, pthID string) service.DIDCommMsgMap {
+		return service.DIDCommMsgMap{
+			"@id":     uuid.NewString(),
+			"@type":   presentproofsvc.RequestPresentationMsgTypeV2,
+			"~thread": map[string]interface{}{"thid": thID, "pthid": pthID},
+		}
+	}
+
+	// other sessions' messages are sent, which fails as the messenger is not started.
+	require.ErrorIs(t, msgr.Send(newRequest(uuid.NewString(), uuid.NewString()), "did:example:a", "did:example:b"),
+		errMessengerNotReady)
+
+	thID := uuid.NewString()
+
+	require.NoError(t, msgr.Send(newRequest(thID, invID), "did:example:a", "did:example:b"))
+
+	logged := inspector.recentMessages(thID)
+	require.Len(t, logged, 1)
+	require.Equal(t, errFaultDropped.Error(), logged[0].Error)
+
+	session, ok := transcripts.get(invID)
+	require.True(t, ok)
+
+	var faultEntries int
+
+	for _, entry := range session.Entries {
+		if entry.Kind == transcriptEntryFault {
+			faultEntries++
+			require.Equal(t, []string{"drop"}, entry.Fault.Actions)
+		}
+	}
+
+	require.Equal(t, 1, faultEntries)
+}
+
+func TestFlipSignatures(t *testing.T) {
+	sig := base64.RawURLEncoding.EncodeToString([]byte("signature"))
+	jwt := "eyJhbGciOiJFUzI1NiJ9.eyJzdWIiOiJ4In0." + sig
+
+	doc := map[string]interface{}{
+		"credentials": []interface{}{jwt, "not-a-jwt"},
+		"attachment": map[string]interface{}{
+			"proof": []interface{}{map[string]interface{}{"proofValue": "z3FXQjecWufY46yg5abdVZsXqLhxhueuSoZgNSARiKBk"}},
+		},
+	}
+
+	require.True(t, flipSignatures(doc))
+
+	credentials := doc["credentials"].([]interface{}) //nolint:forcetypeassert
+	require.NotEqual(t, jwt, credentials[0])
+	require.True(t, strings.HasPrefix(credentials[0].(string), "eyJhbGciOiJFUzI1NiJ9.eyJzdWIiOiJ4In0."))
+	require.Equal(t, "not-a-jwt", credentials[1])
+
+	proof := doc["attachment"].(map[string]interface{})["proof"].([]interface{})[0] //nolint:forcetypeassert
+	require.NotEqual(t, "z3FXQjecWufY46yg5abdVZsXqLhxhueuSoZgNSARiKBk", proof.(map[string]interface{})["proofValue"])
+
+	require.False(t, flipSignatures(map[string]interface{}{"id": "vc-1"}))
+
+	flipped, ok := mutateBody([]byte(jwt), faultBodyFlipSignature)
+	require.True(t, ok)
+	require.NotEqual(t, jwt, string(flipped))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const (
+	harVersion     = "1.2"
+	harCreatorName = "mock-adapter"
+)
+
+// har is HTTP Archive 1.2 document. DIDComm messages, tokens and injected faults of the session, which are not
+// HTTP exchanges, are kept in the custom "_messages", "_tokens" and "_faults" fields of the log.
+type har struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version  string             `json:"version"`
+	Creator  *harCreator        `json:"creator"`
+	Comment  string             `json:"comment,omitempty"`
+	Entries  []*harEntry        `json:"entries"`
+	Messages []*transcriptEntry `json:"_messages"`
+	Tokens   []*transcriptEntry `json:"_tokens"`
+	Faults   []*transcriptEntry `json:"_faults"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            int64        `json:"time"`
+	Request         *harRequest  `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+func newHAR(session *transcript) *har {
+	log := &harLog{
+		Version:  harVersion,
+		Creator:  &harCreator{Name: harCreatorName, Version: harVersion},
+		Comment:  "session " + session.ID,
+		Entries:  []*harEntry{},
+		Messages: []*transcriptEntry{},
+		Tokens:   []*transcriptEntry{},
+		Faults:   []*transcriptEntry{},
+	}
+
+	for _, entry := range session.Entries {
+		switch entry.Kind {
+		case transcriptEntryHTTP:
+			log.Entries = append(log.Entries, newHAREntry(entry))
+		case transcriptEntryDIDComm:
+			log.Messages = append(log.Messages, entry)
+		case transcriptEntryToken:
+			log.Tokens = append(log.Tokens, entry)
+		case transcriptEntryFault:
+			log.Faults = append(log.Faults, entry)
+		}
+	}
+
+	return &har{Log: log}
+}
+
+func newHAREntry(entry *transcriptEntry) *harEntry {
+	exchange := entry.HTTP
+
+	request := &harRequest{
+		Method:      exchange.Method,
+		URL:         exchange.URL,
+		HTTPVersion: exchange.Proto,
+		Cookies:     harCookies((&http.Request{Header: exchange.RequestHeaders}).Cookies()),
+		Headers:     harHeaders(exchange.RequestHeaders),
+		QueryString: []*harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(exchange.RequestBody),
+	}
+
+	if u, err := url.Parse(exchange.URL); err == nil {
+		request.QueryString = harValues(u.Query())
+	}
+
+	if exchange.RequestBody != "" {
+		request.PostData = &harPostData{
+			MimeType: exchange.RequestHeaders.Get("Content-Type"),
+			Text:     exchange.RequestBody,
+		}
+	}
+
+	return &harEntry{
+		StartedDateTime: entry.Time.Format(time.RFC3339Nano),
+		Time:            exchange.DurationMillis,
+		Request:         request,
+		Response: &harResponse{
+			Status:      exchange.Status,
+			StatusText:  http.StatusText(exchange.Status),
+			HTTPVersion: exchange.Proto,
+			Cookies:     harCookies((&http.Response{Header: exchange.ResponseHeaders}).Cookies()),
+			Headers:     harHeaders(exchange.ResponseHeaders),
+			Content: &harContent{
+				Size:     len(exchange.ResponseBody),
+				MimeType: exchange.ResponseHeaders.Get("Content-Type"),
+				Text:     exchange.ResponseBody,
+			},
+			RedirectURL: exchange.ResponseHeaders.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(exchange.ResponseBody),
+		},
+		Timings: &harTimings{Send: 0, Wait: exchange.DurationMillis, Receive: 0},
+	}
+}
+
+func harHeaders(header http.Header) []*harNameValue {
+	return harValues(url.Values(header))
+}
+
+func harValues(values url.Values) []*harNameValue {
+	nameValues := []*harNameValue{}
+
+	for name, vals := range values {
+		for _, val := range vals {
+			nameValues = append(nameValues, &harNameValue{Name: name, Value: val})
+		}
+	}
+
+	sort.SliceStable(nameValues, func(i, j int) bool { return nameValues[i].Name < nameValues[j].Name })
+
+	return nameValues
+}
+
+func harCookies(cookies []*http.Cookie) []*harNameValue {
+	nameValues := []*harNameValue{}
+
+	for _, cookie := range cookies {
+		nameValues = append(nameValues, &harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	return nameValues
+}
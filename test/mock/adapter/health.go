@@ -0,0 +1,211 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	ariescontext "github.com/hyperledger/aries-framework-go/pkg/framework/context"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	readinessCheckTimeout = 5 * time.Second
+
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	checkStatusOK           = "ok"
+	checkStatusFailed       = "failed"
+	checkStatusSkipped      = "skipped"
+
+	orbDIDPrefix = "did:orb:"
+)
+
+// errCheckSkipped is returned by readiness checks of dependencies that are not configured.
+var errCheckSkipped = errors.New("not configured")
+
+// readinessCheck is a named check of a dependency the adapter needs to serve requests.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type healthStatus struct {
+	Status string                  `json:"status"`
+	Checks map[string]*checkResult `json:"checks,omitempty"`
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz reports that the process is up and serving HTTP.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, &healthStatus{Status: healthStatusOK})
+}
+
+// readyz runs the checks concurrently and responds with 503 if any of them fails.
+func readyz(checks []readinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		status := &healthStatus{Status: healthStatusOK, Checks: make(map[string]*checkResult, len(checks))}
+
+		var (
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+
+		for _, c := range checks {
+			wg.Add(1)
+
+			go func(c readinessCheck) {
+				defer wg.Done()
+
+				result := &checkResult{Status: checkStatusOK}
+
+				err := c.check(ctx)
+
+				switch {
+				case errors.Is(err, errCheckSkipped):
+					result.Status = checkStatusSkipped
+				case err != nil:
+					result.Status = checkStatusFailed
+					result.Error = err.Error()
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				status.Checks[c.name] = result
+
+				if result.Status == checkStatusFailed {
+					status.Status = healthStatusUnavailable
+				}
+			}(c)
+		}
+
+		wg.Wait()
+
+		code := http.StatusOK
+		if status.Status != healthStatusOK {
+			code = http.StatusServiceUnavailable
+
+			logger.Errorf("adapter is not ready : %+v", status.Checks)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+
+		err := json.NewEncoder(w).Encode(status)
+		if err != nil {
+			logger.Errorf("failed to write response : %s", err)
+		}
+	}
+}
+
+// readinessChecks returns the checks of the Aries framework and the external services the adapter depends on.
+func readinessChecks(agent *didComm, cfg *adapterConfig) []readinessCheck {
+	return []readinessCheck{
+		{name: "aries-context", check: frameworkContextCheck(agent.Context)},
+		{name: "didcomm-inbound", check: listenerCheck(cfg.DIDCommInternalHost)},
+		{name: "didcomm-ws-inbound", check: listenerCheck(cfg.DIDCommWSInternalHost)},
+		{name: "orb-did", check: orbDIDCheck(agent.VDRegistry, agent.PublicDIDV2)},
+		{name: "context-provider", check: reachableCheck(&http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // same as the adapter's other clients
+		}}, cfg.ContextProviderURL)},
+	}
+}
+
+// frameworkContextCheck checks that the Aries context is created and its storage can be opened.
+func frameworkContextCheck(ctx *ariescontext.Provider) func(context.Context) error {
+	return func(context.Context) error {
+		if ctx == nil {
+			return errors.New("aries context is not initialized")
+		}
+
+		_, err := ctx.StorageProvider().OpenStore(didCommStoreName)
+		if err != nil {
+			return fmt.Errorf("failed to open aries store : %w", err)
+		}
+
+		return nil
+	}
+}
+
+// listenerCheck checks that something accepts TCP connections on the inbound transport address.
+func listenerCheck(addr string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if addr == "" {
+			return errCheckSkipped
+		}
+
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("inbound endpoint is not listening : %w", err)
+		}
+
+		return conn.Close()
+	}
+}
+
+// orbDIDCheck checks that the adapter's orb DID resolves, it is skipped if the adapter uses another DID method.
+func orbDIDCheck(registry vdr.Registry, didID string) func(context.Context) error {
+	return func(context.Context) error {
+		if !strings.HasPrefix(didID, orbDIDPrefix) {
+			return errCheckSkipped
+		}
+
+		_, err := registry.Resolve(didID)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s : %w", didID, err)
+		}
+
+		return nil
+	}
+}
+
+// reachableCheck checks that the server at the URL responds without a server error.
+func reachableCheck(client *http.Client, targetURL string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if targetURL == "" {
+			return errCheckSkipped
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request : %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach %s : %w", targetURL, err)
+		}
+
+		defer resp.Body.Close() // nolint: errcheck
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s responded with status %d", targetURL, resp.StatusCode)
+		}
+
+		return nil
+	}
+}
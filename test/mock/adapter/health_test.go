@@ -0,0 +1,103 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	mockvdr "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadyz(t *testing.T) {
+	serve := func(checks []readinessCheck) (int, *healthStatus) {
+		rr := httptest.NewRecorder()
+		readyz(checks)(rr, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+
+		status := &healthStatus{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), status))
+
+		return rr.Code, status
+	}
+
+	ok := func(context.Context) error { return nil }
+	skipped := func(context.Context) error { return errCheckSkipped }
+	failed := func(context.Context) error { return errors.New("connection refused") }
+
+	code, status := serve([]readinessCheck{{name: "a", check: ok}, {name: "b", check: skipped}})
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, healthStatusOK, status.Status)
+	require.Equal(t, checkStatusSkipped, status.Checks["b"].Status)
+
+	code, status = serve([]readinessCheck{{name: "a", check: ok}, {name: "b", check: failed}})
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, healthStatusUnavailable, status.Status)
+	require.Equal(t, checkStatusOK, status.Checks["a"].Status)
+	require.Equal(t, checkStatusFailed, status.Checks["b"].Status)
+	require.Equal(t, "connection refused", status.Checks["b"].Error)
+
+	rr := httptest.NewRecorder()
+	healthz(rr, httptest.NewRequest(http.MethodGet, healthzPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func TestReadinessChecks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("aries context", func(t *testing.T) {
+		_, ariesCtx := newTestAdapterAppWithContext(t)
+
+		require.NoError(t, frameworkContextCheck(ariesCtx)(ctx))
+		require.Error(t, frameworkContextCheck(nil)(ctx))
+	})
+
+	t.Run("inbound listener", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		addr := listener.Addr().String()
+
+		require.NoError(t, listenerCheck(addr)(ctx))
+		require.NoError(t, listener.Close())
+		require.Error(t, listenerCheck(addr)(ctx))
+		require.ErrorIs(t, listenerCheck("")(ctx), errCheckSkipped)
+	})
+
+	t.Run("orb DID", func(t *testing.T) {
+		const orbDID = "did:orb:uAAA:EiDahaOGH-liLLdDtTxEAdc8i-cfCz-WUcQdRJheMVNn3A"
+
+		registry := &mockvdr.MockVDRegistry{ResolveValue: &did.Doc{ID: orbDID}}
+		require.NoError(t, orbDIDCheck(registry, orbDID)(ctx))
+
+		registry = &mockvdr.MockVDRegistry{ResolveErr: errors.New("not found")}
+		require.Error(t, orbDIDCheck(registry, orbDID)(ctx))
+		require.ErrorIs(t, orbDIDCheck(registry, "did:peer:2.Ez6LS")(ctx), errCheckSkipped)
+	})
+
+	t.Run("context provider", func(t *testing.T) {
+		status := http.StatusOK
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		require.NoError(t, reachableCheck(server.Client(), server.URL)(ctx))
+
+		status = http.StatusBadGateway
+		require.Error(t, reachableCheck(server.Client(), server.URL)(ctx))
+		require.ErrorIs(t, reachableCheck(server.Client(), "")(ctx), errCheckSkipped)
+	})
+}
@@ -0,0 +1,345 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package holder is a minimal headless holder taking part in the adapter's issuance and presentation flows, so
+// that the flows can be driven end to end from Go tests without a browser or wallet.
+//
+// The holder has an ed25519 did:key, used to sign proofs and presentations, and an embedded Aries agent with an
+// HTTP inbound endpoint for WACI over DIDComm. It keeps no credentials, the flows return what was issued and take
+// what is to be presented.
+package holder
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/client/vcwallet"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	arieshttp "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/http"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/defaults"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/context"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/hyperledger/aries-framework-go/pkg/wallet"
+)
+
+const (
+	defaultRedirectURI = "https://holder.example.com/callback"
+	defaultTimeout     = 30 * time.Second
+	clientID           = "headless-holder"
+)
+
+// Holder is a headless holder. Flows of one holder must not run concurrently.
+type Holder struct {
+	did         string
+	kid         string
+	signer      *signer
+	http        *http.Client
+	redirectURI string
+	timeout     time.Duration
+	framework   *aries.Aries
+	ctx         *context.Provider
+	wallet      *vcwallet.Client
+	issued      issuedCredentials
+}
+
+type options struct {
+	httpClient       *http.Client
+	redirectURI      string
+	timeout          time.Duration
+	vdrs             []vdr.VDR
+	inboundAddr      string
+	keyAgreementType kms.KeyType
+}
+
+// Opt configures the holder.
+type Opt func(opts *options)
+
+// WithHTTPClient sets HTTP client used for the adapter's endpoints and outbound DIDComm messages, e.g. to trust
+// the adapter's TLS certificate. The holder uses a copy of the client with its own cookie jar.
+func WithHTTPClient(client *http.Client) Opt {
+	return func(opts *options) {
+		opts.httpClient = client
+	}
+}
+
+// WithRedirectURI sets the holder's OAuth redirect URI. The holder never serves it, redirects to it end the
+// authorization.
+func WithRedirectURI(uri string) Opt {
+	return func(opts *options) {
+		opts.redirectURI = uri
+	}
+}
+
+// WithTimeout sets how long the holder waits for each DIDComm protocol step.
+func WithTimeout(timeout time.Duration) Opt {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
+// WithVDR adds VDR to the holder's agent, for DID methods of the adapter which the agent doesn't resolve itself.
+func WithVDR(v vdr.VDR) Opt {
+	return func(opts *options) {
+		opts.vdrs = append(opts.vdrs, v)
+	}
+}
+
+// WithInboundAddr sets host:port of the agent's HTTP inbound endpoint, a free localhost port is used by default.
+func WithInboundAddr(addr string) Opt {
+	return func(opts *options) {
+		opts.inboundAddr = addr
+	}
+}
+
+// WithKeyAgreementType sets type of the agent's DIDComm V2 key agreement keys, which must be on the curve of the
+// adapter's key agreement keys. The aries default, X25519, is used by default.
+func WithKeyAgreementType(keyType kms.KeyType) Opt {
+	return func(opts *options) {
+		opts.keyAgreementType = keyType
+	}
+}
+
+// New creates a holder with a new DID and starts its agent.
+func New(opts ...Opt) (*Holder, error) {
+	o := &options{httpClient: &http.Client{}, redirectURI: defaultRedirectURI, timeout: defaultTimeout}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key : %w", err)
+	}
+
+	didKey, kid := fingerprint.CreateDIDKey(pub)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar : %w", err)
+	}
+
+	httpClient := *o.httpClient
+	httpClient.Jar = jar
+
+	h := &Holder{
+		did:         didKey,
+		kid:         kid,
+		signer:      &signer{privateKey: priv},
+		http:        &httpClient,
+		redirectURI: o.redirectURI,
+		timeout:     o.timeout,
+	}
+
+	httpClient.CheckRedirect = h.checkRedirect
+
+	err = h.startAgent(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// DID returns the holder's DID, to be used as subject of credentials issued to the holder.
+func (h *Holder) DID() string {
+	return h.did
+}
+
+// Close stops the holder's agent.
+func (h *Holder) Close() error {
+	h.wallet.Close()
+
+	return h.framework.Close()
+}
+
+// startAgent starts the agent used for DIDComm, it also resolves DIDs and loads JSON-LD contexts of the HTTP flows.
+func (h *Holder) startAgent(o *options) error {
+	addr := o.inboundAddr
+	if addr == "" {
+		var err error
+
+		addr, err = freeLocalAddr()
+		if err != nil {
+			return err
+		}
+	}
+
+	outbound, err := arieshttp.NewOutbound(arieshttp.WithOutboundHTTPClient(o.httpClient))
+	if err != nil {
+		return fmt.Errorf("failed to create http outbound transport : %w", err)
+	}
+
+	ariesOpts := []aries.Option{
+		aries.WithStoreProvider(mem.NewProvider()),
+		aries.WithProtocolStateStoreProvider(mem.NewProvider()),
+		defaults.WithInboundHTTPAddr(addr, "http://"+addr, "", ""),
+		aries.WithOutboundTransports(outbound),
+		aries.WithMediaTypeProfiles([]string{
+			transport.MediaTypeDIDCommV2Profile, transport.MediaTypeAIP2RFC0587Profile,
+			transport.MediaTypeAIP2RFC0019Profile, transport.MediaTypeProfileDIDCommAIP1,
+		}),
+	}
+
+	if o.keyAgreementType != "" {
+		ariesOpts = append(ariesOpts, aries.WithKeyAgreementType(o.keyAgreementType))
+	}
+
+	for _, v := range o.vdrs {
+		ariesOpts = append(ariesOpts, aries.WithVDR(v))
+	}
+
+	h.framework, err = aries.New(ariesOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to start agent : %w", err)
+	}
+
+	h.ctx, err = h.framework.Context()
+	if err != nil {
+		return h.closeOnError(fmt.Errorf("failed to get agent context : %w", err))
+	}
+
+	err = h.registerActions()
+	if err != nil {
+		return h.closeOnError(err)
+	}
+
+	// the wallet only drives WACI interactions, its passphrase protects nothing worth keeping.
+	userID, passphrase := uuid.NewString(), uuid.NewString()
+
+	err = vcwallet.CreateProfile(userID, h.ctx, wallet.WithPassphrase(passphrase))
+	if err != nil {
+		return h.closeOnError(fmt.Errorf("failed to create wallet profile : %w", err))
+	}
+
+	h.wallet, err = vcwallet.New(userID, h.ctx, wallet.WithUnlockByPassphrase(passphrase))
+	if err != nil {
+		return h.closeOnError(fmt.Errorf("failed to open wallet : %w", err))
+	}
+
+	return nil
+}
+
+func (h *Holder) closeOnError(err error) error {
+	if e := h.framework.Close(); e != nil {
+		return fmt.Errorf("%w (failed to stop agent : %s)", err, e)
+	}
+
+	return err
+}
+
+// checkRedirect follows redirects, except to the holder's redirect URI which ends authorization.
+func (h *Holder) checkRedirect(req *http.Request, via []*http.Request) error {
+	if strings.HasPrefix(req.URL.String(), h.redirectURI) {
+		return http.ErrUseLastResponse
+	}
+
+	if len(via) >= 10 { //nolint:gomnd // same limit as the default policy
+		return errors.New("stopped after 10 redirects")
+	}
+
+	return nil
+}
+
+// doJSON sends the request and decodes JSON response into v, any other than 200 response is an error.
+func (h *Holder) doJSON(req *http.Request, v interface{}) error {
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to %s : %w", req.URL, err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s : %w", req.URL, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &ResponseError{URL: req.URL.String(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("failed to decode response from %s : %w", req.URL, err)
+	}
+
+	return nil
+}
+
+// ResponseError is returned when the adapter answers with an unexpected status.
+type ResponseError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("unexpected response from %s : status=%d body=%s", e.URL, e.StatusCode, e.Body)
+}
+
+// parseCredential parses credential in JSON-LD or JWT format, checking its proof.
+func (h *Holder) parseCredential(raw json.RawMessage) (*verifiable.Credential, error) {
+	var jwtVC string
+
+	// JWT credentials are sent as JSON strings.
+	if json.Unmarshal(raw, &jwtVC) == nil {
+		raw = json.RawMessage(jwtVC)
+	}
+
+	vc, err := verifiable.ParseCredential(raw,
+		verifiable.WithPublicKeyFetcher(verifiable.NewVDRKeyResolver(h.ctx.VDRegistry()).PublicKeyFetcher()),
+		verifiable.WithJSONLDDocumentLoader(h.ctx.JSONLDDocumentLoader()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential : %w", err)
+	}
+
+	return vc, nil
+}
+
+// signer signs with the holder's DID key.
+type signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+func (s *signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, data), nil
+}
+
+func (s *signer) Alg() string {
+	return "EdDSA"
+}
+
+func freeLocalAddr() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find free port : %w", err)
+	}
+
+	addr := listener.Addr().String()
+
+	err = listener.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to release port : %w", err)
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package holder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+)
+
+// oidcLoginFormAction is where the issuer's login page posts, as a browser would after the user logs in.
+const oidcLoginFormAction = "/issuer/oidc/authorize-request"
+
+// issuerConfiguration is the issuer's OpenID configuration, both OIDC and OpenID4VC issuers use the same fields.
+type issuerConfiguration struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	CredentialEndpoint    string `json:"credential_endpoint"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	CNonce      string `json:"c_nonce"`
+}
+
+type credentialResponse struct {
+	Format     string          `json:"format"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// OIDCIssuance takes the OIDC issuance offer, which is the URL initiating issuance in the wallet, goes through the
+// authorization code flow and returns credentials of all offered types in the format, ldp_vc or jwt_vc.
+func (h *Holder) OIDCIssuance(offer, format string) ([]*verifiable.Credential, error) {
+	offerURL, err := url.Parse(offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse offer : %w", err)
+	}
+
+	issuer := offerURL.Query().Get("issuer")
+	credentialTypes := offerURL.Query()["credential_type"]
+
+	if issuer == "" || len(credentialTypes) == 0 {
+		return nil, errors.New("offer must have issuer and credential_type")
+	}
+
+	conf, err := h.issuerConfiguration(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := h.authorize(conf, credentialTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newFormRequest(conf.TokenEndpoint, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {h.redirectURI},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var token tokenResponse
+
+	err = h.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token : %w", err)
+	}
+
+	credentials := make([]*verifiable.Credential, 0, len(credentialTypes))
+
+	for _, credentialType := range credentialTypes {
+		req, err = newFormRequest(conf.CredentialEndpoint, url.Values{"format": {format}, "type": {credentialType}})
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		var resp credentialResponse
+
+		err = h.doJSON(req, &resp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get '%s' credential : %w", credentialType, err)
+		}
+
+		vc, err := h.parseCredential(resp.Credential)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, vc)
+	}
+
+	return credentials, nil
+}
+
+func (h *Holder) issuerConfiguration(issuer string) (*issuerConfiguration, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration",
+		http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request : %w", err)
+	}
+
+	var conf issuerConfiguration
+
+	err = h.doJSON(req, &conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issuer configuration : %w", err)
+	}
+
+	return &conf, nil
+}
+
+// authorize goes through the authorization endpoint and the login page, and returns the authorization code sent to
+// the redirect URI.
+func (h *Holder) authorize(conf *issuerConfiguration, credentialTypes []string) (string, error) {
+	state := uuid.NewString()
+
+	claims, err := json.Marshal(map[string]interface{}{"credential_type": credentialTypes})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims : %w", err)
+	}
+
+	authURL := conf.AuthorizationEndpoint + "?" + url.Values{
+		"response_type": {"code"},
+		"client_id":     {clientID},
+		"redirect_uri":  {h.redirectURI},
+		"scope":         {"openid"},
+		"state":         {state},
+		"claims":        {string(claims)},
+	}.Encode()
+
+	resp, err := h.http.Get(authURL) //nolint:noctx
+	if err != nil {
+		return "", fmt.Errorf("failed to send authorization request : %w", err)
+	}
+
+	resp.Body.Close() //nolint:errcheck,gosec
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &ResponseError{URL: authURL, StatusCode: resp.StatusCode}
+	}
+
+	loginURL := resp.Request.URL.ResolveReference(&url.URL{Path: oidcLoginFormAction})
+
+	req, err := newFormRequest(loginURL.String(), url.Values{})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err = h.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in : %w", err)
+	}
+
+	resp.Body.Close() //nolint:errcheck,gosec
+
+	location, err := resp.Location()
+	if err != nil || !strings.HasPrefix(location.String(), h.redirectURI) {
+		return "", fmt.Errorf("login was not redirected to the redirect URI : status=%d", resp.StatusCode)
+	}
+
+	if got := location.Query().Get("state"); got != state {
+		return "", fmt.Errorf("authorization response state '%s' does not match the request", got)
+	}
+
+	code := location.Query().Get("code")
+	if code == "" {
+		return "", errors.New("authorization response has no code")
+	}
+
+	return code, nil
+}
+
+func newFormRequest(endpoint string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode())) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return req, nil
+}
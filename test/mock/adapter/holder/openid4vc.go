@@ -0,0 +1,224 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package holder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jwt"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/presexch"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/util/didsignjwt"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+)
+
+const (
+	preAuthorizedCodeGrantType = "urn:ietf:params:oauth:grant-type:pre-authorized_code"
+	selfIssuedIssuer           = "https://self-issued.me/v2/openid-vc"
+	idTokenLifetime            = 10 * time.Minute
+)
+
+// OpenID4VCIssuance takes the OpenID4VC pre-authorized issuance offer, the openid-initiate-issuance URL, with the
+// PIN shown to the user, and returns credential of the offered type in the format, ldp_vc or jwt_vc. The credential
+// request has proof JWT signed with the holder's DID key.
+func (h *Holder) OpenID4VCIssuance(offer, pin, format string) (*verifiable.Credential, error) {
+	offerURL, err := url.Parse(offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse offer : %w", err)
+	}
+
+	issuer := offerURL.Query().Get("issuer")
+	credentialType := offerURL.Query().Get("credential_type")
+	preAuthorizedCode := offerURL.Query().Get("pre-authorized_code")
+
+	if issuer == "" || credentialType == "" || preAuthorizedCode == "" {
+		return nil, errors.New("offer must have issuer, credential_type and pre-authorized_code")
+	}
+
+	conf, err := h.issuerConfiguration(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newFormRequest(conf.TokenEndpoint, url.Values{
+		"grant_type":          {preAuthorizedCodeGrantType},
+		"pre-authorized_code": {preAuthorizedCode},
+		"user_pin":            {pin},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var token tokenResponse
+
+	err = h.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token : %w", err)
+	}
+
+	proofJWT, err := h.signJWT(map[string]interface{}{
+		"iss":   clientID,
+		"aud":   issuer,
+		"iat":   time.Now().Unix(),
+		"nonce": token.CNonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign proof : %w", err)
+	}
+
+	credentialRequest, err := json.Marshal(map[string]interface{}{
+		"type":   credentialType,
+		"format": format,
+		"proof":  map[string]interface{}{"proof_type": "jwt", "jwt": proofJWT},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credential request : %w", err)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, conf.CredentialEndpoint, bytes.NewReader(credentialRequest)) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	var resp credentialResponse
+
+	err = h.doJSON(req, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential : %w", err)
+	}
+
+	return h.parseCredential(resp.Credential)
+}
+
+// requestObject is the verifier's signed OpenID4VP authorization request.
+type requestObject struct {
+	ClientID    string `json:"client_id"`
+	RedirectURI string `json:"redirect_uri"`
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Claims      struct {
+		VPToken struct {
+			PresentationDefinition *presexch.PresentationDefinition `json:"presentation_definition"`
+		} `json:"vp_token"`
+	} `json:"claims"`
+}
+
+// OpenID4VCPresentation fetches the verifier's request object from the request URI, checks its signature and
+// answers it with the credentials matching the presentation definition. The presentation is sent as JWT VP
+// with an id_token, both signed with the holder's DID key. It returns the verifier's response.
+func (h *Holder) OpenID4VCPresentation(requestURI string, credentials ...*verifiable.Credential) (json.RawMessage,
+	error) {
+	request, err := h.fetchRequestObject(requestURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Claims.VPToken.PresentationDefinition == nil {
+		return nil, errors.New("request object has no presentation definition")
+	}
+
+	vp, err := request.Claims.VPToken.PresentationDefinition.CreateVP(credentials, h.ctx.JSONLDDocumentLoader(),
+		verifiable.WithJSONLDDocumentLoader(h.ctx.JSONLDDocumentLoader()), verifiable.WithDisabledProofCheck())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create presentation : %w", err)
+	}
+
+	vp.Holder = h.did
+
+	vpClaims, err := vp.JWTClaims([]string{request.ClientID}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create presentation claims : %w", err)
+	}
+
+	vpToken, err := vpClaims.MarshalJWS(verifiable.EdDSA, h.signer, h.kid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign presentation : %w", err)
+	}
+
+	now := time.Now()
+
+	idToken, err := h.signJWT(map[string]interface{}{
+		"iss":       selfIssuedIssuer,
+		"sub":       h.did,
+		"aud":       request.ClientID,
+		"nonce":     request.Nonce,
+		"iat":       now.Unix(),
+		"exp":       now.Add(idTokenLifetime).Unix(),
+		"_vp_token": map[string]interface{}{"presentation_submission": vp.CustomFields["presentation_submission"]},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign id_token : %w", err)
+	}
+
+	req, err := newFormRequest(request.RedirectURI, url.Values{
+		"id_token": {idToken},
+		"vp_token": {vpToken},
+		"state":    {request.State},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result json.RawMessage
+
+	err = h.doJSON(req, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send presentation : %w", err)
+	}
+
+	return result, nil
+}
+
+func (h *Holder) fetchRequestObject(requestURI string) (*requestObject, error) {
+	resp, err := h.http.Get(requestURI) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch request object : %w", err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request object : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ResponseError{URL: requestURI, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	token, err := jwt.Parse(string(body), jwt.WithSignatureVerifier(jwt.NewVerifier(jwt.KeyResolverFunc(
+		verifiable.NewVDRKeyResolver(h.ctx.VDRegistry()).PublicKeyFetcher()))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify request object : %w", err)
+	}
+
+	var request requestObject
+
+	err = token.DecodeClaims(&request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode request object : %w", err)
+	}
+
+	return &request, nil
+}
+
+// signJWT signs claims with the holder's DID key, with the key ID in the header.
+func (h *Holder) signJWT(claims map[string]interface{}) (string, error) {
+	return didsignjwt.SignJWT(nil, claims, h.kid, func(*did.VerificationMethod) (didsignjwt.Signer, error) {
+		return h.signer, nil
+	}, h.ctx.VDRegistry())
+}
@@ -0,0 +1,357 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package holder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/client/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	issuecredentialsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	issuecredentialmiddleware "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/middleware/issuecredential"
+	presentproofsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/presexch"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/jsonld"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/suite"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/suite/ed25519signature2018"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/hyperledger/aries-framework-go/pkg/wallet"
+)
+
+const (
+	holderLabel = "headless holder"
+
+	// DIDComm V1 messages keep attachments under these keys, V2 messages under "attachments".
+	requestPresentationsAttachKey = "request_presentations~attach"
+	credentialsAttachKey          = "credentials~attach"
+	attachmentsKey                = "attachments"
+)
+
+// WACIResult is the outcome of WACI interaction, as reported by the adapter's ack or problem report.
+type WACIResult struct {
+	// Status is OK when the adapter accepted the interaction and FAIL when it declined it.
+	Status      string
+	RedirectURL string
+	// Credentials are the credentials issued in WACI issuance.
+	Credentials []*verifiable.Credential
+}
+
+// issuedCredentials collects credentials received in issue-credential messages, by protocol instance ID.
+type issuedCredentials struct {
+	mu          sync.Mutex
+	credentials map[string][]*verifiable.Credential
+	errs        map[string]error
+}
+
+// WACIShare takes the adapter's WACI share invitation, DIDComm V1 or V2, connects to the adapter and proposes
+// presentation. The adapter's request is answered with the credentials matching its presentation definition, in a
+// presentation signed with the holder's DID key.
+func (h *Holder) WACIShare(invitation json.RawMessage, credentials ...*verifiable.Credential) (*WACIResult, error) {
+	inv, err := parseInvitation(invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := h.wallet.ProposePresentation(inv, h.initiateOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose presentation : %w", err)
+	}
+
+	thID, err := request.ThreadID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request thread : %w", err)
+	}
+
+	vp, err := h.createWACIPresentation(*request, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := h.wallet.PresentProof(thID, wallet.FromPresentation(vp), wallet.WaitForDone(h.timeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to present proof : %w", err)
+	}
+
+	return &WACIResult{Status: status.Status, RedirectURL: status.RedirectURL}, nil
+}
+
+// WACIIssuance takes the adapter's WACI issuance invitation, DIDComm V1 or V2, connects to the adapter and proposes
+// credential, then requests the offered credential and returns it.
+func (h *Holder) WACIIssuance(invitation json.RawMessage) (*WACIResult, error) {
+	inv, err := parseInvitation(invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	offer, err := h.wallet.ProposeCredential(inv, h.initiateOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose credential : %w", err)
+	}
+
+	piID, err := issueCredentialPIID(offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offer protocol instance : %w", err)
+	}
+
+	status, err := h.wallet.RequestCredential(piID, wallet.WaitForDone(h.timeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to request credential : %w", err)
+	}
+
+	credentials, err := h.issued.take(piID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WACIResult{Status: status.Status, RedirectURL: status.RedirectURL, Credentials: credentials}, nil
+}
+
+func (h *Holder) initiateOptions() []wallet.InitiateInteractionOption {
+	return []wallet.InitiateInteractionOption{
+		wallet.WithInitiateTimeout(h.timeout),
+		wallet.WithConnectOptions(wallet.WithMyLabel(holderLabel), wallet.WithConnectTimeout(h.timeout)),
+	}
+}
+
+// createWACIPresentation answers request-presentation with presentation of the credentials, signed for the
+// request's challenge and domain.
+func (h *Holder) createWACIPresentation(request service.DIDCommMsgMap,
+	credentials []*verifiable.Credential) (*verifiable.Presentation, error) {
+	attachments, err := attachmentsJSON(request, requestPresentationsAttachKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attachments) == 0 {
+		return nil, errors.New("request presentation has no attachment")
+	}
+
+	var presentationRequest struct {
+		Challenge              string                           `json:"challenge"`
+		Domain                 string                           `json:"domain"`
+		PresentationDefinition *presexch.PresentationDefinition `json:"presentation_definition"`
+	}
+
+	err = json.Unmarshal(attachments[0], &presentationRequest)
+	if err != nil || presentationRequest.PresentationDefinition == nil {
+		return nil, fmt.Errorf("request presentation has no presentation definition : %v", err)
+	}
+
+	vp, err := presentationRequest.PresentationDefinition.CreateVP(credentials, h.ctx.JSONLDDocumentLoader(),
+		verifiable.WithJSONLDDocumentLoader(h.ctx.JSONLDDocumentLoader()), verifiable.WithDisabledProofCheck())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create presentation : %w", err)
+	}
+
+	vp.Holder = h.did
+	created := time.Now()
+
+	err = vp.AddLinkedDataProof(&verifiable.LinkedDataProofContext{
+		SignatureType:           "Ed25519Signature2018",
+		SignatureRepresentation: verifiable.SignatureProofValue,
+		Suite:                   ed25519signature2018.New(suite.WithSigner(h.signer)),
+		VerificationMethod:      h.kid,
+		Purpose:                 "authentication",
+		Challenge:               presentationRequest.Challenge,
+		Domain:                  presentationRequest.Domain,
+		Created:                 &created,
+	}, jsonld.WithDocumentLoader(h.ctx.JSONLDDocumentLoader()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign presentation : %w", err)
+	}
+
+	return vp, nil
+}
+
+// handleActions takes the agent's protocol actions. The wallet finds requests and offers among pending actions,
+// so those are left pending. Issued credentials are collected and accepted without saving them in the agent,
+// problem reports are accepted so that the wallet sees the interaction abandoned.
+func (h *Holder) handleActions(actions <-chan service.DIDCommAction) {
+	for action := range actions {
+		switch action.Message.Type() {
+		case issuecredentialsvc.IssueCredentialMsgTypeV2, issuecredentialsvc.IssueCredentialMsgTypeV3:
+			h.issued.collect(action.Message, h.parseCredential)
+
+			action.Continue(issuecredentialsvc.WithProperties(map[string]interface{}{
+				issuecredentialmiddleware.SkipCredentialSaveKey: true,
+			}))
+		case issuecredentialsvc.ProblemReportMsgTypeV2, issuecredentialsvc.ProblemReportMsgTypeV3,
+			presentproofsvc.ProblemReportMsgTypeV2, presentproofsvc.ProblemReportMsgTypeV3:
+			action.Continue(nil)
+		}
+	}
+}
+
+// registerActions routes actions of issue-credential and present-proof protocols to handleActions.
+func (h *Holder) registerActions() error {
+	actions := make(chan service.DIDCommAction)
+
+	issueCredentialClient, err := issuecredential.New(h.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create issue credential client : %w", err)
+	}
+
+	err = issueCredentialClient.RegisterActionEvent(actions)
+	if err != nil {
+		return fmt.Errorf("failed to register issue credential actions : %w", err)
+	}
+
+	presentProofClient, err := presentproof.New(h.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create present proof client : %w", err)
+	}
+
+	err = presentProofClient.RegisterActionEvent(actions)
+	if err != nil {
+		return fmt.Errorf("failed to register present proof actions : %w", err)
+	}
+
+	go h.handleActions(actions)
+
+	return nil
+}
+
+func (c *issuedCredentials) collect(msg service.DIDCommMsg, parse func(json.RawMessage) (*verifiable.Credential,
+	error)) {
+	piID, err := issueCredentialPIID(msg)
+	if err != nil {
+		return
+	}
+
+	credentials, err := credentialsFromMessage(msg, parse)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.credentials == nil {
+		c.credentials = map[string][]*verifiable.Credential{}
+		c.errs = map[string]error{}
+	}
+
+	c.credentials[piID] = credentials
+	c.errs[piID] = err
+}
+
+// take returns credentials issued in the interaction, and forgets them.
+func (c *issuedCredentials) take(piID string) ([]*verifiable.Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	credentials, ok := c.credentials[piID]
+	err := c.errs[piID]
+
+	delete(c.credentials, piID)
+	delete(c.errs, piID)
+
+	if !ok {
+		return nil, nil
+	}
+
+	return credentials, err
+}
+
+// credentialsFromMessage reads credentials of issue-credential message, attached either directly or in
+// presentation fulfilling credential manifest.
+func credentialsFromMessage(msg service.DIDCommMsg, parse func(json.RawMessage) (*verifiable.Credential,
+	error)) ([]*verifiable.Credential, error) {
+	msgMap, ok := msg.(service.DIDCommMsgMap)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message %T", msg)
+	}
+
+	attachments, err := attachmentsJSON(msgMap, credentialsAttachKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []*verifiable.Credential
+
+	for _, attachment := range attachments {
+		var vp struct {
+			Credentials []json.RawMessage `json:"verifiableCredential"`
+		}
+
+		raw := []json.RawMessage{attachment}
+
+		if json.Unmarshal(attachment, &vp) == nil && len(vp.Credentials) > 0 {
+			raw = vp.Credentials
+		}
+
+		for _, r := range raw {
+			vc, err := parse(r)
+			if err != nil {
+				return nil, err
+			}
+
+			credentials = append(credentials, vc)
+		}
+	}
+
+	return credentials, nil
+}
+
+// attachmentsJSON returns data of the message's attachments.
+func attachmentsJSON(msg service.DIDCommMsgMap, v1Key string) ([]json.RawMessage, error) {
+	raw, ok := msg[v1Key]
+	if !ok {
+		raw = msg[attachmentsKey]
+	}
+
+	rawBytes, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attachments : %w", err)
+	}
+
+	var attachments []struct {
+		Data decorator.AttachmentData `json:"data"`
+	}
+
+	err = json.Unmarshal(rawBytes, &attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachments : %w", err)
+	}
+
+	data := make([]json.RawMessage, 0, len(attachments))
+
+	for _, attachment := range attachments {
+		d, err := attachment.Data.Fetch()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment : %w", err)
+		}
+
+		data = append(data, d)
+	}
+
+	return data, nil
+}
+
+// actionID returns ID the agent keeps the message's protocol instance under, which is the parent thread ID of
+// messages sent in reply to OOB v2 invitation and the thread ID otherwise.
+func issueCredentialPIID(msg service.DIDCommMsg) (string, error) {
+	if pthID := msg.ParentThreadID(); pthID != "" {
+		return pthID, nil
+	}
+
+	return msg.ThreadID()
+}
+
+func parseInvitation(invitation json.RawMessage) (*wallet.GenericInvitation, error) {
+	var inv wallet.GenericInvitation
+
+	err := json.Unmarshal(invitation, &inv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse invitation : %w", err)
+	}
+
+	return &inv, nil
+}
@@ -0,0 +1,304 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/ldcontext/embed"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/web"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/wallet/test/mock/adapter/holder"
+)
+
+const holderTestCredentialType = "VerifiableCredential"
+
+func TestHolder_OIDCIssuanceAndOpenID4VCPresentation(t *testing.T) {
+	adapter := startHolderTestAdapter(t)
+	h := adapter.newHolder(t)
+
+	for _, format := range []string{"ldp_vc", "jwt_vc"} {
+		t.Run(format, func(t *testing.T) {
+			session := adapter.createSession(t, oidcIssuanceSessionPath, map[string]interface{}{
+				"wallet_init_issuance_url": "https://wallet.example.com/initiate",
+				"issuer_url":               adapter.url,
+				"credential_types":         []string{holderTestCredentialType},
+				"credentials": map[string]json.RawMessage{
+					holderTestCredentialType: newHolderTestCredential(h.DID()),
+				},
+			})
+
+			credentials, err := h.OIDCIssuance(session.Offer, format)
+			require.NoError(t, err)
+			require.Len(t, credentials, 1)
+			requireIssuedToHolder(t, credentials[0], h)
+
+			share := adapter.createSession(t, openid4vcShareSessionPath, map[string]interface{}{})
+
+			result, err := h.OpenID4VCPresentation(share.RequestURI, credentials...)
+			require.NoError(t, err)
+
+			var verification verificationResult
+
+			require.NoError(t, json.Unmarshal(result, &verification))
+			require.True(t, verification.Verified, string(result))
+		})
+	}
+}
+
+func TestHolder_OpenID4VCIssuance(t *testing.T) {
+	adapter := startHolderTestAdapter(t)
+	h := adapter.newHolder(t)
+
+	newSession := func(t *testing.T) *adminSession {
+		t.Helper()
+
+		return adapter.createSession(t, openid4vcIssuanceSessionPath, map[string]interface{}{
+			"issuer_url":      adapter.url,
+			"credential_type": holderTestCredentialType,
+			"credentials": map[string]json.RawMessage{
+				holderTestCredentialType: newHolderTestCredential(h.DID()),
+			},
+		})
+	}
+
+	for _, format := range []string{"ldp_vc", "jwt_vc"} {
+		t.Run(format, func(t *testing.T) {
+			session := newSession(t)
+
+			vc, err := h.OpenID4VCIssuance(session.Offer, session.Pin, format)
+			require.NoError(t, err)
+			requireIssuedToHolder(t, vc, h)
+		})
+	}
+
+	t.Run("wrong PIN", func(t *testing.T) {
+		session := newSession(t)
+
+		_, err := h.OpenID4VCIssuance(session.Offer, session.Pin+"0", "ldp_vc")
+
+		var respErr *holder.ResponseError
+
+		require.ErrorAs(t, err, &respErr)
+		require.Equal(t, http.StatusBadRequest, respErr.StatusCode)
+		require.Contains(t, respErr.Body, "request validation failed")
+	})
+}
+
+func TestHolder_WACI(t *testing.T) {
+	adapter := startHolderTestAdapter(t)
+	h := adapter.newHolder(t)
+
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			issuance := adapter.createSession(t, waciIssuanceSessionPath, map[string]interface{}{
+				"wallet_url":      "https://wallet.example.com",
+				"didcomm_version": version,
+				"credential_manifest": json.RawMessage(`{
+					"id": "manifest-1",
+					"version": "0.1.0",
+					"issuer": {"id": "did:example:issuer"},
+					"output_descriptors": [{"id": "od-1", "schema": "https://example.com/schema"}]
+				}`),
+				"credential": newHolderTestCredential(h.DID()),
+			})
+
+			issued, err := h.WACIIssuance(issuance.Invitation)
+			require.NoError(t, err)
+			require.Equal(t, "OK", issued.Status)
+			require.Equal(t, adapter.url+"/issuer/waci-issuance/", issued.RedirectURL[:len(adapter.url)+22])
+			require.Len(t, issued.Credentials, 1)
+			requireIssuedToHolder(t, issued.Credentials[0], h)
+
+			share := adapter.createSession(t, waciShareSessionPath, map[string]interface{}{
+				"wallet_url":      "https://wallet.example.com",
+				"didcomm_version": version,
+				"presentation_definition": json.RawMessage(`{
+					"id": "pd-1",
+					"input_descriptors": [{
+						"id": "family-name",
+						"schema": [{"uri": "https://www.w3.org/2018/credentials#VerifiableCredential"}],
+						"constraints": {"fields": [{"path": ["$.credentialSubject.familyName"]}]}
+					}]
+				}`),
+			})
+
+			shared, err := h.WACIShare(share.Invitation, issued.Credentials...)
+			require.NoError(t, err)
+			require.Equal(t, "OK", shared.Status)
+			require.True(t, strings.HasPrefix(shared.RedirectURL, adapter.url+"/verifier/waci-share/"),
+				shared.RedirectURL)
+		})
+	}
+}
+
+// holderTestAdapter is the adapter with its agent, started as main does. It serves HTTPS, so that its
+// DIDComm V2 DID can be did:web, which aries accepts OOB v2 invitations from unlike did:peer.
+type holderTestAdapter struct {
+	url        string
+	didCommURL string
+	http       *http.Client
+}
+
+func startHolderTestAdapter(t *testing.T) *holderTestAdapter {
+	t.Helper()
+
+	serveEmbeddedContexts(t)
+
+	router := mux.NewRouter()
+	server := httptest.NewUnstartedServer(router)
+
+	t.Cleanup(server.Close)
+
+	didCommAddr := freeTestAddr(t)
+
+	cfg := &adapterConfig{
+		ExternalURL:         "https://" + server.Listener.Addr().String(),
+		DIDCommInternalHost: didCommAddr,
+		DIDCommExternalHost: "http://" + didCommAddr,
+		KeyType:             kms.ED25519Type,
+		KeyAgreementType:    kms.NISTP256ECDHKWType,
+		StatusListCacheTTL:  defaultStatusListCacheTTL,
+		DatabaseType:        databaseTypeMemOption,
+		DIDCommV2DIDMethod:  didMethodWebOption,
+	}
+
+	storeProvider := mem.NewProvider()
+
+	agent, err := startAriesAgent(storeProvider, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, startAdapterApp(agent, router, storeProvider, cfg))
+
+	server.StartTLS()
+
+	return &holderTestAdapter{url: cfg.ExternalURL, didCommURL: cfg.DIDCommExternalHost, http: server.Client()}
+}
+
+// newHolder creates holder trusting the adapter's certificate, also when resolving the adapter's did:web, with
+// key agreement keys on the adapter's curve.
+func (a *holderTestAdapter) newHolder(t *testing.T) *holder.Holder {
+	t.Helper()
+
+	h, err := holder.New(holder.WithHTTPClient(a.http), holder.WithVDR(&webVDR{http: a.http, VDR: web.New()}),
+		holder.WithKeyAgreementType(kms.NISTP256ECDHKWType))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, h.Close()) })
+
+	return h
+}
+
+func (a *holderTestAdapter) createSession(t *testing.T, path string, request interface{}) *adminSession {
+	t.Helper()
+
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	resp, err := a.http.Post(a.url+path, "application/json", bytes.NewReader(body)) //nolint:noctx
+	require.NoError(t, err)
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(respBody))
+
+	var session adminSession
+
+	require.NoError(t, json.Unmarshal(respBody, &session))
+
+	return &session
+}
+
+// newHolderTestCredential returns unsigned credential issued by the adapter to the subject. Its terms are defined
+// inline so that it's processed without fetching contexts.
+func newHolderTestCredential(subject string) json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`{
+		"@context": [
+			"https://www.w3.org/2018/credentials/v1",
+			{"familyName": "https://schema.org/familyName", "givenName": "https://schema.org/givenName"}
+		],
+		"id": "urn:uuid:%s",
+		"type": ["VerifiableCredential"],
+		"issuer": "%s",
+		"issuanceDate": "2022-01-01T00:00:00Z",
+		"credentialSubject": {"id": "%s", "familyName": "Doe", "givenName": "Jane"}
+	}`, uuid.NewString(), didKey, subject))
+}
+
+func requireIssuedToHolder(t *testing.T, vc *verifiable.Credential, h *holder.Holder) {
+	t.Helper()
+
+	subjects, ok := vc.Subject.([]verifiable.Subject)
+	require.True(t, ok)
+	require.Len(t, subjects, 1)
+	require.Equal(t, h.DID(), subjects[0].ID)
+	require.Equal(t, didKey, vc.Issuer.ID)
+}
+
+// serveEmbeddedContexts serves JSON-LD contexts embedded in aries to the default HTTP client, which the adapter
+// loads contexts with, so that the tests run offline.
+func serveEmbeddedContexts(t *testing.T) {
+	t.Helper()
+
+	contexts := map[string][]byte{}
+
+	for _, c := range embed.Contexts {
+		contexts[c.URL] = c.Content
+	}
+
+	transport := http.DefaultClient.Transport
+
+	http.DefaultClient.Transport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if content, ok := contexts[r.URL.String()]; ok {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/ld+json"}},
+				Body:       io.NopCloser(bytes.NewReader(content)),
+				Request:    r,
+			}, nil
+		}
+
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func freeTestAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+
+	require.NoError(t, listener.Close())
+
+	return addr
+}
@@ -0,0 +1,420 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+)
+
+const (
+	messageDirectionInbound  = "inbound"
+	messageDirectionOutbound = "outbound"
+	messageDirectionAttached = "attached"
+
+	maxLoggedMessages      = 500
+	maxProtocolInstances   = 1000
+	protocolEventBufferLen = 100
+)
+
+var errMessengerNotReady = errors.New("messenger is not initialized yet")
+
+// loggedMessage is a DIDComm message sent or received by the adapter's agent.
+type loggedMessage struct {
+	Direction      string                `json:"direction"`
+	ID             string                `json:"id"`
+	Type           string                `json:"type"`
+	ThreadID       string                `json:"thread_id,omitempty"`
+	ParentThreadID string                `json:"parent_thread_id,omitempty"`
+	MyDID          string                `json:"my_did,omitempty"`
+	TheirDID       string                `json:"their_did,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	Time           time.Time             `json:"time"`
+	Message        service.DIDCommMsgMap `json:"message"`
+}
+
+// protocolInstance is the latest known state of a DID-exchange, present-proof or issue-credential instance.
+type protocolInstance struct {
+	Protocol       string    `json:"protocol"`
+	PIID           string    `json:"piid,omitempty"`
+	ThreadID       string    `json:"thread_id,omitempty"`
+	ParentThreadID string    `json:"parent_thread_id,omitempty"`
+	State          string    `json:"state"`
+	MessageType    string    `json:"message_type,omitempty"`
+	Updated        time.Time `json:"updated"`
+}
+
+// agentInspector keeps recent DIDComm messages and protocol states of the agent for debugging.
+type agentInspector struct {
+	mu        sync.RWMutex
+	messages  []*loggedMessage
+	instances map[string]*protocolInstance
+	observers []func(msg *loggedMessage)
+}
+
+func newAgentInspector() *agentInspector {
+	return &agentInspector{instances: map[string]*protocolInstance{}}
+}
+
+func (i *agentInspector) logMessage(direction string, msg service.DIDCommMsgMap, myDID, theirDID string, err error) {
+	entry := &loggedMessage{
+		Direction:      direction,
+		ID:             msg.ID(),
+		Type:           msg.Type(),
+		ParentThreadID: msg.ParentThreadID(),
+		MyDID:          myDID,
+		TheirDID:       theirDID,
+		Time:           time.Now(),
+		Message:        msg,
+	}
+
+	// a message starting a new thread has no thread decorator.
+	if thID, e := msg.ThreadID(); e == nil {
+		entry.ThreadID = thID
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	i.mu.Lock()
+
+	i.messages = append(i.messages, entry)
+	if len(i.messages) > maxLoggedMessages {
+		i.messages = i.messages[len(i.messages)-maxLoggedMessages:]
+	}
+
+	observers := i.observers
+
+	i.mu.Unlock()
+
+	for _, observe := range observers {
+		observe(entry)
+	}
+}
+
+// observe calls fn with every message logged from now on.
+func (i *agentInspector) observe(fn func(msg *loggedMessage)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.observers = append(i.observers, fn)
+}
+
+// listenForProtocolStates records post states of DIDComm protocols.
+func (i *agentInspector) listenForProtocolStates(stateCh chan service.StateMsg) {
+	for msg := range stateCh {
+		if msg.Type != service.PostState {
+			continue
+		}
+
+		instance := &protocolInstance{
+			Protocol: msg.ProtocolName,
+			State:    msg.StateID,
+			Updated:  time.Now(),
+		}
+
+		if msg.Properties != nil {
+			props := msg.Properties.All()
+			instance.PIID, _ = props["piid"].(string) //nolint:errcheck
+
+			if instance.PIID == "" {
+				instance.PIID, _ = props["connectionID"].(string) //nolint:errcheck
+			}
+		}
+
+		if msg.Msg != nil {
+			instance.MessageType = msg.Msg.Type()
+			instance.ParentThreadID = msg.Msg.ParentThreadID()
+
+			if thID, err := msg.Msg.ThreadID(); err == nil {
+				instance.ThreadID = thID
+			}
+		}
+
+		i.recordInstance(instance)
+	}
+}
+
+func (i *agentInspector) recordInstance(instance *protocolInstance) {
+	key := instance.Protocol + "/" + instance.PIID
+	if instance.PIID == "" {
+		key = instance.Protocol + "/" + instance.ThreadID
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.instances[key] = instance
+
+	if len(i.instances) <= maxProtocolInstances {
+		return
+	}
+
+	oldestKey := ""
+
+	for k, v := range i.instances {
+		if oldestKey == "" || v.Updated.Before(i.instances[oldestKey].Updated) {
+			oldestKey = k
+		}
+	}
+
+	delete(i.instances, oldestKey)
+}
+
+func (i *agentInspector) recentMessages(thID string) []*loggedMessage {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	messages := []*loggedMessage{}
+
+	for _, msg := range i.messages {
+		if thID == "" || msg.ThreadID == thID || msg.ParentThreadID == thID {
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages
+}
+
+func (i *agentInspector) protocolInstances(protocol string) []*protocolInstance {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	instances := []*protocolInstance{}
+
+	for _, instance := range i.instances {
+		if protocol == "" || instance.Protocol == protocol {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(a, b int) bool { return instances[a].Updated.After(instances[b].Updated) })
+
+	return instances
+}
+
+// recordingMessenger wraps the agent's messenger to log messages handled by protocol services. The wrapped
+// messenger needs the agent's outbound dispatcher, so it is set once the framework has started.
+// Messages sent to an invitation attachment DID are captured instead of being sent.
+type recordingMessenger struct {
+	inspector *agentInspector
+
+	mu          sync.RWMutex
+	inner       service.MessengerHandler
+	faults      *faultInjector
+	attachments map[string]service.DIDCommMsgMap
+}
+
+func (m *recordingMessenger) setMessenger(inner service.MessengerHandler) {
+	m.mu.Lock()
+	m.inner = inner
+	m.mu.Unlock()
+}
+
+// setFaultInjector makes outbound messages subject to fault rules.
+func (m *recordingMessenger) setFaultInjector(faults *faultInjector) {
+	m.mu.Lock()
+	m.faults = faults
+	m.mu.Unlock()
+}
+
+func (m *recordingMessenger) messenger() (service.MessengerHandler, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.inner == nil {
+		return nil, errMessengerNotReady
+	}
+
+	return m.inner, nil
+}
+
+// outboundMessenger returns the messenger to send the message with, or errFaultDropped if a fault rule drops it.
+func (m *recordingMessenger) outboundMessenger(msg service.DIDCommMsgMap) (service.MessengerHandler, error) {
+	m.mu.RLock()
+	faults := m.faults
+	m.mu.RUnlock()
+
+	if faults.outboundMessage(msg) {
+		return nil, errFaultDropped
+	}
+
+	return m.messenger()
+}
+
+// sendResult hides errFaultDropped from protocol services, which consider the dropped message sent.
+func sendResult(err error) error {
+	if errors.Is(err, errFaultDropped) {
+		return nil
+	}
+
+	return err
+}
+
+func (m *recordingMessenger) HandleInbound(msg service.DIDCommMsgMap, ctx service.DIDCommContext) error {
+	inner, err := m.messenger()
+	if err == nil {
+		err = inner.HandleInbound(msg, ctx)
+	}
+
+	m.inspector.logMessage(messageDirectionInbound, msg, ctx.MyDID(), ctx.TheirDID(), err)
+
+	return err
+}
+
+// ReplyTo is deprecated in aries messenger, kept to implement the interface.
+func (m *recordingMessenger) ReplyTo(msgID string, msg service.DIDCommMsgMap, opts ...service.Opt) error {
+	inner, err := m.outboundMessenger(msg)
+	if err == nil {
+		err = inner.ReplyTo(msgID, msg, opts...) //nolint:staticcheck
+	}
+
+	m.inspector.logMessage(messageDirectionOutbound, msg, "", "", err)
+
+	return sendResult(err)
+}
+
+func (m *recordingMessenger) ReplyToMsg(in, out service.DIDCommMsgMap, myDID, theirDID string,
+	opts ...service.Opt) error {
+	inner, err := m.outboundMessenger(out)
+	if err == nil {
+		err = inner.ReplyToMsg(in, out, myDID, theirDID, opts...)
+	}
+
+	m.inspector.logMessage(messageDirectionOutbound, out, myDID, theirDID, err)
+
+	return sendResult(err)
+}
+
+func (m *recordingMessenger) Send(msg service.DIDCommMsgMap, myDID, theirDID string, opts ...service.Opt) error {
+	// messages attached to invitations are kept instead of sent, see invitationAttachmentDIDPrefix.
+	if strings.HasPrefix(theirDID, invitationAttachmentDIDPrefix) {
+		m.captureAttachment(theirDID, msg)
+		m.inspector.logMessage(messageDirectionAttached, msg, myDID, "", nil)
+
+		return nil
+	}
+
+	inner, err := m.outboundMessenger(msg)
+	if err == nil {
+		err = inner.Send(msg, myDID, theirDID, opts...)
+	}
+
+	m.inspector.logMessage(messageDirectionOutbound, msg, myDID, theirDID, err)
+
+	return sendResult(err)
+}
+
+func (m *recordingMessenger) SendToDestination(msg service.DIDCommMsgMap, sender string,
+	destination *service.Destination, opts ...service.Opt) error {
+	inner, err := m.outboundMessenger(msg)
+	if err == nil {
+		err = inner.SendToDestination(msg, sender, destination, opts...)
+	}
+
+	m.inspector.logMessage(messageDirectionOutbound, msg, sender, "", err)
+
+	return sendResult(err)
+}
+
+func (m *recordingMessenger) ReplyToNested(msg service.DIDCommMsgMap, opts *service.NestedReplyOpts) error {
+	inner, err := m.outboundMessenger(msg)
+	if err == nil {
+		err = inner.ReplyToNested(msg, opts)
+	}
+
+	m.inspector.logMessage(messageDirectionOutbound, msg, opts.MyDID, opts.TheirDID, err)
+
+	return sendResult(err)
+}
+
+func (m *recordingMessenger) captureAttachment(key string, msg service.DIDCommMsgMap) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.attachments == nil {
+		m.attachments = map[string]service.DIDCommMsgMap{}
+	}
+
+	m.attachments[key] = msg
+}
+
+// takeAttachment returns and forgets the message captured for the invitation attachment DID.
+func (m *recordingMessenger) takeAttachment(key string) (service.DIDCommMsgMap, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, ok := m.attachments[key]
+	delete(m.attachments, key)
+
+	return msg, ok
+}
+
+func (v *adapterApp) listConnections(w http.ResponseWriter, r *http.Request) {
+	connections, err := v.agent.DIDExchClient.QueryConnections(&didexchange.QueryConnectionsParams{
+		State:        r.URL.Query().Get("state"),
+		InvitationID: r.URL.Query().Get("invitation_id"),
+	})
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to query connections : %s", err))
+
+		return
+	}
+
+	writeJSON(w, connections)
+}
+
+func (v *adapterApp) listProtocolInstances(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, v.agent.Inspector.protocolInstances(r.URL.Query().Get("protocol")))
+}
+
+func (v *adapterApp) listPendingActions(w http.ResponseWriter, _ *http.Request) {
+	presentProofActions, err := v.agent.PresentProofClient.Actions()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get present-proof actions : %s", err))
+
+		return
+	}
+
+	issueCredentialActions, err := v.agent.IssueCredentialClient.Actions()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get issue-credential actions : %s", err))
+
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"present_proof":    presentProofActions,
+		"issue_credential": issueCredentialActions,
+	})
+}
+
+func (v *adapterApp) listMessages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, v.agent.Inspector.recentMessages(r.URL.Query().Get("thread_id")))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Errorf("failed to write response : %s", err)
+	}
+}
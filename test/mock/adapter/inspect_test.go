@@ -0,0 +1,105 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+)
+
+type stubMessenger struct {
+	service.MessengerHandler
+	err error
+}
+
+func (m *stubMessenger) Send(service.DIDCommMsgMap, string, string, ...service.Opt) error {
+	return m.err
+}
+
+func TestRecordingMessenger(t *testing.T) {
+	inspector := newAgentInspector()
+	recorder := &recordingMessenger{inspector: inspector}
+
+	msg := service.NewDIDCommMsgMap(struct {
+		ID     string            `json:"@id"`
+		Type   string            `json:"@type"`
+		Thread map[string]string `json:"~thread"`
+	}{
+		ID:     "msg-1",
+		Type:   "https://didcomm.org/present-proof/2.0/request-presentation",
+		Thread: map[string]string{"thid": "thread-1", "pthid": "invitation-1"},
+	})
+
+	t.Run("messenger not ready", func(t *testing.T) {
+		err := recorder.Send(msg, "did:example:me", "did:example:them")
+		require.ErrorIs(t, err, errMessengerNotReady)
+	})
+
+	recorder.setMessenger(&stubMessenger{err: errors.New("send failed")})
+
+	t.Run("send error is logged", func(t *testing.T) {
+		err := recorder.Send(msg, "did:example:me", "did:example:them")
+		require.EqualError(t, err, "send failed")
+	})
+
+	recorder.setMessenger(&stubMessenger{})
+
+	t.Run("messages filtered by thread", func(t *testing.T) {
+		require.NoError(t, recorder.Send(msg, "did:example:me", "did:example:them"))
+
+		messages := inspector.recentMessages("thread-1")
+		require.Len(t, messages, 3)
+		require.Equal(t, errMessengerNotReady.Error(), messages[0].Error)
+		require.Equal(t, "send failed", messages[1].Error)
+		require.Empty(t, messages[2].Error)
+		require.Equal(t, messageDirectionOutbound, messages[2].Direction)
+		require.Equal(t, "invitation-1", messages[2].ParentThreadID)
+
+		require.Len(t, inspector.recentMessages("invitation-1"), 3)
+		require.Empty(t, inspector.recentMessages("thread-2"))
+	})
+}
+
+func TestAgentInspector_ProtocolStates(t *testing.T) {
+	inspector := newAgentInspector()
+
+	stateCh := make(chan service.StateMsg)
+	done := make(chan struct{})
+
+	go func() {
+		inspector.listenForProtocolStates(stateCh)
+		close(done)
+	}()
+
+	for _, state := range []string{"request-sent", "presentation-received", "done"} {
+		stateCh <- service.StateMsg{
+			ProtocolName: "present-proof",
+			Type:         service.PostState,
+			StateID:      state,
+			Msg: service.DIDCommMsgMap{
+				"@id":     "msg-" + state,
+				"@type":   "https://didcomm.org/present-proof/2.0/" + state,
+				"~thread": map[string]interface{}{"thid": "thread-1"},
+			},
+		}
+	}
+
+	stateCh <- service.StateMsg{ProtocolName: "issue-credential", Type: service.PreState, StateID: "offer-sent"}
+
+	close(stateCh)
+	<-done
+
+	require.Empty(t, inspector.protocolInstances("issue-credential"))
+
+	instances := inspector.protocolInstances("present-proof")
+	require.Len(t, instances, 1)
+	require.Equal(t, "done", instances[0].State)
+	require.Equal(t, "thread-1", instances[0].ThreadID)
+}
@@ -0,0 +1,315 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+)
+
+// waciIssuanceState is the state of a WACI issuance interaction.
+type waciIssuanceState string
+
+const (
+	waciIssuanceInvited   waciIssuanceState = "invited"
+	waciIssuanceProposed  waciIssuanceState = "proposed"
+	waciIssuanceOffered   waciIssuanceState = "offered"
+	waciIssuanceRequested waciIssuanceState = "requested"
+	waciIssuanceIssued    waciIssuanceState = "issued"
+	waciIssuanceAcked     waciIssuanceState = "acked"
+	waciIssuanceAbandoned waciIssuanceState = "abandoned"
+)
+
+// issue-credential protocol states reported through message events.
+const (
+	issueCredentialStateProposalReceived = "proposal-received"
+	issueCredentialStateOfferSent        = "offer-sent"
+	issueCredentialStateRequestReceived  = "request-received"
+	issueCredentialStateCredentialIssued = "credential-issued"
+	issueCredentialStateAbandoning       = "abandoning"
+	issueCredentialStateDone             = "done"
+)
+
+const issuanceStateEventBufferSize = 100
+
+// waciIssuanceTransition is a single state change of WACI issuance interaction.
+type waciIssuanceTransition struct {
+	State       waciIssuanceState `json:"state"`
+	MessageType string            `json:"message_type,omitempty"`
+	Time        time.Time         `json:"time"`
+}
+
+// waciIssuanceStatus tracks WACI issuance interaction from invitation to its final state.
+type waciIssuanceStatus struct {
+	InvitationID string                    `json:"invitation_id,omitempty"`
+	ThreadID     string                    `json:"thread_id,omitempty"`
+	State        waciIssuanceState         `json:"state"`
+	Reason       string                    `json:"reason,omitempty"`
+	History      []*waciIssuanceTransition `json:"history"`
+}
+
+// succeeded tells whether credential was delivered to the holder.
+func (s *waciIssuanceStatus) succeeded() bool {
+	return s.State == waciIssuanceIssued || s.State == waciIssuanceAcked
+}
+
+func (v *adapterApp) listenForIssuanceStates(stateCh chan service.StateMsg) {
+	for msg := range stateCh {
+		if msg.ProtocolName != issuecredential.Name || msg.Type != service.PostState {
+			continue
+		}
+
+		err := v.handleIssuanceState(msg)
+		if err != nil {
+			logger.Errorf("failed to track WACI issuance state : state=%s err=%s", msg.StateID, err)
+		}
+	}
+}
+
+func (v *adapterApp) handleIssuanceState(msg service.StateMsg) error {
+	if msg.Msg == nil {
+		return nil
+	}
+
+	thID, err := msg.Msg.ThreadID()
+	if err != nil {
+		return fmt.Errorf("failed to get thread ID : %w", err)
+	}
+
+	var (
+		state  waciIssuanceState
+		reason string
+	)
+
+	switch msg.StateID {
+	case issueCredentialStateProposalReceived:
+		invitationID, e := v.issuanceInvitationID(msg)
+		if e != nil {
+			return e
+		}
+
+		err = v.linkWACIIssuanceThread(invitationID, thID)
+		if err != nil {
+			return err
+		}
+
+		state = waciIssuanceProposed
+	case issueCredentialStateOfferSent:
+		state = waciIssuanceOffered
+	case issueCredentialStateRequestReceived:
+		state = waciIssuanceRequested
+	case issueCredentialStateCredentialIssued:
+		state = waciIssuanceIssued
+
+		v.countWACICredentialIssued(thID)
+	case issueCredentialStateAbandoning:
+		state = waciIssuanceAbandoned
+		reason = v.issuanceAbandonReason(msg, thID)
+	case issueCredentialStateDone:
+		if msg.Msg.Type() != issuecredential.AckMsgTypeV2 && msg.Msg.Type() != issuecredential.AckMsgTypeV3 {
+			return nil
+		}
+
+		state = waciIssuanceAcked
+	default:
+		return nil
+	}
+
+	return v.updateWACIIssuanceState(thID, state, msg.Msg.Type(), reason)
+}
+
+// issuanceInvitationID returns ID of the invitation the proposal answers, resolved as for the proposal action.
+func (v *adapterApp) issuanceInvitationID(msg service.StateMsg) (string, error) {
+	invitationID := v.messageInvitationID(msg.Msg, msg.Properties)
+
+	if invitationID == "" {
+		var body struct {
+			InvitationID string `json:"invitationID"`
+		}
+
+		if err := msg.Msg.Decode(&body); err == nil {
+			invitationID = body.InvitationID
+		}
+	}
+
+	if invitationID == "" {
+		return "", errors.New("failed to find invitation of issue-credential thread")
+	}
+
+	return invitationID, nil
+}
+
+// countWACICredentialIssued counts issued credential by the session's credential format.
+func (v *adapterApp) countWACICredentialIssued(thID string) {
+	format := credentialFormatManifest
+
+	if waciData, err := readWACIIssuanceData(v.store, thID, ""); err == nil && waciData.CredentialFormat != "" {
+		format = waciData.CredentialFormat
+	}
+
+	v.metrics.credentialIssued(protocolWACI, format)
+}
+
+// issuanceAbandonReason returns why issuance was abandoned, either by the holder's problem report or by
+// the adapter stopping the protocol.
+func (v *adapterApp) issuanceAbandonReason(msg service.StateMsg, thID string) string {
+	if failure := v.readDIDCommFailure(thID); failure != nil {
+		return failure.Reason
+	}
+
+	switch msg.Msg.Type() {
+	case issuecredential.ProblemReportMsgTypeV2:
+		report := struct {
+			Description struct {
+				Code string `json:"code"`
+			} `json:"description"`
+		}{}
+
+		if err := msg.Msg.Decode(&report); err == nil && report.Description.Code != "" {
+			return "problem report from holder : " + report.Description.Code
+		}
+
+		return "problem report from holder"
+	case issuecredential.ProblemReportMsgTypeV3:
+		report := struct {
+			Body struct {
+				Code string `json:"code"`
+			} `json:"body"`
+		}{}
+
+		if err := msg.Msg.Decode(&report); err == nil && report.Body.Code != "" {
+			return "problem report from holder : " + report.Body.Code
+		}
+
+		return "problem report from holder"
+	}
+
+	if props, ok := msg.Properties.(interface{ Err() error }); ok && props.Err() != nil {
+		return props.Err().Error()
+	}
+
+	return "issuance abandoned"
+}
+
+// startWACIIssuance records new WACI issuance invitation.
+func (v *adapterApp) startWACIIssuance(invitationID string) error {
+	v.issuanceMu.Lock()
+	defer v.issuanceMu.Unlock()
+
+	return v.saveWACIIssuanceStatus(invitationID, &waciIssuanceStatus{
+		InvitationID: invitationID,
+		State:        waciIssuanceInvited,
+		History:      []*waciIssuanceTransition{{State: waciIssuanceInvited, Time: time.Now()}},
+	})
+}
+
+// linkWACIIssuanceThread links issue-credential thread to the invitation it belongs to. States already recorded
+// for the thread are kept, as the offer attached to a connectionless invitation is sent before it is linked.
+func (v *adapterApp) linkWACIIssuanceThread(invitationID, thID string) error {
+	v.issuanceMu.Lock()
+	defer v.issuanceMu.Unlock()
+
+	status, err := v.readWACIIssuanceStatus(invitationID)
+	if err != nil {
+		status = &waciIssuanceStatus{InvitationID: invitationID}
+	}
+
+	if status.ThreadID == thID {
+		return nil
+	}
+
+	if thread, e := v.readWACIIssuanceStatus(thID); e == nil {
+		status.State = thread.State
+		status.Reason = thread.Reason
+		status.History = append(status.History, thread.History...)
+	}
+
+	status.ThreadID = thID
+
+	err = v.saveWACIIssuanceStatus(invitationID, status)
+	if err != nil {
+		return err
+	}
+
+	return v.saveWACIIssuanceStatus(thID, status)
+}
+
+func (v *adapterApp) updateWACIIssuanceState(thID string, state waciIssuanceState, msgType, reason string) error {
+	v.issuanceMu.Lock()
+	defer v.issuanceMu.Unlock()
+
+	status, err := v.readWACIIssuanceStatus(thID)
+	if err != nil {
+		status = &waciIssuanceStatus{ThreadID: thID}
+	}
+
+	status.State = state
+	status.Reason = reason
+	status.History = append(status.History,
+		&waciIssuanceTransition{State: state, MessageType: msgType, Time: time.Now()})
+
+	return v.saveWACIIssuanceStatus(thID, status)
+}
+
+func (v *adapterApp) saveWACIIssuanceStatus(id string, status *waciIssuanceStatus) error {
+	statusBytes, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal WACI issuance state : %w", err)
+	}
+
+	return v.store.Put(getWACIIssuanceStateKeyPrefix(id), statusBytes)
+}
+
+// readWACIIssuanceStatus returns issuance status by thread ID or invitation ID. Invitations answered by the
+// holder resolve to the status of their thread.
+func (v *adapterApp) readWACIIssuanceStatus(id string) (*waciIssuanceStatus, error) {
+	statusBytes, err := v.store.Get(getWACIIssuanceStateKeyPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var status waciIssuanceStatus
+
+	err = json.Unmarshal(statusBytes, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal WACI issuance state : %w", err)
+	}
+
+	if status.ThreadID != "" && status.ThreadID != id {
+		return v.readWACIIssuanceStatus(status.ThreadID)
+	}
+
+	return &status, nil
+}
+
+func (v *adapterApp) waciIssuanceState(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	v.issuanceMu.Lock()
+	status, err := v.readWACIIssuanceStatus(id)
+	v.issuanceMu.Unlock()
+
+	if err != nil {
+		handleError(w, http.StatusNotFound,
+			fmt.Sprintf("failed to get WACI issuance state : %s", err))
+
+		return
+	}
+
+	writeJSON(w, status)
+}
+
+func getWACIIssuanceStateKeyPrefix(key string) string {
+	return fmt.Sprintf("waci_issuance_state_%s", key)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/util"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/piprate/json-gold/ld"
+)
+
+const (
+	// credentialFormatManifest issues credentials with DIF credential manifest and credential response.
+	credentialFormatManifest = "credential-manifest"
+	// credentialFormatLDProof issues credentials with Aries RFC 0593 JSON-LD credential attachments.
+	credentialFormatLDProof = "ld-proof"
+
+	ldProofVCDetailFormat = "aries/ld-proof-vc-detail@v1.0"
+	ldProofVCFormat       = "aries/ld-proof-vc@v1.0"
+
+	ldProofType    = "Ed25519Signature2018"
+	ldProofPurpose = "assertionMethod"
+)
+
+// ldProofVCDetail is the "aries/ld-proof-vc-detail@v1.0" attachment, describing credential to be issued and
+// the proof the issuer is going to add.
+type ldProofVCDetail struct {
+	Credential json.RawMessage         `json:"credential"`
+	Options    *ldProofVCDetailOptions `json:"options"`
+}
+
+type ldProofVCDetailOptions struct {
+	ProofType    string `json:"proofType"`
+	ProofPurpose string `json:"proofPurpose,omitempty"`
+}
+
+// createLDProofOfferCredentialMsg offers the unsigned credential as "aries/ld-proof-vc-detail@v1.0".
+func createLDProofOfferCredentialMsg(credential []byte) (*issuecredential.OfferCredentialParams, error) {
+	vc, err := verifiable.ParseCredential(credential, verifiable.WithJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)),
+		verifiable.WithDisabledProofCheck())
+	if err != nil {
+		return nil, err
+	}
+
+	// offered credential detail is unsigned.
+	vc.Proofs = nil
+
+	vcBytes, err := vc.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	attachID := uuid.New().String()
+
+	return &issuecredential.OfferCredentialParams{
+		Type:    issuecredential.OfferCredentialMsgTypeV2,
+		Comment: "Offer to issue credential",
+		Formats: []issuecredential.Format{{
+			AttachID: attachID,
+			Format:   ldProofVCDetailFormat,
+		}},
+		Attachments: []decorator.GenericAttachment{{
+			ID:        attachID,
+			Format:    ldProofVCDetailFormat,
+			MediaType: "application/json",
+			Data: decorator.AttachmentData{
+				JSON: &ldProofVCDetail{
+					Credential: vcBytes,
+					Options: &ldProofVCDetailOptions{
+						ProofType:    ldProofType,
+						ProofPurpose: ldProofPurpose,
+					},
+				},
+			},
+		}},
+	}, nil
+}
+
+// createLDProofIssueCredentialMsg issues the signed credential as "aries/ld-proof-vc@v1.0".
+func createLDProofIssueCredentialMsg(credential []byte, subjectID,
+	redirect string) (*issuecredential.IssueCredentialParams, error) {
+	vc, err := verifiable.ParseCredential(credential, verifiable.WithJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)),
+		verifiable.WithDisabledProofCheck())
+	if err != nil {
+		return nil, err
+	}
+
+	vc.Proofs = nil
+
+	if vc.ID == "" {
+		vc.ID = "urn:uuid:" + uuid.NewString()
+	}
+
+	if subjectID != "" {
+		err = setCredentialSubjectID(vc, subjectID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if vc.Issued == nil {
+		vc.Issued = util.NewTime(time.Now())
+	}
+
+	err = signCredentialWithED25519(vc)
+	if err != nil {
+		return nil, err
+	}
+
+	attachID := uuid.New().String()
+
+	return &issuecredential.IssueCredentialParams{
+		Type: issuecredential.IssueCredentialMsgTypeV2,
+		Formats: []issuecredential.Format{{
+			AttachID: attachID,
+			Format:   ldProofVCFormat,
+		}},
+		Attachments: []decorator.GenericAttachment{{
+			ID:        attachID,
+			Format:    ldProofVCFormat,
+			MediaType: "application/ld+json",
+			Data: decorator.AttachmentData{
+				JSON: vc,
+			},
+		}},
+		WebRedirect: &decorator.WebRedirect{
+			Status: "OK",
+			URL:    redirect,
+		},
+	}, nil
+}
+
+// ldProofRequestedSubjectID returns credential subject ID the holder asked for in the
+// "aries/ld-proof-vc-detail@v1.0" attachment of request-credential, so that the credential is bound to the
+// holder's DID. Returns empty string if the request has no such attachment.
+func ldProofRequestedSubjectID(msg service.DIDCommMsg) (string, error) {
+	detailBytes, err := attachmentByFormat(msg, ldProofVCDetailFormat)
+	if err != nil || detailBytes == nil {
+		return "", err
+	}
+
+	var detail struct {
+		Credential struct {
+			Subject json.RawMessage `json:"credentialSubject"`
+		} `json:"credential"`
+	}
+
+	err = json.Unmarshal(detailBytes, &detail)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal credential detail : %w", err)
+	}
+
+	var subject struct {
+		ID string `json:"id"`
+	}
+
+	// credential subject may also be an array or a plain ID, which do not bind the credential.
+	if json.Unmarshal(detail.Credential.Subject, &subject) != nil {
+		return "", nil
+	}
+
+	return subject.ID, nil
+}
+
+func setCredentialSubjectID(vc *verifiable.Credential, subjectID string) error {
+	switch subject := vc.Subject.(type) {
+	case []verifiable.Subject:
+		if len(subject) != 1 {
+			return fmt.Errorf("credential must have exactly one subject to bind, got %d", len(subject))
+		}
+
+		subject[0].ID = subjectID
+	case map[string]interface{}:
+		subject["id"] = subjectID
+	case string:
+		vc.Subject = subjectID
+	default:
+		return fmt.Errorf("unsupported credential subject type %T", vc.Subject)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDProofRequestedSubjectID(t *testing.T) {
+	detail := map[string]interface{}{
+		"credential": map[string]interface{}{
+			"credentialSubject": map[string]interface{}{"id": "did:example:holder"},
+		},
+		"options": map[string]interface{}{"proofType": ldProofType},
+	}
+
+	t.Run("issue credential v2", func(t *testing.T) {
+		subjectID, err := ldProofRequestedSubjectID(service.DIDCommMsgMap{
+			"@id":     "request-1",
+			"@type":   issuecredential.RequestCredentialMsgTypeV2,
+			"formats": []interface{}{map[string]interface{}{"attach_id": "a1", "format": ldProofVCDetailFormat}},
+			"requests~attach": []interface{}{map[string]interface{}{
+				"@id":  "a1",
+				"data": map[string]interface{}{"json": detail},
+			}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "did:example:holder", subjectID)
+	})
+
+	t.Run("issue credential v3", func(t *testing.T) {
+		subjectID, err := ldProofRequestedSubjectID(service.DIDCommMsgMap{
+			"id":   "request-1",
+			"type": issuecredential.RequestCredentialMsgTypeV3,
+			"attachments": []interface{}{map[string]interface{}{
+				"id":     "a1",
+				"format": ldProofVCDetailFormat,
+				"data":   map[string]interface{}{"json": detail},
+			}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "did:example:holder", subjectID)
+	})
+
+	t.Run("no credential detail", func(t *testing.T) {
+		subjectID, err := ldProofRequestedSubjectID(service.DIDCommMsgMap{
+			"@id":   "request-1",
+			"@type": issuecredential.RequestCredentialMsgTypeV2,
+		})
+		require.NoError(t, err)
+		require.Empty(t, subjectID)
+	})
+}
+
+func TestCreateLDProofIssueCredentialMsg(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	issue := func(t *testing.T, credential json.RawMessage, subjectID string) *verifiable.Credential {
+		t.Helper()
+
+		msg, err := createLDProofIssueCredentialMsg(credential, subjectID, "https://adapter.example.com/done")
+		require.NoError(t, err)
+		require.Len(t, msg.Attachments, 1)
+
+		vc, ok := msg.Attachments[0].Data.JSON.(*verifiable.Credential)
+		require.True(t, ok)
+		require.Len(t, vc.Proofs, 1)
+
+		return vc
+	}
+
+	t.Run("credential ID is kept", func(t *testing.T) {
+		credential := newHolderTestCredential("did:example:holder")
+
+		var raw struct {
+			ID string `json:"id"`
+		}
+
+		require.NoError(t, json.Unmarshal(credential, &raw))
+
+		vc := issue(t, credential, "")
+		require.Equal(t, raw.ID, vc.ID)
+	})
+
+	t.Run("credential without ID gets one", func(t *testing.T) {
+		var credential map[string]interface{}
+
+		require.NoError(t, json.Unmarshal(newHolderTestCredential("did:example:holder"), &credential))
+		delete(credential, "id")
+
+		credentialBytes, err := json.Marshal(credential)
+		require.NoError(t, err)
+
+		vc := issue(t, credentialBytes, "did:example:requested")
+		require.True(t, strings.HasPrefix(vc.ID, "urn:uuid:"), vc.ID)
+
+		subjects, ok := vc.Subject.([]verifiable.Subject)
+		require.True(t, ok)
+		require.Equal(t, "did:example:requested", subjects[0].ID)
+	})
+}
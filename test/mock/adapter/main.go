@@ -8,32 +8,129 @@ package main
 
 import (
 	"fmt"
-	"github.com/rs/cors"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+	"github.com/spf13/cobra"
+	"github.com/trustbloc/wallet/test/mock/common/tlsmode"
+)
+
+const (
+	demoPortEnvKey              = "DEMO_PORT"
+	demoExternalURLEnvKey       = "EXTERNAL_URL"
+	didCommInternalHostEnvKey   = "INTERNAL_DIDCOMM_HOST"
+	didCommExternalHostEnvKey   = "EXTERNAL_DIDCOMM_HOST"
+	didCommWSInternalHostEnvKey = "INTERNAL_DIDCOMM_WS_HOST"
+	didCommWSExternalHostEnvKey = "EXTERNAL_DIDCOMM_WS_HOST"
+	tlsKeyFileEnvKey            = "TLS_KEY_FILE"
+	tlsCertFileEnvKey           = "TLS_CERT_FILE"
+	tlsCACertsEnvKey            = "TLS_CACERTS"
+	tlsModeEnvKey               = "TLS_MODE"
+	tlsHostnamesEnvKey          = "TLS_HOSTNAMES"
+	tlsAutoDirEnvKey            = "TLS_AUTO_DIR"
+	orbDomainEnvKey             = "ORB_DOMAIN"
+	contextProviderEnvKey       = "CONTEXT_PROVIDER_URL"
+	keyTypeEnvKey               = "KEY_TYPE"
+	keyAgreementTypeEnvKey      = "KEY_AGREEMENT_TYPE"
+	statusListCacheTTLEnvKey    = "STATUS_LIST_CACHE_TTL"
+	verifierProfilesFileEnvKey  = "VERIFIER_PROFILES_FILE"
+	databaseTypeEnvKey          = "DATABASE_TYPE"
+	databaseURLEnvKey           = "DATABASE_URL"
+	databasePrefixEnvKey        = "DATABASE_PREFIX"
+	didCommV2DIDMethodEnvKey    = "DIDCOMM_V2_DID_METHOD"
+	recordDirEnvKey             = "RECORD_DIR"
+	replayFileEnvKey            = "REPLAY_FILE"
+
+	// legacyExternalURLEnvKey is the misspelled name of EXTERNAL_URL, still read for existing deployments.
+	legacyExternalURLEnvKey = "EXTRERAL_URL"
+
+	autoTLSCAName = "mock-adapter local CA"
 )
 
 const (
-	demoPortEnvKey            = "DEMO_PORT"
-	demoExternalURLEnvKey     = "EXTRERAL_URL"
-	didCommInternalHostEnvKey = "INTERNAL_DIDCOMM_HOST"
-	didCommExternalHostEnvKey = "EXTERNAL_DIDCOMM_HOST"
-	tlsKeyFileEnvKey          = "TLS_KEY_FILE"
-	tlsCertFileEnvKey         = "TLS_CERT_FILE"
-	tlsCACertsEnvKey          = "TLS_CACERTS"
-	orbDomainEnvKey           = "ORB_DOMAIN"
-	contextProviderEnvKey     = "CONTEXT_PROVIDER_URL"
-	keyTypeEnvKey             = "KEY_TYPE"
-	keyAgreementTypeEnvKey    = "KEY_AGREEMENT_TYPE"
+	demoPortFlagName              = "port"
+	demoExternalURLFlagName       = "external-url"
+	didCommInternalHostFlagName   = "didcomm-internal-host"
+	didCommExternalHostFlagName   = "didcomm-external-host"
+	didCommWSInternalHostFlagName = "didcomm-ws-internal-host"
+	didCommWSExternalHostFlagName = "didcomm-ws-external-host"
+	tlsKeyFileFlagName            = "tls-key-file"
+	tlsCertFileFlagName           = "tls-cert-file"
+	tlsCACertsFlagName            = "tls-cacerts"
+	tlsModeFlagName               = "tls-mode"
+	tlsHostnamesFlagName          = "tls-hostnames"
+	tlsAutoDirFlagName            = "tls-auto-dir"
+	orbDomainFlagName             = "orb-domain"
+	contextProviderFlagName       = "context-provider-url"
+	keyTypeFlagName               = "key-type"
+	keyAgreementTypeFlagName      = "key-agreement-type"
+	statusListCacheTTLFlagName    = "status-list-cache-ttl"
+	verifierProfilesFileFlagName  = "verifier-profiles-file"
+	databaseTypeFlagName          = "database-type"
+	databaseURLFlagName           = "database-url"
+	databasePrefixFlagName        = "database-prefix"
+	didCommV2DIDMethodFlagName    = "didcomm-v2-did-method"
+	recordDirFlagName             = "record-dir"
+	replayFileFlagName            = "replay-file"
 )
 
 func main() {
+	err := newStartCmd().Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func newStartCmd() *cobra.Command {
+	cfg := &adapterConfig{}
+
+	cmd := &cobra.Command{
+		Use:   "mock-adapter",
+		Short: "Mock issuer and verifier adapter",
+		Long: "Mock issuer and verifier adapter demonstrating WACI, OIDC and OpenID4VC flows. Settings are read " +
+			"from command line flags, environment variables and the config file, in this order of precedence.",
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return cfg.load(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger.Infof("effective config:\n%s", cfg.redacted())
+
+			return startAdapter(cfg)
+		},
+	}
+
+	cfg.addFlags(cmd)
+
+	return cmd
+}
+
+func startAdapter(cfg *adapterConfig) error {
+	// DIDComm inbound transports read the certificate files once, so the generated files are used by both.
+	if cfg.TLSMode == tlsmode.Auto {
+		certFile, keyFile, err := tlsmode.GenerateAuto(cfg.TLSAutoDir, autoTLSCAName, cfg.autoTLSHostnames())
+		if err != nil {
+			return fmt.Errorf("failed to generate TLS certificate : %w", err)
+		}
+
+		cfg.TLSCertFile, cfg.TLSKeyFile = certFile, keyFile
+
+		logger.Infof("generated TLS certificate %s, trust CA %s to connect", certFile,
+			filepath.Join(cfg.TLSAutoDir, tlsmode.CACertFile))
+	}
+
+	storeProvider, err := createStoreProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create store provider : %w", err)
+	}
+
 	// initiate aries framework go options
-	agent, err := startAriesAgent()
+	agent, err := startAriesAgent(storeProvider, cfg)
 	if err != nil {
-		panic(fmt.Errorf("failed to start aries-agent : %w", err))
+		return fmt.Errorf("failed to start aries-agent : %w", err)
 	}
 
 	router := mux.NewRouter()
@@ -43,24 +140,17 @@ func main() {
 	router.Handle("/", fs)
 
 	// host demo sample ui pages
-	err = startAdapterApp(agent, router)
+	err = startAdapterApp(agent, router, storeProvider, cfg)
 	if err != nil {
-		panic(fmt.Errorf("failed to get verifier-app : %w", err))
-	}
-
-	// start the server
-	port := os.Getenv(demoPortEnvKey)
-	if port == "" {
-		panic("port to be served not provided")
+		return fmt.Errorf("failed to get verifier-app : %w", err)
 	}
 
-	handler := cors.New(
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: cors.New(
 		cors.Options{
-			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
 			AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "Authorization"},
 		},
-	).Handler(router)
+	).Handler(router)}
 
-	fmt.Println(http.ListenAndServeTLS(":"+port, os.Getenv(tlsCertFileEnvKey),
-		os.Getenv(tlsKeyFileEnvKey), handler))
+	return tlsmode.ListenAndServe(srv, cfg.TLSMode, cfg.TLSCertFile, cfg.TLSKeyFile, logger)
 }
@@ -0,0 +1,314 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messaging/service/basic"
+)
+
+const (
+	trustPingMsgType           = "https://didcomm.org/trust_ping/1.0/ping"
+	trustPingResponseMsgType   = "https://didcomm.org/trust_ping/1.0/ping_response"
+	trustPingMsgTypeV2         = "https://didcomm.org/trust-ping/2.0/ping"
+	trustPingResponseMsgTypeV2 = "https://didcomm.org/trust-ping/2.0/ping-response"
+	basicMessageMsgType        = basic.MessageRequestType
+	basicMessageMsgTypeV2      = "https://didcomm.org/basicmessage/2.0/message"
+
+	basicMessageEchoPrefix = "echo: "
+	maxBasicMessages       = 100
+)
+
+var errEmptyBasicMessage = errors.New("basic message content is empty")
+
+// didCommMessageService handles DIDComm messages which are not part of the agent's protocol services.
+type didCommMessageService struct {
+	name     string
+	msgTypes []string
+	handle   func(msg service.DIDCommMsgMap, ctx service.DIDCommContext) error
+}
+
+func (s *didCommMessageService) Name() string {
+	return s.name
+}
+
+func (s *didCommMessageService) Accept(msgType string, _ []string) bool {
+	for _, t := range s.msgTypes {
+		if t == msgType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *didCommMessageService) HandleInbound(msg service.DIDCommMsg, ctx service.DIDCommContext) (string, error) {
+	return "", s.handle(msg.Clone(), ctx)
+}
+
+// basicMessage is a basic message exchanged with a connection.
+type basicMessage struct {
+	ID        string    `json:"id"`
+	Direction string    `json:"direction"`
+	Content   string    `json:"content"`
+	Time      time.Time `json:"time"`
+}
+
+// handleTrustPing answers trust ping, unless the sender asked for no response.
+func (v *adapterApp) handleTrustPing(msg service.DIDCommMsgMap, ctx service.DIDCommContext) error {
+	ping := struct {
+		ResponseRequested *bool `json:"response_requested"`
+		Body              struct {
+			ResponseRequested *bool `json:"response_requested"`
+		} `json:"body"`
+	}{}
+
+	err := msg.Decode(&ping)
+	if err != nil {
+		return fmt.Errorf("failed to decode trust ping : %w", err)
+	}
+
+	var (
+		response service.DIDCommMsgMap
+		version  = service.V1
+	)
+
+	if msg.Type() == trustPingMsgTypeV2 {
+		if ping.Body.ResponseRequested != nil && !*ping.Body.ResponseRequested {
+			return nil
+		}
+
+		version = service.V2
+		response = service.DIDCommMsgMap{
+			"id":   uuid.NewString(),
+			"type": trustPingResponseMsgTypeV2,
+			"body": map[string]interface{}{},
+		}
+	} else {
+		if ping.ResponseRequested != nil && !*ping.ResponseRequested {
+			return nil
+		}
+
+		response = service.DIDCommMsgMap{
+			"@id":   uuid.NewString(),
+			"@type": trustPingResponseMsgType,
+		}
+	}
+
+	logger.Infof("answering trust ping : theirDID=%s", ctx.TheirDID())
+
+	return v.agent.Messenger.ReplyToMsg(msg, response, ctx.MyDID(), ctx.TheirDID(), service.WithVersion(version))
+}
+
+// handleBasicMessage records basic message of the connection and echoes its content back.
+func (v *adapterApp) handleBasicMessage(msg service.DIDCommMsgMap, ctx service.DIDCommContext) error {
+	content, err := basicMessageContent(msg)
+	if err != nil {
+		return err
+	}
+
+	err = v.recordBasicMessage(ctx.TheirDID(), &basicMessage{
+		ID:        msg.ID(),
+		Direction: messageDirectionInbound,
+		Content:   content,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// echoes are not echoed back, so that two echoing agents don't reply to each other forever.
+	if strings.HasPrefix(content, basicMessageEchoPrefix) {
+		return nil
+	}
+
+	version := service.V1
+	if msg.Type() == basicMessageMsgTypeV2 {
+		version = service.V2
+	}
+
+	reply := newBasicMessage(version, basicMessageEchoPrefix+content)
+
+	err = v.agent.Messenger.ReplyToMsg(msg, reply, ctx.MyDID(), ctx.TheirDID(), service.WithVersion(version))
+	if err != nil {
+		return fmt.Errorf("failed to echo basic message : %w", err)
+	}
+
+	return v.recordBasicMessage(ctx.TheirDID(), &basicMessage{
+		ID:        reply.ID(),
+		Direction: messageDirectionOutbound,
+		Content:   basicMessageEchoPrefix + content,
+		Time:      time.Now(),
+	})
+}
+
+// sendBasicMessage sends basic message to the connection, to check the wallet's DIDComm connection.
+func (v *adapterApp) sendBasicMessage(w http.ResponseWriter, r *http.Request) {
+	conn, err := v.agent.DIDExchClient.GetConnection(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(w, http.StatusNotFound, fmt.Sprintf("failed to get connection : %s", err))
+
+		return
+	}
+
+	var request struct {
+		Content string `json:"content"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode basic message request : %s", err))
+
+		return
+	}
+
+	if request.Content == "" {
+		handleError(w, http.StatusBadRequest, errEmptyBasicMessage.Error())
+
+		return
+	}
+
+	version := conn.DIDCommVersion
+	if version == "" {
+		version = service.V1
+	}
+
+	msg := newBasicMessage(version, request.Content)
+
+	err = v.agent.Messenger.Send(msg, conn.MyDID, conn.TheirDID, service.WithVersion(version))
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to send basic message : %s", err))
+
+		return
+	}
+
+	sent := &basicMessage{
+		ID:        msg.ID(),
+		Direction: messageDirectionOutbound,
+		Content:   request.Content,
+		Time:      time.Now(),
+	}
+
+	err = v.recordBasicMessage(conn.TheirDID, sent)
+	if err != nil {
+		logger.Errorf("failed to record basic message : %s", err)
+	}
+
+	writeJSON(w, sent)
+}
+
+// listBasicMessages returns basic messages exchanged with the connection, oldest first.
+func (v *adapterApp) listBasicMessages(w http.ResponseWriter, r *http.Request) {
+	conn, err := v.agent.DIDExchClient.GetConnection(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(w, http.StatusNotFound, fmt.Sprintf("failed to get connection : %s", err))
+
+		return
+	}
+
+	v.basicMessageMu.Lock()
+	messages, err := v.readBasicMessages(conn.TheirDID)
+	v.basicMessageMu.Unlock()
+
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read basic messages : %s", err))
+
+		return
+	}
+
+	writeJSON(w, messages)
+}
+
+func (v *adapterApp) recordBasicMessage(theirDID string, msg *basicMessage) error {
+	v.basicMessageMu.Lock()
+	defer v.basicMessageMu.Unlock()
+
+	messages, err := v.readBasicMessages(theirDID)
+	if err != nil {
+		return err
+	}
+
+	messages = append(messages, msg)
+	if len(messages) > maxBasicMessages {
+		messages = messages[len(messages)-maxBasicMessages:]
+	}
+
+	messagesBytes, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal basic messages : %w", err)
+	}
+
+	return v.store.Put(getBasicMessagesKeyPrefix(theirDID), messagesBytes)
+}
+
+func (v *adapterApp) readBasicMessages(theirDID string) ([]*basicMessage, error) {
+	messages := []*basicMessage{}
+
+	messagesBytes, err := v.store.Get(getBasicMessagesKeyPrefix(theirDID))
+	if err != nil {
+		// no messages exchanged with the connection yet.
+		return messages, nil //nolint:nilerr
+	}
+
+	err = json.Unmarshal(messagesBytes, &messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal basic messages : %w", err)
+	}
+
+	return messages, nil
+}
+
+func basicMessageContent(msg service.DIDCommMsgMap) (string, error) {
+	content := struct {
+		Content string `json:"content"`
+		Body    struct {
+			Content string `json:"content"`
+		} `json:"body"`
+	}{}
+
+	err := msg.Decode(&content)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode basic message : %w", err)
+	}
+
+	if msg.Type() == basicMessageMsgTypeV2 {
+		return content.Body.Content, nil
+	}
+
+	return content.Content, nil
+}
+
+func newBasicMessage(version service.Version, content string) service.DIDCommMsgMap {
+	if version == service.V2 {
+		return service.DIDCommMsgMap{
+			"id":           uuid.NewString(),
+			"type":         basicMessageMsgTypeV2,
+			"created_time": time.Now().Unix(),
+			"body":         map[string]interface{}{"content": content},
+		}
+	}
+
+	return service.DIDCommMsgMap{
+		"@id":       uuid.NewString(),
+		"@type":     basicMessageMsgType,
+		"sent_time": time.Now().UTC().Format(time.RFC3339),
+		"content":   content,
+	}
+}
+
+func getBasicMessagesKeyPrefix(key string) string {
+	return fmt.Sprintf("basic_messages_%s", key)
+}
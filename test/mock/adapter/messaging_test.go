@@ -0,0 +1,116 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+)
+
+type replyingMessenger struct {
+	service.MessengerHandler
+	replies []service.DIDCommMsgMap
+}
+
+func (m *replyingMessenger) ReplyToMsg(_, out service.DIDCommMsgMap, _, _ string, _ ...service.Opt) error {
+	m.replies = append(m.replies, out)
+
+	return nil
+}
+
+func TestHandleTrustPing(t *testing.T) {
+	app, inner := newMessagingTestApp(t)
+	ctx := service.NewDIDCommContext("did:example:adapter", "did:example:wallet", nil)
+
+	t.Run("answers ping", func(t *testing.T) {
+		err := app.handleTrustPing(service.DIDCommMsgMap{"@id": "ping-1", "@type": trustPingMsgType}, ctx)
+		require.NoError(t, err)
+
+		err = app.handleTrustPing(service.DIDCommMsgMap{
+			"id": "ping-2", "type": trustPingMsgTypeV2, "body": map[string]interface{}{},
+		}, ctx)
+		require.NoError(t, err)
+
+		require.Len(t, inner.replies, 2)
+		require.Equal(t, trustPingResponseMsgType, inner.replies[0].Type())
+		require.Equal(t, trustPingResponseMsgTypeV2, inner.replies[1].Type())
+	})
+
+	t.Run("response not requested", func(t *testing.T) {
+		inner.replies = nil
+
+		err := app.handleTrustPing(service.DIDCommMsgMap{
+			"@id": "ping-3", "@type": trustPingMsgType, "response_requested": false,
+		}, ctx)
+		require.NoError(t, err)
+		require.Empty(t, inner.replies)
+	})
+}
+
+func TestHandleBasicMessage(t *testing.T) {
+	app, inner := newMessagingTestApp(t)
+	ctx := service.NewDIDCommContext("did:example:adapter", "did:example:wallet", nil)
+
+	err := app.handleBasicMessage(service.DIDCommMsgMap{
+		"@id": "msg-1", "@type": basicMessageMsgType, "content": "hello",
+	}, ctx)
+	require.NoError(t, err)
+
+	err = app.handleBasicMessage(service.DIDCommMsgMap{
+		"id": "msg-2", "type": basicMessageMsgTypeV2, "body": map[string]interface{}{"content": "hi"},
+	}, ctx)
+	require.NoError(t, err)
+
+	require.Len(t, inner.replies, 2)
+
+	content, err := basicMessageContent(inner.replies[0])
+	require.NoError(t, err)
+	require.Equal(t, "echo: hello", content)
+
+	content, err = basicMessageContent(inner.replies[1])
+	require.NoError(t, err)
+	require.Equal(t, "echo: hi", content)
+
+	messages, err := app.readBasicMessages("did:example:wallet")
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	require.Equal(t, messageDirectionInbound, messages[0].Direction)
+	require.Equal(t, "hello", messages[0].Content)
+	require.Equal(t, messageDirectionOutbound, messages[1].Direction)
+	require.Equal(t, "echo: hello", messages[1].Content)
+
+	t.Run("echo is recorded but not echoed back", func(t *testing.T) {
+		err = app.handleBasicMessage(service.DIDCommMsgMap{
+			"@id": "msg-3", "@type": basicMessageMsgType, "content": "echo: hello",
+		}, ctx)
+		require.NoError(t, err)
+
+		require.Len(t, inner.replies, 2)
+
+		messages, err = app.readBasicMessages("did:example:wallet")
+		require.NoError(t, err)
+		require.Len(t, messages, 5)
+		require.Equal(t, messageDirectionInbound, messages[4].Direction)
+		require.Equal(t, "echo: hello", messages[4].Content)
+	})
+}
+
+func newMessagingTestApp(t *testing.T) (*adapterApp, *replyingMessenger) {
+	t.Helper()
+
+	inner := &replyingMessenger{}
+	recorder := &recordingMessenger{inspector: newAgentInspector()}
+	recorder.setMessenger(inner)
+
+	app, err := newAdapterApp(&didComm{Messenger: recorder}, mem.NewProvider(), newTestAdapterConfig())
+	require.NoError(t, err)
+
+	return app, inner
+}
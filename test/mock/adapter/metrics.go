@@ -0,0 +1,160 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "mock_adapter"
+	metricsPath      = "/metrics"
+
+	protocolWACI      = "waci"
+	protocolOIDC      = "oidc"
+	protocolOpenID4VC = "openid4vc"
+
+	presentationVerified = "verified"
+	presentationRejected = "rejected"
+)
+
+// adapterMetrics are Prometheus metrics of the adapter, served from /metrics.
+type adapterMetrics struct {
+	registry            *prometheus.Registry
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	offersCreated       *prometheus.CounterVec
+	credentialsIssued   *prometheus.CounterVec
+	presentations       *prometheus.CounterVec
+	didCommActions      *prometheus.CounterVec
+}
+
+func newAdapterMetrics() *adapterMetrics {
+	m := &adapterMetrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		offersCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "offers_created_total",
+			Help:      "Credential offers created, by protocol.",
+		}, []string{"protocol"}),
+		credentialsIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "credentials_issued_total",
+			Help:      "Credentials issued, by protocol and credential format.",
+		}, []string{"protocol", "format"}),
+		presentations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "presentations_total",
+			Help:      "Presentations received, by protocol and verification result.",
+		}, []string{"protocol", "result"}),
+		didCommActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "didcomm_action_events_total",
+			Help:      "DIDComm action events received, by message type.",
+		}, []string{"message_type"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.offersCreated,
+		m.credentialsIssued,
+		m.presentations,
+		m.didCommActions,
+	)
+
+	return m
+}
+
+// handler serves the metrics in Prometheus text format.
+func (m *adapterMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// middleware counts requests and observes their latency by route template, so that path IDs do not create
+// new series.
+func (m *adapterMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		m.httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *adapterMetrics) offerCreated(protocol string) {
+	m.offersCreated.WithLabelValues(protocol).Inc()
+}
+
+func (m *adapterMetrics) credentialIssued(protocol, format string) {
+	m.credentialsIssued.WithLabelValues(protocol, format).Inc()
+}
+
+func (m *adapterMetrics) presentationReceived(protocol string, verified bool) {
+	result := presentationRejected
+	if verified {
+		result = presentationVerified
+	}
+
+	m.presentations.WithLabelValues(protocol, result).Inc()
+}
+
+func (m *adapterMetrics) didCommAction(msgType string) {
+	m.didCommActions.WithLabelValues(msgType).Inc()
+}
+
+// statusRecorder captures status of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdapterMetrics(t *testing.T) {
+	metrics := newAdapterMetrics()
+
+	router := mux.NewRouter()
+	router.Use(metrics.middleware)
+	router.HandleFunc("/issuer/waci-issuance/{id}/state", func(w http.ResponseWriter, r *http.Request) {
+		handleError(w, http.StatusNotFound, "not found")
+	})
+	router.Handle(metricsPath, metrics.handler())
+
+	for _, id := range []string{"thread-1", "thread-2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/issuer/waci-issuance/"+id+"/state", nil))
+	}
+
+	metrics.offerCreated(protocolWACI)
+	metrics.credentialIssued(protocolOIDC, oidcCredentialFormat("jwt"))
+	metrics.presentationReceived(protocolWACI, true)
+	metrics.presentationReceived(protocolWACI, false)
+	metrics.didCommAction("https://didcomm.org/issue-credential/2.0/request-credential")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	require.Contains(t, body,
+		`mock_adapter_http_requests_total{method="GET",route="/issuer/waci-issuance/{id}/state",status="404"} 2`)
+	require.Contains(t, body, `mock_adapter_http_request_duration_seconds_bucket{method="GET",route="/issuer/waci-issuance/{id}/state"`)
+	require.Contains(t, body, `mock_adapter_offers_created_total{protocol="waci"} 1`)
+	require.Contains(t, body, `mock_adapter_credentials_issued_total{format="jwt_vc",protocol="oidc"} 1`)
+	require.Contains(t, body, `mock_adapter_presentations_total{protocol="waci",result="verified"} 1`)
+	require.Contains(t, body, `mock_adapter_presentations_total{protocol="waci",result="rejected"} 1`)
+	require.Contains(t, body,
+		`mock_adapter_didcomm_action_events_total{message_type="https://didcomm.org/issue-credential/2.0/request-credential"} 1`)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const oobInvitationPath = "/oob/"
+
+// oobInvitation is WACI invitation served by its short URL, with the wallet the holder is sent to.
+type oobInvitation struct {
+	Invitation json.RawMessage `json:"invitation"`
+	WalletURL  string          `json:"wallet_url"`
+}
+
+// waciInvitationLinks are links to WACI invitation returned to clients asking for JSON instead of a redirect.
+type waciInvitationLinks struct {
+	InvitationID  string `json:"invitation_id"`
+	InvitationURL string `json:"invitation_url"`
+	DeepLink      string `json:"deep_link"`
+}
+
+// saveOOBInvitation saves invitation to be served by its short URL.
+func (v *adapterApp) saveOOBInvitation(invID, walletURL string, inv interface{}) (*oobInvitation, error) {
+	invBytes, err := json.Marshal(inv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal invitation : %w", err)
+	}
+
+	oobInv := &oobInvitation{Invitation: invBytes, WalletURL: walletURL}
+
+	oobInvBytes, err := json.Marshal(oobInv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal oob invitation : %w", err)
+	}
+
+	err = v.store.Put(getOOBInvitationKeyPrefix(invID), oobInvBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save oob invitation : %w", err)
+	}
+
+	return oobInv, nil
+}
+
+func (v *adapterApp) readOOBInvitation(invID string) (*oobInvitation, error) {
+	oobInvBytes, err := v.store.Get(getOOBInvitationKeyPrefix(invID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oob invitation : %w", err)
+	}
+
+	var oobInv oobInvitation
+
+	err = json.Unmarshal(oobInvBytes, &oobInv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal oob invitation : %w", err)
+	}
+
+	return &oobInv, nil
+}
+
+// oobInvitation serves the invitation by its short URL, in the style of Aries RFC 0434. Browsers are redirected
+// to the wallet's deep link, other clients (the wallet among them) get the invitation as JSON.
+func (v *adapterApp) oobInvitation(w http.ResponseWriter, r *http.Request) {
+	invID := mux.Vars(r)["id"]
+
+	oobInv, err := v.readOOBInvitation(invID)
+	if err != nil {
+		handleError(w, http.StatusNotFound, err.Error())
+
+		return
+	}
+
+	if !acceptsHTML(r) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if _, err = w.Write(oobInv.Invitation); err != nil {
+			logger.Errorf("failed to write oob invitation : %s", err)
+		}
+
+		return
+	}
+
+	http.Redirect(w, r, v.deepLink(invID, oobInv.WalletURL), http.StatusFound)
+}
+
+// deepLink returns link to the wallet's WACI page with short URL of the invitation, which the wallet fetches the
+// invitation from. Unlike the invitation in the long form, it stays short enough for QR codes.
+func (v *adapterApp) deepLink(invID, walletURL string) string {
+	return fmt.Sprintf("%s/waci?oob_url=%s", walletURL, url.QueryEscape(v.oobInvitationURL(invID)))
+}
+
+// oobInvitationURL returns short URL of the invitation.
+func (v *adapterApp) oobInvitationURL(invID string) string {
+	return v.cfg.ExternalURL + oobInvitationPath + invID
+}
+
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func acceptsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func getOOBInvitationKeyPrefix(key string) string {
+	return fmt.Sprintf("oob_invitation_%s", key)
+}
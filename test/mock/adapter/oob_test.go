@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOOBInvitation(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	form := url.Values{
+		"walletURL": {"https://wallet.example.com"},
+		"pEx":       {`{"id":"pd-1","input_descriptors":[]}`},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/verifier/waci-share", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	rr := httptest.NewRecorder()
+
+	app.waciShare(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var links waciInvitationLinks
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &links))
+	require.Equal(t, "https://adapter.example.com/oob/"+links.InvitationID, links.InvitationURL)
+
+	getInvitation := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, links.InvitationURL, nil)
+		req.Header.Set("Accept", accept)
+		req = mux.SetURLVars(req, map[string]string{"id": links.InvitationID})
+
+		rr := httptest.NewRecorder()
+
+		app.oobInvitation(rr, req)
+
+		return rr
+	}
+
+	t.Run("serves invitation as JSON", func(t *testing.T) {
+		rr := getInvitation("application/json")
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var inv map[string]interface{}
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &inv))
+		require.Equal(t, links.InvitationID, inv["@id"])
+	})
+
+	t.Run("redirects browser to the wallet", func(t *testing.T) {
+		rr := getInvitation("text/html,application/xhtml+xml,*/*;q=0.8")
+		require.Equal(t, http.StatusFound, rr.Code)
+		require.Equal(t, links.DeepLink, rr.Header().Get("Location"))
+
+		deepLink, err := url.Parse(links.DeepLink)
+		require.NoError(t, err)
+		require.Equal(t, "wallet.example.com", deepLink.Host)
+		require.Equal(t, "/waci", deepLink.Path)
+
+		require.Equal(t, links.InvitationURL, deepLink.Query().Get("oob_url"))
+	})
+
+	t.Run("unknown invitation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/oob/unknown", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "unknown"})
+
+		rr := httptest.NewRecorder()
+
+		app.oobInvitation(rr, req)
+		require.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
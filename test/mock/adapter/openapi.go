@@ -0,0 +1,234 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+const (
+	openAPIPath = "/openapi.json"
+
+	// oauthErrorsExtension marks operations of OAuth endpoints, which answer invalid requests with OAuth error.
+	oauthErrorsExtension = "x-oauth-errors"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+func init() { //nolint:gochecknoinits
+	openapi3filter.RegisterBodyDecoder("application/x-www-form-urlencoded", decodeFormBody)
+}
+
+// openAPI validates requests against the adapter's OpenAPI document, and serves the document. Only operations
+// described in the document are validated; demo pages, health checks and unknown methods are left to the router.
+// Invalid requests are answered with 400, in OAuth error format on endpoints marked with x-oauth-errors.
+type openAPI struct {
+	router   routers.Router
+	options  *openapi3filter.Options
+	document []byte
+}
+
+// newOpenAPI loads the OpenAPI document, served with the external URL as its server.
+func newOpenAPI(externalURL string) (*openAPI, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi document : %w", err)
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi document : %w", err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openapi router : %w", err)
+	}
+
+	served := *doc
+	served.Servers = openapi3.Servers{{URL: externalURL}}
+
+	document, err := json.MarshalIndent(&served, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal openapi document : %w", err)
+	}
+
+	// defaults aren't set, so that the handlers get requests as sent.
+	options := &openapi3filter.Options{
+		SkipSettingDefaults: true,
+		AuthenticationFunc:  authenticateBearer,
+	}
+	options.WithCustomSchemaErrorFunc(schemaErrorMessage)
+
+	return &openAPI{router: router, options: options, document: document}, nil
+}
+
+func (o *openAPI) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := o.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    o.options,
+		})
+		if err != nil {
+			logger.Infof("invalid request %s %s : %s", r.Method, r.URL.Path, err)
+
+			writeInvalidRequest(w, route.Operation, err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (o *openAPI) serveDocument(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(o.document); err != nil {
+		logger.Errorf("failed to write response : %s", err)
+	}
+}
+
+// writeInvalidRequest answers the request which failed validation.
+func writeInvalidRequest(w http.ResponseWriter, operation *openapi3.Operation, validationErr error) {
+	msg := invalidRequestMessage(validationErr)
+
+	if !hasOAuthErrors(operation) {
+		handleError(w, http.StatusBadRequest, msg)
+
+		return
+	}
+
+	setOIDCResponseHeaders(w)
+	w.WriteHeader(http.StatusBadRequest)
+
+	err := json.NewEncoder(w).Encode(map[string]string{
+		"error":             "invalid_request",
+		"error_description": msg,
+	})
+	if err != nil {
+		logger.Errorf("failed to write response : %s", err)
+	}
+}
+
+func invalidRequestMessage(err error) string {
+	var (
+		securityErr *openapi3filter.SecurityRequirementsError
+		requestErr  *openapi3filter.RequestError
+		schemaErr   *openapi3.SchemaError
+	)
+
+	switch {
+	case errors.As(err, &securityErr):
+		return "invalid request : missing bearer token"
+	case errors.As(err, &requestErr) && requestErr.RequestBody != nil && errors.As(err, &schemaErr):
+		// without the name of the schema the body doesn't match.
+		return fmt.Sprintf("invalid request : request body has an error: %s", schemaErrorMessage(schemaErr))
+	default:
+		return fmt.Sprintf("invalid request : %s", err)
+	}
+}
+
+// schemaErrorMessage returns reason of the schema error, prefixed with pointer to the invalid value unless the
+// reason names it.
+func schemaErrorMessage(err *openapi3.SchemaError) string {
+	if pointer := err.JSONPointer(); len(pointer) > 0 && err.SchemaField != "required" {
+		return fmt.Sprintf("%s: %s", strings.Join(pointer, "."), err.Reason)
+	}
+
+	return err.Reason
+}
+
+func hasOAuthErrors(operation *openapi3.Operation) bool {
+	raw, ok := operation.Extensions[oauthErrorsExtension].(json.RawMessage)
+	if !ok {
+		return false
+	}
+
+	var oauthErrors bool
+
+	return json.Unmarshal(raw, &oauthErrors) == nil && oauthErrors
+}
+
+// authenticateBearer checks the request has bearer token, which the handlers then check against issued tokens.
+func authenticateBearer(_ context.Context, input *openapi3filter.AuthenticationInput) error {
+	if input.SecurityScheme.Type != "http" || input.SecurityScheme.Scheme != "bearer" {
+		return fmt.Errorf("unsupported security scheme %s", input.SecuritySchemeName)
+	}
+
+	token := strings.TrimPrefix(input.RequestValidationInput.Request.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == input.RequestValidationInput.Request.Header.Get("Authorization") {
+		return errors.New("missing bearer token")
+	}
+
+	return nil
+}
+
+// decodeFormBody decodes form body to object of the fields given in the form. The default decoder sets fields
+// missing in the form to null, which fails validation of optional fields and passes it for required ones.
+func decodeFormBody(body io.Reader, _ http.Header, schema *openapi3.SchemaRef,
+	_ openapi3filter.EncodingFn) (interface{}, error) {
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	form := map[string]interface{}{}
+
+	for name, property := range schema.Value.Properties {
+		if _, ok := values[name]; !ok {
+			continue
+		}
+
+		value := values.Get(name)
+
+		switch property.Value.Type {
+		case openapi3.TypeString:
+			form[name] = value
+		case openapi3.TypeBoolean:
+			form[name], err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: invalid boolean '%s'", name, value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported type %s of form field %s", property.Value.Type, name)
+		}
+	}
+
+	return form, nil
+}
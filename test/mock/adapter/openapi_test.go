@@ -0,0 +1,216 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI_Middleware(t *testing.T) {
+	api, err := newOpenAPI("https://adapter.example.com")
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(api.middleware)
+	router.HandleFunc(openAPIPath, api.serveDocument).Methods(http.MethodGet)
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// handlers get the body validation read.
+		require.NoError(t, r.ParseForm())
+
+		writeJSON(w, r.Form)
+	})
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	form := func(method, path string, values url.Values) *http.Request {
+		if method == http.MethodGet {
+			return httptest.NewRequest(method, path+"?"+values.Encode(), nil)
+		}
+
+		req := httptest.NewRequest(method, path, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return req
+	}
+
+	jsonRequest := func(path, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		return req
+	}
+
+	requireInvalid := func(t *testing.T, rr *httptest.ResponseRecorder, msg string) {
+		t.Helper()
+
+		require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+		var errResp ErrorResponse
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+		require.Contains(t, errResp.Message, msg)
+	}
+
+	requireOAuthInvalid := func(t *testing.T, rr *httptest.ResponseRecorder, msg string) {
+		t.Helper()
+
+		require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+		require.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+		var errResp map[string]string
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+		require.Equal(t, "invalid_request", errResp["error"])
+		require.Contains(t, errResp["error_description"], msg)
+	}
+
+	t.Run("document is served with external URL", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		doc, err := openapi3.NewLoader().LoadFromData(rr.Body.Bytes())
+		require.NoError(t, err)
+		require.Len(t, doc.Servers, 1)
+		require.Equal(t, "https://adapter.example.com", doc.Servers[0].URL)
+		require.NotNil(t, doc.Paths.Find("/{id}/issuer/openid4vc/token"))
+	})
+
+	t.Run("valid form is passed on in query or body", func(t *testing.T) {
+		values := url.Values{
+			"walletURL":      {"https://wallet.example.com"},
+			"pEx":            {`{"id":"pd-1","input_descriptors":[]}`},
+			"connectionless": {"true"},
+		}
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			rr := serve(form(method, "/verifier/waci-share", values))
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			require.Contains(t, rr.Body.String(), "pd-1")
+		}
+	})
+
+	t.Run("invalid form", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			rr := serve(form(method, "/verifier/waci-share", url.Values{"walletURL": {"https://wallet.example.com"}}))
+			requireInvalid(t, rr, "pEx")
+
+			rr = serve(form(method, "/verifier/waci-share", url.Values{
+				"walletURL":      {"https://wallet.example.com"},
+				"pEx":            {`{"id":"pd-1","input_descriptors":[]}`},
+				"connectionless": {"yes"},
+			}))
+			requireInvalid(t, rr, "connectionless")
+		}
+
+		rr := serve(form(http.MethodPost, "/issuer/waci-issuance-v2", url.Values{
+			"walletURL":        {"https://wallet.example.com"},
+			"credToIssue":      {"{}"},
+			"credentialFormat": {"jwt"},
+		}))
+		requireInvalid(t, rr, "credentialFormat")
+	})
+
+	t.Run("invalid query parameter", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/admin/sessions/session-1/transcript?format=xml", nil))
+		requireInvalid(t, rr, `parameter "format" in query`)
+
+		rr = serve(httptest.NewRequest(http.MethodGet, "/issuer-1/issuer/oidc/authorize?client_id=wallet", nil))
+		requireInvalid(t, rr, "redirect_uri")
+	})
+
+	t.Run("invalid JSON body", func(t *testing.T) {
+		rr := serve(jsonRequest(waciShareSessionPath, `{"walletURL": "https://wallet.example.com"}`))
+		requireInvalid(t, rr, "walletURL")
+
+		rr = serve(jsonRequest("/admin/faults", `{"route": "/issuer/oidc/issuance", "body": "explode"}`))
+		requireInvalid(t, rr, "body")
+
+		rr = serve(jsonRequest("/admin/connections/connection-1/basic-messages", `{"content": ""}`))
+		requireInvalid(t, rr, "content")
+	})
+
+	t.Run("OAuth endpoints answer with OAuth error", func(t *testing.T) {
+		rr := serve(form(http.MethodPost, "/issuer-1/issuer/openid4vc/token", url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:pre-authorized_code"},
+		}))
+		requireOAuthInvalid(t, rr, "pre-authorized_code")
+
+		rr = serve(jsonRequest("/issuer-1/issuer/openid4vc/credential",
+			`{"type": "VerifiableCredential", "proof": {"jwt": "proof"}}`))
+		requireOAuthInvalid(t, rr, "bearer token")
+
+		req := jsonRequest("/issuer-1/issuer/openid4vc/credential", `{"type": "VerifiableCredential"}`)
+		req.Header.Set("Authorization", "Bearer token")
+
+		rr = serve(req)
+		requireOAuthInvalid(t, rr, "proof")
+
+		req = form(http.MethodPost, "/issuer-1/issuer/oidc/credential", url.Values{"type": {"VerifiableCredential"}})
+		req.Header.Set("Authorization", "Bearer token")
+
+		rr = serve(req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	})
+
+	t.Run("requests not in document are passed on", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/issuer/waci", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		rr = serve(httptest.NewRequest(http.MethodPut, "/verifier/waci-share", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestOpenAPI_Routes(t *testing.T) {
+	adapter := startHolderTestAdapter(t)
+
+	resp, err := adapter.http.Get(adapter.url + openAPIPath) //nolint:noctx
+	require.NoError(t, err)
+
+	docBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	doc, err := openapi3.NewLoader().LoadFromData(docBytes)
+	require.NoError(t, err)
+
+	client := *adapter.http
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// requests of every documented operation get to the adapter's route, be it rejected by validation or handled.
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			req, err := http.NewRequest(method, adapter.url+strings.ReplaceAll(path, "{id}", "test-id"), nil) //nolint:noctx
+			require.NoError(t, err)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			body, _ := io.ReadAll(resp.Body) //nolint:errcheck
+			require.NoError(t, resp.Body.Close())
+
+			require.NotEqual(t, http.StatusMethodNotAllowed, resp.StatusCode, method+" "+path)
+			require.NotEqual(t, "404 page not found\n", string(body), method+" "+path)
+		}
+	}
+}
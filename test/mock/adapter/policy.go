@@ -0,0 +1,468 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/util/didsignjwt"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/square/go-jose/jwt"
+)
+
+const (
+	defaultVerifierProfile = "default"
+	trustedIssuerRuleID    = "trusted-issuer"
+	didWebMethodPrefix     = "did:web:"
+
+	// policy rule operators.
+	policyOpEquals    = "eq"
+	policyOpNotEquals = "ne"
+	policyOpContains  = "contains"
+	policyOpIn        = "in"
+	policyOpExists    = "exists"
+	policyOpBefore    = "before"
+	policyOpAfter     = "after"
+	policyOpMinAge    = "age_gte"
+	policyOpMaxAge    = "age_lt"
+
+	policyMatchAny     = "any"
+	policyTimeValueNow = "now"
+	policyDateLayout   = "2006-01-02"
+)
+
+// verifierProfile configures which issuers a verifier trusts and which policy presentations must satisfy.
+type verifierProfile struct {
+	TrustedIssuers *trustedIssuers `json:"trusted_issuers,omitempty"`
+	Policy         []*policyRule   `json:"policy,omitempty"`
+}
+
+// trustedIssuers lists trusted issuer DIDs, did:web domains and an optional signed trust list.
+// When no issuers are configured any issuer is accepted.
+type trustedIssuers struct {
+	DIDs            []string `json:"dids,omitempty"`
+	DIDWebDomains   []string `json:"did_web_domains,omitempty"`
+	TrustList       string   `json:"trust_list,omitempty"`
+	TrustListSigner string   `json:"trust_list_signer,omitempty"`
+}
+
+// trustList is the payload of a signed (compact JWS) trust list.
+type trustList struct {
+	Issuers       []string `json:"issuers,omitempty"`
+	DIDWebDomains []string `json:"did_web_domains,omitempty"`
+}
+
+// policyRule is a single check over credential fields, e.g.
+//
+//	{"id": "over-18", "field": "credentialSubject.birthDate", "op": "age_gte", "value": 18}
+//
+// The rule is evaluated against every credential of the presentation unless match is "any".
+type policyRule struct {
+	ID    string      `json:"id"`
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+	Match string      `json:"match,omitempty"`
+}
+
+// policyViolation reports the rule which rejected a presentation.
+type policyViolation struct {
+	Rule         string `json:"rule"`
+	CredentialID string `json:"credential_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// loadVerifierProfiles reads verifier profiles from the JSON file at given path, if any.
+// Signed trust lists are verified while loading.
+func loadVerifierProfiles(vdr vdrapi.Registry, path string) (map[string]*verifierProfile, error) {
+	profiles := map[string]*verifierProfile{}
+
+	if path != "" {
+		profilesBytes, err := os.ReadFile(path) //nolint:gosec
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verifier profiles : %w", err)
+		}
+
+		err = json.Unmarshal(profilesBytes, &profiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal verifier profiles : %w", err)
+		}
+	}
+
+	if _, ok := profiles[defaultVerifierProfile]; !ok {
+		profiles[defaultVerifierProfile] = &verifierProfile{}
+	}
+
+	for id, profile := range profiles {
+		if profile.TrustedIssuers == nil || profile.TrustedIssuers.TrustList == "" {
+			continue
+		}
+
+		err := profile.TrustedIssuers.loadTrustList(vdr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trust list for verifier profile '%s' : %w", id, err)
+		}
+	}
+
+	return profiles, nil
+}
+
+// loadTrustList verifies the signed trust list file and merges its entries into trusted issuers. The list must be
+// signed by the configured signer, as anyone can sign a valid JWS with their own DID.
+func (t *trustedIssuers) loadTrustList(vdr vdrapi.Registry) error {
+	if t.TrustListSigner == "" {
+		return errors.New("trust_list_signer is required with trust_list")
+	}
+
+	jwsBytes, err := os.ReadFile(t.TrustList)
+	if err != nil {
+		return fmt.Errorf("read trust list : %w", err)
+	}
+
+	jws := strings.TrimSpace(string(jwsBytes))
+
+	err = didsignjwt.VerifyJWT(jws, vdr)
+	if err != nil {
+		return fmt.Errorf("verify trust list signature : %w", err)
+	}
+
+	token, err := jwt.ParseSigned(jws)
+	if err != nil {
+		return fmt.Errorf("parse trust list : %w", err)
+	}
+
+	signer := strings.Split(token.Headers[0].KeyID, "#")[0]
+	if signer != t.TrustListSigner {
+		return fmt.Errorf("trust list signed by '%s', expected '%s'", signer, t.TrustListSigner)
+	}
+
+	var list trustList
+
+	err = token.UnsafeClaimsWithoutVerification(&list)
+	if err != nil {
+		return fmt.Errorf("read trust list claims : %w", err)
+	}
+
+	t.DIDs = append(t.DIDs, list.Issuers...)
+	t.DIDWebDomains = append(t.DIDWebDomains, list.DIDWebDomains...)
+
+	return nil
+}
+
+// validateVerifierProfile rejects unknown profiles, so that sessions are never verified with another profile than
+// the one they were created for. Empty ID stands for the default profile.
+func (v *adapterApp) validateVerifierProfile(profileID string) error {
+	if _, ok := v.verifierProfiles[profileID]; profileID != "" && !ok {
+		return fmt.Errorf("unknown verifier profile '%s'", profileID)
+	}
+
+	return nil
+}
+
+// verifierProfile returns the profile by ID, empty ID being the default profile.
+func (v *adapterApp) verifierProfile(id string) (*verifierProfile, error) {
+	if id == "" {
+		id = defaultVerifierProfile
+	}
+
+	profile, ok := v.verifierProfiles[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown verifier profile '%s'", id)
+	}
+
+	return profile, nil
+}
+
+// evaluate checks issuer trust and policy rules against presentation credentials.
+// Returns nil when the presentation satisfies the profile.
+func (p *verifierProfile) evaluate(vp *verifiable.Presentation) (*policyViolation, error) {
+	rawCreds, err := vp.MarshalledCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation credentials : %w", err)
+	}
+
+	creds := make([]map[string]interface{}, 0, len(rawCreds))
+
+	for _, raw := range rawCreds {
+		vc, err := decodeCredentialJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credential : %w", err)
+		}
+
+		creds = append(creds, vc)
+	}
+
+	if p.TrustedIssuers != nil {
+		for _, vc := range creds {
+			if issuer := credentialIssuer(vc); !p.TrustedIssuers.trusts(issuer) {
+				return &policyViolation{
+					Rule:         trustedIssuerRuleID,
+					CredentialID: credentialID(vc),
+					Reason:       fmt.Sprintf("issuer '%s' is not trusted", issuer),
+				}, nil
+			}
+		}
+	}
+
+	for _, rule := range p.Policy {
+		if violation := rule.evaluate(creds); violation != nil {
+			return violation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (t *trustedIssuers) trusts(issuer string) bool {
+	if len(t.DIDs) == 0 && len(t.DIDWebDomains) == 0 {
+		return true
+	}
+
+	for _, d := range t.DIDs {
+		if d == issuer {
+			return true
+		}
+	}
+
+	if !strings.HasPrefix(issuer, didWebMethodPrefix) {
+		return false
+	}
+
+	domain, err := url.PathUnescape(strings.Split(strings.TrimPrefix(issuer, didWebMethodPrefix), ":")[0])
+	if err != nil {
+		return false
+	}
+
+	for _, d := range t.DIDWebDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *policyRule) evaluate(creds []map[string]interface{}) *policyViolation {
+	var firstViolation *policyViolation
+
+	for _, vc := range creds {
+		err := r.check(vc)
+		if err == nil {
+			if r.Match == policyMatchAny {
+				return nil
+			}
+
+			continue
+		}
+
+		if firstViolation == nil {
+			firstViolation = &policyViolation{Rule: r.ID, CredentialID: credentialID(vc), Reason: err.Error()}
+		}
+
+		if r.Match != policyMatchAny {
+			return firstViolation
+		}
+	}
+
+	if r.Match == policyMatchAny && firstViolation == nil {
+		return &policyViolation{Rule: r.ID, Reason: "no credentials in presentation"}
+	}
+
+	return firstViolation
+}
+
+// check evaluates rule against a single credential.
+func (r *policyRule) check(vc map[string]interface{}) error { //nolint:gocyclo
+	val, found := credentialField(vc, r.Field)
+
+	switch r.Op {
+	case policyOpExists:
+		if !found {
+			return fmt.Errorf("field '%s' is missing", r.Field)
+		}
+
+		return nil
+	case policyOpEquals, policyOpNotEquals:
+		equal := found && reflect.DeepEqual(normalizeFieldValue(val), normalizeFieldValue(r.Value))
+		if equal != (r.Op == policyOpEquals) {
+			return fmt.Errorf("field '%s' value '%v' failed '%s' check against '%v'", r.Field, val, r.Op, r.Value)
+		}
+
+		return nil
+	case policyOpContains:
+		if !found || !containsValue(val, r.Value) {
+			return fmt.Errorf("field '%s' does not contain '%v'", r.Field, r.Value)
+		}
+
+		return nil
+	case policyOpIn:
+		if !found || !containsValue(r.Value, val) {
+			return fmt.Errorf("field '%s' value '%v' is not one of '%v'", r.Field, val, r.Value)
+		}
+
+		return nil
+	case policyOpBefore, policyOpAfter:
+		return r.checkTime(val, found)
+	case policyOpMinAge, policyOpMaxAge:
+		return r.checkAge(val, found)
+	default:
+		return fmt.Errorf("unsupported policy operator '%s'", r.Op)
+	}
+}
+
+func (r *policyRule) checkTime(val interface{}, found bool) error {
+	if !found {
+		return fmt.Errorf("field '%s' is missing", r.Field)
+	}
+
+	fieldTime, err := parsePolicyTime(val)
+	if err != nil {
+		return fmt.Errorf("field '%s' : %w", r.Field, err)
+	}
+
+	ruleTime, err := parsePolicyTime(r.Value)
+	if err != nil {
+		return fmt.Errorf("rule value : %w", err)
+	}
+
+	if r.Op == policyOpBefore && !fieldTime.Before(ruleTime) {
+		return fmt.Errorf("field '%s' (%s) is not before %s", r.Field, fieldTime.Format(time.RFC3339),
+			ruleTime.Format(time.RFC3339))
+	}
+
+	if r.Op == policyOpAfter && !fieldTime.After(ruleTime) {
+		return fmt.Errorf("field '%s' (%s) is not after %s", r.Field, fieldTime.Format(time.RFC3339),
+			ruleTime.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (r *policyRule) checkAge(val interface{}, found bool) error {
+	if !found {
+		return fmt.Errorf("field '%s' is missing", r.Field)
+	}
+
+	birthDate, err := parsePolicyTime(val)
+	if err != nil {
+		return fmt.Errorf("field '%s' : %w", r.Field, err)
+	}
+
+	years, ok := r.Value.(float64)
+	if !ok {
+		return fmt.Errorf("rule value '%v' is not a number", r.Value)
+	}
+
+	age := ageInYears(birthDate, time.Now().UTC())
+
+	if r.Op == policyOpMinAge && float64(age) < years {
+		return fmt.Errorf("age %d is below %v", age, years)
+	}
+
+	if r.Op == policyOpMaxAge && float64(age) >= years {
+		return fmt.Errorf("age %d is not below %v", age, years)
+	}
+
+	return nil
+}
+
+// credentialField returns value at dot separated path in credential.
+func credentialField(vc map[string]interface{}, path string) (interface{}, bool) {
+	var val interface{} = vc
+
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		val, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return normalizeFieldValue(val), true
+}
+
+// normalizeFieldValue reduces objects having an "id" (e.g. issuer) to their ID.
+func normalizeFieldValue(val interface{}) interface{} {
+	if obj, ok := val.(map[string]interface{}); ok {
+		if id, ok := obj["id"].(string); ok {
+			return id
+		}
+	}
+
+	return val
+}
+
+func containsValue(list, val interface{}) bool {
+	items, ok := list.([]interface{})
+	if !ok {
+		return reflect.DeepEqual(list, val)
+	}
+
+	for _, item := range items {
+		if reflect.DeepEqual(normalizeFieldValue(item), val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parsePolicyTime(val interface{}) (time.Time, error) {
+	s, ok := val.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("'%v' is not a date", val)
+	}
+
+	if s == policyTimeValueNow {
+		return time.Now(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(policyDateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", s)
+	}
+
+	return t, nil
+}
+
+func ageInYears(birthDate, now time.Time) int {
+	age := now.Year() - birthDate.Year()
+
+	if now.Month() < birthDate.Month() || (now.Month() == birthDate.Month() && now.Day() < birthDate.Day()) {
+		age--
+	}
+
+	return age
+}
+
+func credentialIssuer(vc map[string]interface{}) string {
+	issuer, _ := normalizeFieldValue(vc["issuer"]).(string) //nolint:errcheck
+
+	return issuer
+}
+
+func credentialID(vc map[string]interface{}) string {
+	id, _ := vc["id"].(string) //nolint:errcheck
+
+	return id
+}
@@ -0,0 +1,321 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jose"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jwt"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	vdrpkg "github.com/hyperledger/aries-framework-go/pkg/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/key"
+	"github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyRule_Check(t *testing.T) {
+	vc := map[string]interface{}{
+		"id":             "urn:uuid:credential-1",
+		"issuer":         map[string]interface{}{"id": "did:example:issuer", "name": "Issuer"},
+		"type":           []interface{}{"VerifiableCredential", "PermanentResidentCard"},
+		"expirationDate": "2030-01-01T00:00:00Z",
+		"credentialSubject": map[string]interface{}{
+			"givenName":   "Jane",
+			"nationality": "CA",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rule  *policyRule
+		valid bool
+	}{
+		{"eq", &policyRule{Field: "credentialSubject.givenName", Op: "eq", Value: "Jane"}, true},
+		{"eq mismatch", &policyRule{Field: "credentialSubject.givenName", Op: "eq", Value: "John"}, false},
+		{"eq missing field", &policyRule{Field: "credentialSubject.familyName", Op: "eq", Value: "Doe"}, false},
+		{"eq issuer object by ID", &policyRule{Field: "issuer", Op: "eq", Value: "did:example:issuer"}, true},
+		{"ne", &policyRule{Field: "credentialSubject.givenName", Op: "ne", Value: "John"}, true},
+		{"ne match", &policyRule{Field: "credentialSubject.givenName", Op: "ne", Value: "Jane"}, false},
+		{"contains", &policyRule{Field: "type", Op: "contains", Value: "PermanentResidentCard"}, true},
+		{"contains missing value", &policyRule{Field: "type", Op: "contains", Value: "DriversLicense"}, false},
+		{"in", &policyRule{
+			Field: "credentialSubject.nationality", Op: "in", Value: []interface{}{"US", "CA"},
+		}, true},
+		{"in missing value", &policyRule{
+			Field: "credentialSubject.nationality", Op: "in", Value: []interface{}{"FR"},
+		}, false},
+		{"exists", &policyRule{Field: "credentialSubject.givenName", Op: "exists"}, true},
+		{"exists missing field", &policyRule{Field: "credentialSubject.birthDate", Op: "exists"}, false},
+		{"before", &policyRule{Field: "expirationDate", Op: "before", Value: "2031-01-01"}, true},
+		{"before same time", &policyRule{Field: "expirationDate", Op: "before", Value: "2030-01-01T00:00:00Z"}, false},
+		{"after", &policyRule{Field: "expirationDate", Op: "after", Value: "2029-12-31"}, true},
+		{"after now", &policyRule{Field: "expirationDate", Op: "after", Value: "now"}, true},
+		{"after later", &policyRule{Field: "expirationDate", Op: "after", Value: "2031-01-01"}, false},
+		{"after invalid date", &policyRule{Field: "credentialSubject.givenName", Op: "after", Value: "now"}, false},
+		{"unsupported operator", &policyRule{Field: "credentialSubject.givenName", Op: "like", Value: "J*"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.check(vc)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicyRule_CheckAge(t *testing.T) {
+	const years = 18
+
+	now := time.Now()
+
+	birthDate := func(date time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"credentialSubject": map[string]interface{}{"birthDate": date.Format(policyDateLayout)},
+		}
+	}
+
+	eighteenToday := birthDate(now.AddDate(-years, 0, 0))
+	eighteenTomorrow := birthDate(now.AddDate(-years, 0, 1))
+	eighteenYesterday := birthDate(now.AddDate(-years, 0, -1))
+
+	minAge := &policyRule{Field: "credentialSubject.birthDate", Op: policyOpMinAge, Value: float64(years)}
+	maxAge := &policyRule{Field: "credentialSubject.birthDate", Op: policyOpMaxAge, Value: float64(years)}
+
+	tests := []struct {
+		name  string
+		rule  *policyRule
+		vc    map[string]interface{}
+		valid bool
+	}{
+		{"age_gte on birthday", minAge, eighteenToday, true},
+		{"age_gte day after birthday", minAge, eighteenYesterday, true},
+		{"age_gte day before birthday", minAge, eighteenTomorrow, false},
+		{"age_lt on birthday", maxAge, eighteenToday, false},
+		{"age_lt day after birthday", maxAge, eighteenYesterday, false},
+		{"age_lt day before birthday", maxAge, eighteenTomorrow, true},
+		{"missing birth date", minAge, map[string]interface{}{}, false},
+		{"non-numeric age", &policyRule{
+			Field: "credentialSubject.birthDate", Op: policyOpMinAge, Value: "18",
+		}, eighteenYesterday, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.check(test.vc)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifierProfile_Evaluate(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	vp := func(issuers ...string) *verifiable.Presentation {
+		creds := make([]interface{}, 0, len(issuers))
+
+		for i, issuer := range issuers {
+			creds = append(creds, map[string]interface{}{
+				"@context":          []string{"https://www.w3.org/2018/credentials/v1"},
+				"id":                fmt.Sprintf("urn:uuid:credential-%d", i),
+				"type":              []string{"VerifiableCredential"},
+				"issuer":            issuer,
+				"issuanceDate":      "2022-01-01T00:00:00Z",
+				"credentialSubject": map[string]interface{}{"id": "did:example:holder", "score": float64(i)},
+			})
+		}
+
+		vpBytes, err := json.Marshal(map[string]interface{}{
+			"@context":             []string{"https://www.w3.org/2018/credentials/v1"},
+			"type":                 []string{"VerifiablePresentation"},
+			"verifiableCredential": creds,
+		})
+		require.NoError(t, err)
+
+		pres, err := verifiable.ParsePresentation(vpBytes, verifiable.WithPresDisabledProofCheck(),
+			verifiable.WithPresJSONLDDocumentLoader(ld.NewDefaultDocumentLoader(nil)))
+		require.NoError(t, err)
+
+		return pres
+	}
+
+	profile := &verifierProfile{
+		TrustedIssuers: &trustedIssuers{
+			DIDs:          []string{"did:example:issuer"},
+			DIDWebDomains: []string{"issuer.example.com"},
+		},
+		Policy: []*policyRule{
+			{ID: "scored", Field: "credentialSubject.score", Op: "eq", Value: float64(1), Match: policyMatchAny},
+		},
+	}
+
+	t.Run("trusted issuers and rule matching any credential", func(t *testing.T) {
+		violation, err := profile.evaluate(vp("did:example:issuer", "did:web:issuer.example.com:issuers:1"))
+		require.NoError(t, err)
+		require.Nil(t, violation)
+	})
+
+	t.Run("untrusted issuer", func(t *testing.T) {
+		violation, err := profile.evaluate(vp("did:example:issuer", "did:web:other.example.com"))
+		require.NoError(t, err)
+		require.NotNil(t, violation)
+		require.Equal(t, trustedIssuerRuleID, violation.Rule)
+		require.Equal(t, "urn:uuid:credential-1", violation.CredentialID)
+	})
+
+	t.Run("no credential matches rule", func(t *testing.T) {
+		violation, err := profile.evaluate(vp("did:example:issuer"))
+		require.NoError(t, err)
+		require.NotNil(t, violation)
+		require.Equal(t, "scored", violation.Rule)
+	})
+}
+
+func TestLoadVerifierProfiles(t *testing.T) {
+	vdr := vdrpkg.New(vdrpkg.WithVDR(key.New()))
+	dir := t.TempDir()
+
+	writeProfiles := func(t *testing.T, trustListJWS, signer string) string {
+		t.Helper()
+
+		trustListPath := filepath.Join(dir, "trust-list.jws")
+		require.NoError(t, os.WriteFile(trustListPath, []byte(trustListJWS), 0o600))
+
+		profiles, err := json.Marshal(map[string]*verifierProfile{
+			"trusting": {TrustedIssuers: &trustedIssuers{
+				DIDs: []string{"did:example:issuer"}, TrustList: trustListPath, TrustListSigner: signer,
+			}},
+		})
+		require.NoError(t, err)
+
+		profilesPath := filepath.Join(dir, "profiles.json")
+		require.NoError(t, os.WriteFile(profilesPath, profiles, 0o600))
+
+		return profilesPath
+	}
+
+	signed := signTestTrustList(t, &trustList{Issuers: []string{"did:example:listed"}})
+
+	t.Run("signed trust list is merged", func(t *testing.T) {
+		profiles, err := loadVerifierProfiles(vdr, writeProfiles(t, signed, didKey))
+		require.NoError(t, err)
+		require.Contains(t, profiles, defaultVerifierProfile)
+		require.Equal(t, []string{"did:example:issuer", "did:example:listed"}, profiles["trusting"].TrustedIssuers.DIDs)
+	})
+
+	t.Run("trust list with invalid signature", func(t *testing.T) {
+		parts := strings.Split(signed, ".")
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+
+		signature[0] ^= 0xff
+		parts[2] = base64.RawURLEncoding.EncodeToString(signature)
+
+		_, err = loadVerifierProfiles(vdr, writeProfiles(t, strings.Join(parts, "."), didKey))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "verify trust list signature")
+	})
+
+	t.Run("trust list without signer", func(t *testing.T) {
+		_, err := loadVerifierProfiles(vdr, writeProfiles(t, signed, ""))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "trust_list_signer is required with trust_list")
+	})
+
+	t.Run("trust list of another signer", func(t *testing.T) {
+		_, err := loadVerifierProfiles(vdr, writeProfiles(t, signed, "did:example:signer"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "trust list signed by '"+didKey+"'")
+	})
+}
+
+func TestVerifierProfileIDs(t *testing.T) {
+	app := newTestAdapterApp(t)
+	app.verifierProfiles = map[string]*verifierProfile{
+		defaultVerifierProfile: {},
+		"strict":               {TrustedIssuers: &trustedIssuers{DIDs: []string{"did:example:issuer"}}},
+	}
+
+	t.Run("profile is resolved by ID, empty ID being the default one", func(t *testing.T) {
+		profile, err := app.verifierProfile("strict")
+		require.NoError(t, err)
+		require.Equal(t, app.verifierProfiles["strict"], profile)
+
+		profile, err = app.verifierProfile("")
+		require.NoError(t, err)
+		require.Equal(t, app.verifierProfiles[defaultVerifierProfile], profile)
+	})
+
+	t.Run("unknown profile is neither saved nor replaced with the default one", func(t *testing.T) {
+		require.EqualError(t, app.saveVerifierProfileID("session-1", "unknown"), "unknown verifier profile 'unknown'")
+		require.Equal(t, defaultVerifierProfile, app.readVerifierProfileID("session-1"))
+
+		_, err := app.verifierProfile("unknown")
+		require.EqualError(t, err, "unknown verifier profile 'unknown'")
+	})
+
+	t.Run("demo pages reject unknown profile", func(t *testing.T) {
+		form := url.Values{
+			"walletURL":     {"https://wallet.example.com"},
+			"walletAuthURL": {"https://wallet.example.com/auth"},
+			"pEx":           {`{"id":"pd-1","input_descriptors":[]}`},
+			"profile":       {"unknown"},
+		}
+
+		for _, handler := range []http.HandlerFunc{app.waciShare, app.waciShareV2, app.oidcShare} {
+			req := httptest.NewRequest(http.MethodPost, "/verifier/share", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+
+			handler(rr, req)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			require.Contains(t, rr.Body.String(), "unknown verifier profile 'unknown'")
+		}
+
+		rr := httptest.NewRecorder()
+
+		app.openid4vcShare(rr, httptest.NewRequest(http.MethodGet, "/verifier/openid4vc/share?profile=unknown", nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "unknown verifier profile 'unknown'")
+	})
+}
+
+// signTestTrustList signs trust list with the adapter's did:key.
+func signTestTrustList(t *testing.T, list *trustList) string {
+	t.Helper()
+
+	token, err := jwt.NewSigned(list, jose.Headers{jose.HeaderKeyID: kid},
+		jwt.NewEd25519Signer(ed25519.PrivateKey(base58.Decode(pkBase58))))
+	require.NoError(t, err)
+
+	jws, err := token.Serialize(false)
+	require.NoError(t, err)
+
+	return jws
+}
@@ -0,0 +1,220 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	recordingFileExt = ".json"
+
+	recordingDirPerm  = 0o700
+	recordingFilePerm = 0o600
+)
+
+// recordingStore saves session transcripts in record mode, one JSON file per session named by the session ID. The
+// files have the format of transcripts downloaded from the admin API, and are what replay mode serves.
+type recordingStore struct {
+	dir string
+}
+
+func newRecordingStore(dir string) (*recordingStore, error) {
+	err := os.MkdirAll(dir, recordingDirPerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording dir : %w", err)
+	}
+
+	return &recordingStore{dir: dir}, nil
+}
+
+// save writes the session's transcript, replacing the file atomically so that it's never read half written.
+func (s *recordingStore) save(session *transcript) {
+	sessionBytes, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		logger.Errorf("failed to marshal recording of session %s : %s", session.ID, err)
+
+		return
+	}
+
+	path := s.path(session.ID)
+
+	err = os.WriteFile(path+".tmp", sessionBytes, recordingFilePerm)
+	if err != nil {
+		logger.Errorf("failed to save recording of session %s : %s", session.ID, err)
+
+		return
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		logger.Errorf("failed to save recording of session %s : %s", session.ID, err)
+	}
+}
+
+// remove deletes recording of a key which turned out to be part of another session.
+func (s *recordingStore) remove(id string) {
+	err := os.Remove(s.path(id))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Errorf("failed to remove recording of %s : %s", id, err)
+	}
+}
+
+func (s *recordingStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+recordingFileExt)
+}
+
+// replayer serves HTTP responses recorded for a session instead of the handlers. Responses are matched by method
+// and path, and served in the recorded sequence; once a route's responses run out, its last response is repeated.
+// Bodies are served as recorded, so nonces, timestamps and expiry times in them are those of the recording, and so
+// is the Date header. Only the state the wallet sent is replaced, for the wallet to accept redirects back to it.
+// Requests to routes not in the recording are handled as usual.
+type replayer struct {
+	sessionID string
+
+	mu        sync.Mutex
+	exchanges map[string][]*replayedExchange
+	served    map[string]int
+}
+
+type replayedExchange struct {
+	time     time.Time
+	exchange *httpExchange
+}
+
+// newReplayer loads recording saved in record mode, or transcript downloaded as JSON.
+func newReplayer(path string) (*replayer, error) {
+	sessionBytes, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording : %w", err)
+	}
+
+	var session transcript
+
+	err = json.Unmarshal(sessionBytes, &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s : %w", path, err)
+	}
+
+	r := &replayer{
+		sessionID: session.ID,
+		exchanges: map[string][]*replayedExchange{},
+		served:    map[string]int{},
+	}
+
+	for _, entry := range session.Entries {
+		if entry.Kind != transcriptEntryHTTP || entry.HTTP == nil {
+			continue
+		}
+
+		if entry.HTTP.Truncated {
+			return nil, fmt.Errorf("recording %s has truncated %s %s exchange, record the session with --%s",
+				path, entry.HTTP.Method, entry.HTTP.URL, recordDirFlagName)
+		}
+
+		u, err := url.Parse(entry.HTTP.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL of recorded exchange : %w", err)
+		}
+
+		key := replayKey(entry.HTTP.Method, u.Path)
+		r.exchanges[key] = append(r.exchanges[key], &replayedExchange{time: entry.Time, exchange: entry.HTTP})
+	}
+
+	if len(r.exchanges) == 0 {
+		return nil, fmt.Errorf("recording %s has no HTTP exchanges", path)
+	}
+
+	return r, nil
+}
+
+// next returns the route's next recorded exchange, or nil if none was recorded.
+func (p *replayer) next(method, path string) *replayedExchange {
+	key := replayKey(method, path)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	exchanges := p.exchanges[key]
+	if len(exchanges) == 0 {
+		return nil
+	}
+
+	i := p.served[key]
+	if i >= len(exchanges) {
+		i = len(exchanges) - 1
+	}
+
+	p.served[key] = i + 1
+
+	return exchanges[i]
+}
+
+func (p *replayer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed := p.next(r.Method, r.URL.Path)
+		if replayed == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		setTranscriptSession(r, p.sessionID)
+
+		exchange := replayed.exchange
+		location := exchange.ResponseHeaders.Get("Location")
+		body := exchange.ResponseBody
+
+		if recorded, state := recordedState(exchange), r.FormValue("state"); recorded != "" && state != "" {
+			location = strings.ReplaceAll(location, url.QueryEscape(recorded), url.QueryEscape(state))
+			body = strings.ReplaceAll(body, recorded, state)
+		}
+
+		for name, values := range exchange.ResponseHeaders {
+			w.Header()[name] = append([]string{}, values...)
+		}
+
+		// the body may have changed with the state.
+		w.Header().Del("Content-Length")
+
+		if location != "" {
+			w.Header().Set("Location", location)
+		}
+
+		w.Header().Set("Date", replayed.time.UTC().Format(http.TimeFormat))
+		w.WriteHeader(exchange.Status)
+
+		if _, err := w.Write([]byte(body)); err != nil {
+			logger.Errorf("failed to write replayed response : %s", err)
+		}
+	})
+}
+
+// recordedState returns state parameter of the recorded request.
+func recordedState(exchange *httpExchange) string {
+	if u, err := url.Parse(exchange.URL); err == nil && u.Query().Get("state") != "" {
+		return u.Query().Get("state")
+	}
+
+	if form, err := url.ParseQuery(exchange.RequestBody); err == nil {
+		return form.Get("state")
+	}
+
+	return ""
+}
+
+func replayKey(method, path string) string {
+	return method + " " + path
+}
@@ -0,0 +1,135 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	recordings, err := newRecordingStore(dir)
+	require.NoError(t, err)
+
+	transcripts := newTranscriptRecorder()
+	transcripts.recordTo(recordings)
+
+	nonce := 0
+	largeCredential := strings.Repeat("c", maxTranscriptBodyLength+1)
+
+	router := mux.NewRouter()
+	router.Use(transcripts.middleware)
+	router.HandleFunc("/{id}/authorize", func(w http.ResponseWriter, r *http.Request) {
+		setTranscriptSession(r, mux.Vars(r)["id"])
+
+		http.Redirect(w, r, "https://wallet.example.com/cb?code=auth-code&state="+r.FormValue("state"),
+			http.StatusFound)
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/{id}/token", func(w http.ResponseWriter, r *http.Request) {
+		setTranscriptSession(r, mux.Vars(r)["id"])
+
+		nonce++
+		writeJSON(w, map[string]interface{}{"access_token": "token", "c_nonce": nonce})
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/{id}/credential", func(w http.ResponseWriter, r *http.Request) {
+		setTranscriptSession(r, mux.Vars(r)["id"])
+
+		writeJSON(w, map[string]string{"credential": largeCredential})
+	}).Methods(http.MethodPost)
+
+	serve := func(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	serve(router, httptest.NewRequest(http.MethodGet, "/session-1/authorize?state=recorded-state", nil))
+	recordedTokens := []string{
+		serve(router, httptest.NewRequest(http.MethodPost, "/session-1/token", nil)).Body.String(),
+		serve(router, httptest.NewRequest(http.MethodPost, "/session-1/token", nil)).Body.String(),
+	}
+	serve(router, httptest.NewRequest(http.MethodPost, "/session-1/credential", nil))
+
+	recording := filepath.Join(dir, "session-1"+recordingFileExt)
+
+	replay, err := newReplayer(recording)
+	require.NoError(t, err)
+	require.Equal(t, "session-1", replay.sessionID)
+
+	// the handlers would answer differently now.
+	nonce = 100
+
+	replayRouter := mux.NewRouter()
+	replayRouter.Use(replay.middleware)
+	replayRouter.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleError(w, http.StatusTeapot, "not replayed")
+	})
+
+	t.Run("responses are served in recorded sequence", func(t *testing.T) {
+		rr := serve(replayRouter, httptest.NewRequest(http.MethodGet, "/session-1/authorize?state=new-state", nil))
+		require.Equal(t, http.StatusFound, rr.Code)
+		require.Equal(t, "https://wallet.example.com/cb?code=auth-code&state=new-state", rr.Header().Get("Location"))
+
+		for _, recorded := range append(recordedTokens, recordedTokens[1]) {
+			rr = serve(replayRouter, httptest.NewRequest(http.MethodPost, "/session-1/token", nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, recorded, rr.Body.String())
+			require.NotEmpty(t, rr.Header().Get("Date"))
+		}
+
+		date, err := http.ParseTime(rr.Header().Get("Date"))
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), date, time.Minute)
+
+		rr = serve(replayRouter, httptest.NewRequest(http.MethodPost, "/session-1/credential", nil))
+		require.Contains(t, rr.Body.String(), largeCredential)
+	})
+
+	t.Run("routes not recorded are handled", func(t *testing.T) {
+		rr := serve(replayRouter, httptest.NewRequest(http.MethodPost, "/session-1/authorize", nil))
+		require.Equal(t, http.StatusTeapot, rr.Code)
+	})
+
+	t.Run("truncated transcript", func(t *testing.T) {
+		session := &transcript{ID: "session-2", Entries: []*transcriptEntry{{
+			Kind: transcriptEntryHTTP,
+			HTTP: &httpExchange{Method: http.MethodPost, URL: "/session-2/credential", Truncated: true},
+		}}}
+
+		recordings.save(session)
+
+		_, err = newReplayer(filepath.Join(dir, "session-2"+recordingFileExt))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "truncated")
+	})
+
+	t.Run("linked key is merged into the session's recording", func(t *testing.T) {
+		transcripts.recordToken("pending-code", "authorization_code", "pending-code")
+		require.FileExists(t, filepath.Join(dir, url.PathEscape("pending-code")+recordingFileExt))
+
+		transcripts.link("pending-code", "session-1")
+
+		_, err = os.Stat(filepath.Join(dir, "pending-code"+recordingFileExt))
+		require.True(t, os.IsNotExist(err))
+
+		recordingBytes, err := os.ReadFile(recording) //nolint:gosec
+		require.NoError(t, err)
+		require.Contains(t, string(recordingBytes), "authorization_code")
+	})
+}
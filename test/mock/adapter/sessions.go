@@ -0,0 +1,346 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+)
+
+// admin API creating issuer and verifier sessions, for tests setting up scenarios without the demo pages.
+const (
+	adminSessionsPath            = "/admin/sessions/"
+	waciIssuanceSessionPath      = adminSessionsPath + "waci-issuance"
+	waciShareSessionPath         = adminSessionsPath + "waci-share"
+	oidcIssuanceSessionPath      = adminSessionsPath + "oidc-issuance"
+	oidcShareSessionPath         = adminSessionsPath + "oidc-share"
+	openid4vcIssuanceSessionPath = adminSessionsPath + "openid4vc-issuance"
+	openid4vcShareSessionPath    = adminSessionsPath + "openid4vc-share"
+)
+
+// adminSession is the session created through admin API, with what the holder needs to take part in it.
+type adminSession struct {
+	SessionID     string          `json:"session_id"`
+	Invitation    json.RawMessage `json:"invitation,omitempty"`
+	InvitationURL string          `json:"invitation_url,omitempty"`
+	DeepLink      string          `json:"deep_link,omitempty"`
+	Offer         string          `json:"offer,omitempty"`
+	RequestURI    string          `json:"request_uri,omitempty"`
+	Pin           string          `json:"pin,omitempty"`
+}
+
+// waciSessionRequest are the settings of WACI session, the DIDComm version defaults to v1.
+type waciSessionRequest struct {
+	DIDCommVersion service.Version `json:"didcomm_version"`
+	Connectionless bool            `json:"connectionless"`
+	WalletURL      string          `json:"wallet_url"`
+}
+
+type waciIssuanceSessionRequest struct {
+	waciSessionRequest
+	CredentialManifest json.RawMessage `json:"credential_manifest"`
+	Credential         json.RawMessage `json:"credential"`
+	CredentialFormat   string          `json:"credential_format"`
+}
+
+type waciShareSessionRequest struct {
+	waciSessionRequest
+	PresentationDefinition json.RawMessage `json:"presentation_definition"`
+	Profile                string          `json:"profile"`
+}
+
+type oidcShareSessionRequest struct {
+	WalletAuthURL          string          `json:"wallet_auth_url"`
+	PresentationDefinition json.RawMessage `json:"presentation_definition"`
+	Profile                string          `json:"profile"`
+}
+
+type openid4vcShareSessionRequest struct {
+	Profile string `json:"profile"`
+}
+
+func (v *adapterApp) createWACIIssuanceSession(w http.ResponseWriter, r *http.Request) {
+	var request waciIssuanceSessionRequest
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	if request.CredentialFormat == "" {
+		request.CredentialFormat = credentialFormatManifest
+	}
+
+	err := request.validate()
+	if err == nil && len(request.Credential) == 0 {
+		err = errors.New("credential is required")
+	}
+
+	if err == nil && request.CredentialFormat == credentialFormatManifest && len(request.CredentialManifest) == 0 {
+		err = fmt.Errorf("credential_manifest is required for credential format '%s'", credentialFormatManifest)
+	}
+
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	waciData := &waciIssuanceData{Credential: request.Credential, CredentialFormat: request.CredentialFormat}
+	if request.CredentialFormat == credentialFormatManifest {
+		waciData.CredentialManifest = request.CredentialManifest
+	}
+
+	invID, inv, err := v.createWACIIssuanceInvitation(request.DIDCommVersion, request.Connectionless, waciData)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	v.writeWACISession(w, invID, request.WalletURL, inv)
+}
+
+func (v *adapterApp) createWACIShareSession(w http.ResponseWriter, r *http.Request) {
+	var request waciShareSessionRequest
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	err := request.validate()
+	if err == nil {
+		err = validatePresentationDefinition(request.PresentationDefinition)
+	}
+
+	if err == nil {
+		err = v.validateVerifierProfile(request.Profile)
+	}
+
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	invID, inv, err := v.createWACIShareInvitation(request.DIDCommVersion, request.Connectionless,
+		request.PresentationDefinition, request.Profile)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	v.writeWACISession(w, invID, request.WalletURL, inv)
+}
+
+func (v *adapterApp) createOIDCIssuanceSession(w http.ResponseWriter, r *http.Request) {
+	var request oidcIssuanceParams
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	err := validateURL("wallet_init_issuance_url", request.WalletInitIssuanceURL)
+	if err == nil {
+		err = validateURL("issuer_url", request.IssuerURL)
+	}
+
+	if err == nil && len(request.Credentials) == 0 {
+		err = errors.New("credentials are required")
+	}
+
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	key, walletURL, err := v.createOIDCIssuance(&request)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	writeAdminSession(w, &adminSession{SessionID: key, Offer: walletURL})
+}
+
+func (v *adapterApp) createOpenID4VCIssuanceSession(w http.ResponseWriter, r *http.Request) {
+	var request openid4vcIssuanceParams
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	err := validateURL("issuer_url", request.IssuerURL)
+	if err == nil && request.CredentialType == "" {
+		err = errors.New("credential_type is required")
+	}
+
+	if err == nil && len(request.Credentials) == 0 {
+		err = errors.New("credentials are required")
+	}
+
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	issuance, err := v.createOpenID4VCIssuance(&request)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	writeAdminSession(w, &adminSession{SessionID: issuance.key, Offer: issuance.InitiateUrl, Pin: issuance.Pin})
+}
+
+func (v *adapterApp) createOIDCShareSession(w http.ResponseWriter, r *http.Request) {
+	var request oidcShareSessionRequest
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	err := validateURL("wallet_auth_url", request.WalletAuthURL)
+	if err == nil {
+		err = validatePresentationDefinition(request.PresentationDefinition)
+	}
+
+	if err == nil {
+		err = v.validateVerifierProfile(request.Profile)
+	}
+
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	state, requestURI, err := v.createOIDCShareRequest(request.WalletAuthURL, request.PresentationDefinition,
+		request.Profile)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	writeAdminSession(w, &adminSession{SessionID: state, RequestURI: requestURI})
+}
+
+func (v *adapterApp) createOpenID4VCShareSession(w http.ResponseWriter, r *http.Request) {
+	var request openid4vcShareSessionRequest
+
+	if !decodeSessionRequest(w, r, &request) {
+		return
+	}
+
+	err := v.validateVerifierProfile(request.Profile)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	state, err := v.createOpenID4VCShareState(request.Profile)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	writeAdminSession(w, &adminSession{SessionID: state, RequestURI: v.openid4vcShareRequestURI(state)})
+}
+
+// writeWACISession saves the invitation to be served by its short URL, and returns it with its links.
+func (v *adapterApp) writeWACISession(w http.ResponseWriter, invID, walletURL string, inv interface{}) {
+	oobInv, err := v.saveOOBInvitation(invID, walletURL, inv)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	writeAdminSession(w, &adminSession{
+		SessionID:     invID,
+		Invitation:    oobInv.Invitation,
+		InvitationURL: v.oobInvitationURL(invID),
+		DeepLink:      v.deepLink(invID, walletURL),
+	})
+}
+
+func (r *waciSessionRequest) validate() error {
+	if r.DIDCommVersion == "" {
+		r.DIDCommVersion = service.V1
+	}
+
+	if r.DIDCommVersion != service.V1 && r.DIDCommVersion != service.V2 {
+		return fmt.Errorf("unsupported didcomm_version '%s', expected %s or %s", r.DIDCommVersion,
+			service.V1, service.V2)
+	}
+
+	return validateURL("wallet_url", r.WalletURL)
+}
+
+func decodeSessionRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(request)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("failed to decode session request : %s", err))
+
+		return false
+	}
+
+	return true
+}
+
+func validateURL(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("%s must be absolute URL", name)
+	}
+
+	return nil
+}
+
+func validatePresentationDefinition(pdBytes json.RawMessage) error {
+	if len(pdBytes) == 0 {
+		return errors.New("presentation_definition is required")
+	}
+
+	var pd map[string]interface{}
+
+	err := json.Unmarshal(pdBytes, &pd)
+	if err != nil || pd == nil {
+		return errors.New("presentation_definition must be JSON object")
+	}
+
+	return nil
+}
+
+func writeAdminSession(w http.ResponseWriter, session *adminSession) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err := json.NewEncoder(w).Encode(session)
+	if err != nil {
+		logger.Errorf("failed to write response : %s", err)
+	}
+}
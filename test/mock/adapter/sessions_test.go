@@ -0,0 +1,190 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminSessions(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	create := func(t *testing.T, handler http.HandlerFunc, path, body string) (*adminSession, *httptest.ResponseRecorder) {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+
+		if rr.Code != http.StatusCreated {
+			return nil, rr
+		}
+
+		var session adminSession
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &session))
+		require.NotEmpty(t, session.SessionID)
+
+		return &session, rr
+	}
+
+	t.Run("WACI share", func(t *testing.T) {
+		session, rr := create(t, app.createWACIShareSession, waciShareSessionPath, `{
+			"wallet_url": "https://wallet.example.com",
+			"presentation_definition": {"id": "pd-1", "input_descriptors": []}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		var inv outofband.Invitation
+
+		require.NoError(t, json.Unmarshal(session.Invitation, &inv))
+		require.Equal(t, session.SessionID, inv.ID)
+		require.Equal(t, app.oobInvitationURL(inv.ID), session.InvitationURL)
+		require.Equal(t, "https://wallet.example.com/waci?oob_url="+url.QueryEscape(session.InvitationURL),
+			session.DeepLink)
+		require.JSONEq(t, string(session.Invitation), string(resolveWACIInvitation(t, app, session.InvitationURL)))
+
+		pdBytes, err := app.store.Get(getPresentationDefinitionKeyPrefix(inv.ID))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"id": "pd-1", "input_descriptors": []}`, string(pdBytes))
+	})
+
+	t.Run("connectionless WACI share", func(t *testing.T) {
+		session, rr := create(t, app.createWACIShareSession, waciShareSessionPath, `{
+			"wallet_url": "https://wallet.example.com",
+			"connectionless": true,
+			"presentation_definition": {"id": "pd-2", "input_descriptors": []}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		var inv outofband.Invitation
+
+		require.NoError(t, json.Unmarshal(session.Invitation, &inv))
+		require.Len(t, inv.Requests, 1)
+	})
+
+	t.Run("WACI issuance", func(t *testing.T) {
+		session, rr := create(t, app.createWACIIssuanceSession, waciIssuanceSessionPath, `{
+			"wallet_url": "https://wallet.example.com",
+			"credential_manifest": {"id": "manifest-1"},
+			"credential": {"id": "vc-1"}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		waciData, err := readWACIIssuanceData(app.store, session.SessionID, "")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"id": "manifest-1"}`, string(waciData.CredentialManifest))
+		require.Equal(t, credentialFormatManifest, waciData.CredentialFormat)
+
+		status, err := app.readWACIIssuanceStatus(session.SessionID)
+		require.NoError(t, err)
+		require.NotNil(t, status)
+	})
+
+	t.Run("OIDC issuance", func(t *testing.T) {
+		session, rr := create(t, app.createOIDCIssuanceSession, oidcIssuanceSessionPath, `{
+			"wallet_init_issuance_url": "https://wallet.example.com/initiate",
+			"issuer_url": "https://adapter.example.com",
+			"credential_types": ["PermanentResidentCard"],
+			"manifest_ids": ["prc"],
+			"credentials": {"PermanentResidentCard": {"id": "vc-1"}}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		offer, err := url.Parse(session.Offer)
+		require.NoError(t, err)
+		require.Equal(t, "https://adapter.example.com/"+session.SessionID, offer.Query().Get("issuer"))
+		require.Equal(t, "PermanentResidentCard", offer.Query().Get("credential_type"))
+
+		credential, err := app.store.Get(getCredStoreKeyPrefix(session.SessionID, "PermanentResidentCard"))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"id": "vc-1"}`, string(credential))
+	})
+
+	t.Run("OpenID4VC issuance", func(t *testing.T) {
+		session, rr := create(t, app.createOpenID4VCIssuanceSession, openid4vcIssuanceSessionPath, `{
+			"issuer_url": "https://adapter.example.com",
+			"credential_type": "PermanentResidentCard",
+			"credentials_supported": [],
+			"credentials": {"PermanentResidentCard": {"id": "vc-1"}}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		require.Len(t, session.Pin, 6)
+		require.True(t, strings.HasPrefix(session.Offer, "openid-initiate-issuance://?issuer="))
+		require.Contains(t, session.Offer, url.QueryEscape("https://adapter.example.com/"+session.SessionID))
+	})
+
+	t.Run("OIDC share", func(t *testing.T) {
+		session, rr := create(t, app.createOIDCShareSession, oidcShareSessionPath, `{
+			"wallet_auth_url": "https://wallet.example.com/auth",
+			"presentation_definition": {"id": "pd-3", "input_descriptors": []}
+		}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		requestURI, err := url.Parse(session.RequestURI)
+		require.NoError(t, err)
+		require.Equal(t, session.SessionID, requestURI.Query().Get("state"))
+		require.Contains(t, requestURI.Query().Get("claims"), "pd-3")
+	})
+
+	t.Run("OpenID4VC share", func(t *testing.T) {
+		session, rr := create(t, app.createOpenID4VCShareSession, openid4vcShareSessionPath,
+			`{"profile": "`+defaultVerifierProfile+`"}`)
+		require.NotNil(t, session, rr.Body.String())
+
+		require.Equal(t, app.openid4vcShareRequestURI(session.SessionID), session.RequestURI)
+		require.Equal(t, defaultVerifierProfile, app.readVerifierProfileID(session.SessionID))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		for _, tc := range []struct {
+			handler http.HandlerFunc
+			body    string
+			err     string
+		}{
+			{app.createWACIShareSession, `{"wallet_url": "https://wallet.example.com"`, "failed to decode session request"},
+			{app.createWACIShareSession, `{"walletURL": "https://wallet.example.com"}`, "unknown field"},
+			{app.createWACIShareSession, `{}`, "wallet_url is required"},
+			{
+				app.createWACIShareSession,
+				`{"wallet_url": "https://wallet.example.com", "didcomm_version": "v3"}`,
+				"unsupported didcomm_version 'v3'",
+			},
+			{
+				app.createWACIShareSession, `{"wallet_url": "https://wallet.example.com"}`,
+				"presentation_definition is required",
+			},
+			{
+				app.createWACIShareSession,
+				`{"wallet_url": "https://wallet.example.com", "presentation_definition": {}, "profile": "unknown"}`,
+				"unknown verifier profile 'unknown'",
+			},
+			{
+				app.createWACIIssuanceSession,
+				`{"wallet_url": "https://wallet.example.com", "credential": {"id": "vc-1"}}`,
+				"credential_manifest is required",
+			},
+			{app.createOIDCIssuanceSession, `{"wallet_init_issuance_url": "wallet"}`, "must be absolute URL"},
+			{app.createOpenID4VCIssuanceSession, `{"issuer_url": "https://adapter.example.com"}`, "credential_type"},
+			{app.createOIDCShareSession, `{"wallet_auth_url": "https://wallet.example.com/auth",
+				"presentation_definition": []}`, "presentation_definition must be JSON object"},
+			{app.createOpenID4VCShareSession, `{"profile": "unknown"}`, "unknown verifier profile"},
+		} {
+			_, rr := create(t, tc.handler, adminSessionsPath, tc.body)
+			require.Equal(t, http.StatusBadRequest, rr.Code, tc.body)
+			require.Contains(t, rr.Body.String(), tc.err, tc.body)
+		}
+	})
+}
@@ -0,0 +1,484 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/piprate/json-gold/ld"
+	tlsutils "github.com/trustbloc/edge-core/pkg/utils/tls"
+)
+
+const (
+	statusList2021EntryType      = "StatusList2021Entry"
+	revocationList2020StatusType = "RevocationList2020Status"
+
+	statusPurposeRevocation = "revocation"
+	statusPurposeSuspension = "suspension"
+
+	defaultStatusListCacheTTL = 5 * time.Minute
+	statusListRequestTimeout  = 10 * time.Second
+)
+
+// credentialStatusOutcome is the outcome of checking a single credential's status.
+type credentialStatusOutcome string
+
+const (
+	credentialStatusValid       credentialStatusOutcome = "valid"
+	credentialStatusRevoked     credentialStatusOutcome = "revoked"
+	credentialStatusSuspended   credentialStatusOutcome = "suspended"
+	credentialStatusUnreachable credentialStatusOutcome = "unreachable"
+	credentialStatusInvalid     credentialStatusOutcome = "invalid"
+	credentialStatusUnsupported credentialStatusOutcome = "unsupported"
+	credentialStatusNone        credentialStatusOutcome = "none"
+)
+
+// credentialStatusResult is the status check result for one credential in a presentation.
+type credentialStatusResult struct {
+	CredentialID string                  `json:"credential_id,omitempty"`
+	StatusType   string                  `json:"status_type,omitempty"`
+	StatusList   string                  `json:"status_list,omitempty"`
+	Outcome      credentialStatusOutcome `json:"outcome"`
+	Error        string                  `json:"error,omitempty"`
+}
+
+// verificationResult is returned by the mock verifiers once a presentation was received.
+type verificationResult struct {
+	Verified         bool                      `json:"verified"`
+	Error            string                    `json:"error,omitempty"`
+	CredentialStatus []*credentialStatusResult `json:"credential_status,omitempty"`
+	PolicyViolation  *policyViolation          `json:"policy_violation,omitempty"`
+}
+
+// rejected tells whether the status outcome should fail the verification.
+func (r *credentialStatusResult) rejected() bool {
+	switch r.Outcome {
+	case credentialStatusRevoked, credentialStatusSuspended, credentialStatusUnreachable, credentialStatusInvalid:
+		return true
+	default:
+		return false
+	}
+}
+
+type cachedStatusList struct {
+	issuer    string
+	bitstring []byte
+	expiry    time.Time
+}
+
+// invalidStatusListError is returned for status list credentials which were fetched, but can't be used.
+type invalidStatusListError struct {
+	err error
+}
+
+func (e *invalidStatusListError) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidStatusListError) Unwrap() error {
+	return e.err
+}
+
+// statusListChecker resolves StatusList2021 and RevocationList2020 credentials and checks
+// credential status entries against them. Proofs of status list credentials are verified, and lists
+// are only used for credentials of their issuer. Fetched lists are cached for the configured TTL.
+type statusListChecker struct {
+	httpClient *http.Client
+	ttl        time.Duration
+	keyFetcher verifiable.PublicKeyFetcher
+	docLoader  ld.DocumentLoader
+
+	mu    sync.Mutex
+	cache map[string]*cachedStatusList
+}
+
+func newStatusListChecker(httpClient *http.Client, ttl time.Duration, vdr vdrapi.Registry) *statusListChecker {
+	return &statusListChecker{
+		httpClient: httpClient,
+		ttl:        ttl,
+		keyFetcher: verifiable.NewVDRKeyResolver(vdr).PublicKeyFetcher(),
+		docLoader:  ld.NewDefaultDocumentLoader(nil),
+		cache:      map[string]*cachedStatusList{},
+	}
+}
+
+// buildStatusListChecker creates status list checker using configured CA certs and cache TTL, which resolves
+// status list issuers' keys with given VDR.
+func buildStatusListChecker(cfg *adapterConfig, vdr vdrapi.Registry) (*statusListChecker, error) {
+	rootCAs, err := tlsutils.GetCertPool(true, cfg.TLSCACerts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup root ca for status list client : %w", err)
+	}
+
+	ttl := cfg.StatusListCacheTTL
+	if ttl == 0 {
+		ttl = defaultStatusListCacheTTL
+	}
+
+	return newStatusListChecker(&http.Client{
+		Timeout: statusListRequestTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+		},
+	}, ttl, vdr), nil
+}
+
+// checkPresentation checks status of every credential in given presentation.
+func (c *statusListChecker) checkPresentation(vp *verifiable.Presentation) (*verificationResult, error) {
+	creds, err := vp.MarshalledCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presentation credentials : %w", err)
+	}
+
+	result := &verificationResult{Verified: true}
+
+	for _, cred := range creds {
+		status := c.checkCredential(cred)
+		if status.rejected() {
+			result.Verified = false
+		}
+
+		result.CredentialStatus = append(result.CredentialStatus, status)
+	}
+
+	return result, nil
+}
+
+// checkCredential checks status of a single JSON-LD or JWT credential. Credentials may have several status
+// entries, e.g. for revocation and suspension, the result is the one of the first entry rejecting the credential,
+// else of the first unsupported entry.
+func (c *statusListChecker) checkCredential(raw []byte) *credentialStatusResult {
+	vc, err := decodeCredentialJSON(raw)
+	if err != nil {
+		return &credentialStatusResult{Outcome: credentialStatusUnsupported, Error: err.Error()}
+	}
+
+	credentialID, _ := vc["id"].(string) //nolint:errcheck
+
+	var entries []interface{}
+
+	switch status := vc["credentialStatus"].(type) {
+	case map[string]interface{}:
+		entries = []interface{}{status}
+	case []interface{}:
+		entries = status
+	}
+
+	var result *credentialStatusResult
+
+	for _, entry := range entries {
+		entryResult := &credentialStatusResult{CredentialID: credentialID, Outcome: credentialStatusInvalid,
+			Error: "invalid credential status entry"}
+
+		if status, ok := entry.(map[string]interface{}); ok {
+			entryResult = c.checkStatusEntry(vc, status)
+			entryResult.CredentialID = credentialID
+		}
+
+		if entryResult.rejected() {
+			return entryResult
+		}
+
+		if result == nil || result.Outcome != credentialStatusUnsupported {
+			result = entryResult
+		}
+	}
+
+	if result == nil {
+		return &credentialStatusResult{CredentialID: credentialID, Outcome: credentialStatusNone}
+	}
+
+	return result
+}
+
+// checkStatusEntry checks one credential status entry of the credential.
+func (c *statusListChecker) checkStatusEntry(vc, status map[string]interface{}) *credentialStatusResult {
+	result := &credentialStatusResult{}
+	result.StatusType, _ = status["type"].(string) //nolint:errcheck
+
+	var (
+		listURL string
+		index   string
+		purpose string
+	)
+
+	switch result.StatusType {
+	case statusList2021EntryType:
+		listURL, _ = status["statusListCredential"].(string) //nolint:errcheck
+		index = stringValue(status["statusListIndex"])
+		purpose, _ = status["statusPurpose"].(string) //nolint:errcheck
+	case revocationList2020StatusType:
+		listURL, _ = status["revocationListCredential"].(string) //nolint:errcheck
+		index = stringValue(status["revocationListIndex"])
+		purpose = statusPurposeRevocation
+	default:
+		result.Outcome = credentialStatusUnsupported
+		result.Error = fmt.Sprintf("unsupported credential status type '%s'", result.StatusType)
+
+		return result
+	}
+
+	result.StatusList = listURL
+
+	idx, err := strconv.Atoi(index)
+	if err != nil || idx < 0 || listURL == "" {
+		result.Outcome = credentialStatusInvalid
+		result.Error = "invalid credential status entry"
+
+		return result
+	}
+
+	list, err := c.statusList(listURL)
+	if err != nil {
+		var invalidErr *invalidStatusListError
+
+		result.Outcome = credentialStatusUnreachable
+		if errors.As(err, &invalidErr) {
+			result.Outcome = credentialStatusInvalid
+		}
+
+		result.Error = err.Error()
+
+		return result
+	}
+
+	if issuer := credentialIssuer(vc); list.issuer != issuer {
+		result.Outcome = credentialStatusInvalid
+		result.Error = fmt.Sprintf("status list issued by '%s', credential by '%s'", list.issuer, issuer)
+
+		return result
+	}
+
+	bitstring := list.bitstring
+
+	if idx/8 >= len(bitstring) {
+		result.Outcome = credentialStatusInvalid
+		result.Error = fmt.Sprintf("status list index %d out of range", idx)
+
+		return result
+	}
+
+	// bit 0 is the left-most bit of the first byte.
+	if bitstring[idx/8]&(1<<(7-uint(idx%8))) == 0 {
+		result.Outcome = credentialStatusValid
+
+		return result
+	}
+
+	if purpose == statusPurposeSuspension {
+		result.Outcome = credentialStatusSuspended
+	} else {
+		result.Outcome = credentialStatusRevoked
+	}
+
+	return result
+}
+
+// statusList returns verified status list credential's issuer and decoded bitstring, using cache when possible.
+// Expired lists are evicted on lookup, so that the cache only holds lists used within the TTL.
+func (c *statusListChecker) statusList(listURL string) (*cachedStatusList, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+
+	for u, list := range c.cache {
+		if !now.Before(list.expiry) {
+			delete(c.cache, u)
+		}
+	}
+
+	cached, ok := c.cache[listURL]
+	c.mu.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	body, err := c.fetchStatusList(listURL)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := c.parseStatusList(body)
+	if err != nil {
+		return nil, &invalidStatusListError{err: err}
+	}
+
+	list.expiry = time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	c.cache[listURL] = list
+	c.mu.Unlock()
+
+	return list, nil
+}
+
+func (c *statusListChecker) fetchStatusList(listURL string) ([]byte, error) {
+	resp, err := c.httpClient.Get(listURL) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch status list : %w", err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch status list : status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status list : %w", err)
+	}
+
+	return body, nil
+}
+
+// parseStatusList verifies status list credential's proof and decodes its bitstring.
+func (c *statusListChecker) parseStatusList(body []byte) (*cachedStatusList, error) {
+	credential, err := verifiable.ParseCredential(body, verifiable.WithPublicKeyFetcher(c.keyFetcher),
+		verifiable.WithJSONLDDocumentLoader(c.docLoader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify status list credential : %w", err)
+	}
+
+	// credentials without proof, including unsecured JWTs, are parsed without error.
+	if credential.JWT == "" && len(credential.Proofs) == 0 {
+		return nil, fmt.Errorf("status list credential is missing proof")
+	}
+
+	vc, err := decodeCredentialJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse status list credential : %w", err)
+	}
+
+	subject, ok := vc["credentialSubject"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("status list credential is missing credentialSubject")
+	}
+
+	encodedList, ok := subject["encodedList"].(string)
+	if !ok {
+		return nil, fmt.Errorf("status list credential is missing encodedList")
+	}
+
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encodedList, "="))
+	if err != nil {
+		compressed, err = base64.StdEncoding.DecodeString(encodedList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode status list : %w", err)
+		}
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress status list : %w", err)
+	}
+
+	bitstring, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress status list : %w", err)
+	}
+
+	return &cachedStatusList{issuer: credential.Issuer.ID, bitstring: bitstring}, nil
+}
+
+// decodeCredentialJSON returns credential JSON object from JSON-LD credential or JWT credential
+// (either raw or as JSON string).
+func decodeCredentialJSON(raw []byte) (map[string]interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+
+	if len(raw) > 0 && raw[0] == '"' {
+		var jws string
+
+		if err := json.Unmarshal(raw, &jws); err != nil {
+			return nil, err
+		}
+
+		raw = []byte(jws)
+	}
+
+	if len(raw) > 0 && raw[0] == '{' {
+		var vc map[string]interface{}
+
+		return vc, json.Unmarshal(raw, &vc)
+	}
+
+	var claims struct {
+		VC     map[string]interface{} `json:"vc"`
+		Issuer string                 `json:"iss"`
+		ID     string                 `json:"jti"`
+		Expiry int64                  `json:"exp"`
+	}
+
+	if err := decodeJWTClaims(string(raw), &claims); err != nil {
+		return nil, err
+	}
+
+	if claims.VC == nil {
+		return nil, fmt.Errorf("JWT is missing vc claim")
+	}
+
+	// registered JWT claims take the place of their credential counterparts.
+	if _, ok := claims.VC["issuer"]; !ok && claims.Issuer != "" {
+		claims.VC["issuer"] = claims.Issuer
+	}
+
+	if _, ok := claims.VC["id"]; !ok && claims.ID != "" {
+		claims.VC["id"] = claims.ID
+	}
+
+	if _, ok := claims.VC["expirationDate"]; !ok && claims.Expiry != 0 {
+		claims.VC["expirationDate"] = time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339)
+	}
+
+	return claims.VC, nil
+}
+
+// decodeJWTClaims decodes payload of compact JWT without verifying it.
+func decodeJWTClaims(compactJWT string, claims interface{}) error {
+	parts := strings.Split(compactJWT, ".")
+	if len(parts) != 3 { //nolint:gomnd
+		return fmt.Errorf("value is neither JSON nor JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("failed to decode JWT payload : %w", err)
+	}
+
+	err = json.Unmarshal(payload, claims)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JWT payload : %w", err)
+	}
+
+	return nil
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
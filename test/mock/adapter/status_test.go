@@ -0,0 +1,331 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/util"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/verifiable"
+	vdrpkg "github.com/hyperledger/aries-framework-go/pkg/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/key"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusListChecker(t *testing.T) {
+	serveEmbeddedContexts(t)
+
+	lists := newTestStatusLists(t)
+
+	checker := newStatusListChecker(http.DefaultClient, time.Hour, vdrpkg.New(vdrpkg.WithVDR(key.New())))
+
+	check := func(status interface{}) *credentialStatusResult {
+		vc, err := json.Marshal(map[string]interface{}{
+			"id":               "urn:uuid:credential-1",
+			"issuer":           map[string]interface{}{"id": didKey},
+			"credentialStatus": status,
+		})
+		require.NoError(t, err)
+
+		return checker.checkCredential(vc)
+	}
+
+	statusList2021Entry := func(listURL, purpose string, index int) map[string]interface{} {
+		return map[string]interface{}{
+			"type":                 statusList2021EntryType,
+			"statusPurpose":        purpose,
+			"statusListIndex":      fmt.Sprint(index),
+			"statusListCredential": listURL,
+		}
+	}
+
+	t.Run("bit 0 is the left-most bit of the first byte", func(t *testing.T) {
+		listURL := lists.add(t, "/bits", signedStatusList(t, didKey, statusPurposeRevocation, 0, 9))
+
+		for index, outcome := range map[int]credentialStatusOutcome{
+			0:  credentialStatusRevoked,
+			1:  credentialStatusValid,
+			7:  credentialStatusValid,
+			8:  credentialStatusValid,
+			9:  credentialStatusRevoked,
+			15: credentialStatusValid,
+		} {
+			result := check(statusList2021Entry(listURL, statusPurposeRevocation, index))
+			require.Equal(t, outcome, result.Outcome, "index %d", index)
+			require.Equal(t, listURL, result.StatusList)
+		}
+
+		result := check(statusList2021Entry(listURL, statusPurposeRevocation, 16))
+		require.Equal(t, credentialStatusInvalid, result.Outcome)
+		require.Contains(t, result.Error, "out of range")
+	})
+
+	t.Run("status purpose", func(t *testing.T) {
+		revocationURL := lists.add(t, "/revocation", signedStatusList(t, didKey, statusPurposeRevocation, 3))
+		suspensionURL := lists.add(t, "/suspension", signedStatusList(t, didKey, statusPurposeSuspension, 3))
+
+		result := check(statusList2021Entry(revocationURL, statusPurposeRevocation, 3))
+		require.Equal(t, credentialStatusRevoked, result.Outcome)
+		require.True(t, result.rejected())
+
+		result = check(statusList2021Entry(suspensionURL, statusPurposeSuspension, 3))
+		require.Equal(t, credentialStatusSuspended, result.Outcome)
+		require.True(t, result.rejected())
+
+		result = check(statusList2021Entry(suspensionURL, statusPurposeSuspension, 4))
+		require.Equal(t, credentialStatusValid, result.Outcome)
+		require.False(t, result.rejected())
+
+		// RevocationList2020 entries have no purpose, set bits revoke.
+		result = check(map[string]interface{}{
+			"type":                     revocationList2020StatusType,
+			"revocationListIndex":      3,
+			"revocationListCredential": revocationURL,
+		})
+		require.Equal(t, credentialStatusRevoked, result.Outcome)
+	})
+
+	t.Run("status lists are cached for TTL", func(t *testing.T) {
+		listURL := lists.add(t, "/cached", signedStatusList(t, didKey, statusPurposeRevocation))
+
+		for i := 0; i < 3; i++ {
+			require.Equal(t, credentialStatusValid, check(statusList2021Entry(listURL, statusPurposeRevocation, 1)).Outcome)
+		}
+
+		require.Equal(t, 1, lists.fetches("/cached"))
+
+		// the list was updated meanwhile, which is seen once the cached one expires.
+		lists.add(t, "/cached", signedStatusList(t, didKey, statusPurposeRevocation, 1))
+
+		checker.mu.Lock()
+		checker.cache[listURL].expiry = time.Now().Add(-time.Second)
+		checker.mu.Unlock()
+
+		require.Equal(t, credentialStatusRevoked, check(statusList2021Entry(listURL, statusPurposeRevocation, 1)).Outcome)
+		require.Equal(t, 2, lists.fetches("/cached"))
+	})
+
+	t.Run("expired status lists are evicted", func(t *testing.T) {
+		expiredURL := lists.add(t, "/expired", signedStatusList(t, didKey, statusPurposeRevocation))
+		listURL := lists.add(t, "/evicting", signedStatusList(t, didKey, statusPurposeRevocation))
+
+		require.Equal(t, credentialStatusValid, check(statusList2021Entry(expiredURL, statusPurposeRevocation, 1)).Outcome)
+
+		checker.mu.Lock()
+		checker.cache[expiredURL].expiry = time.Now().Add(-time.Second)
+		checker.mu.Unlock()
+
+		// looking up any list evicts the expired one.
+		require.Equal(t, credentialStatusValid, check(statusList2021Entry(listURL, statusPurposeRevocation, 1)).Outcome)
+
+		checker.mu.Lock()
+		defer checker.mu.Unlock()
+
+		require.NotContains(t, checker.cache, expiredURL)
+		require.Contains(t, checker.cache, listURL)
+	})
+
+	t.Run("every status entry is checked", func(t *testing.T) {
+		revocationURL := lists.add(t, "/entries-revocation", signedStatusList(t, didKey, statusPurposeRevocation))
+		suspensionURL := lists.add(t, "/entries-suspension", signedStatusList(t, didKey, statusPurposeSuspension, 2))
+		unsupported := map[string]interface{}{"type": "CredentialStatusList2017"}
+
+		result := check([]interface{}{
+			statusList2021Entry(revocationURL, statusPurposeRevocation, 2),
+			statusList2021Entry(suspensionURL, statusPurposeSuspension, 2),
+		})
+		require.Equal(t, credentialStatusSuspended, result.Outcome)
+		require.Equal(t, suspensionURL, result.StatusList)
+		require.Equal(t, "urn:uuid:credential-1", result.CredentialID)
+
+		result = check([]interface{}{
+			statusList2021Entry(revocationURL, statusPurposeRevocation, 2),
+			statusList2021Entry(suspensionURL, statusPurposeSuspension, 3),
+		})
+		require.Equal(t, credentialStatusValid, result.Outcome)
+
+		result = check([]interface{}{
+			unsupported, statusList2021Entry(revocationURL, statusPurposeRevocation, 2),
+		})
+		require.Equal(t, credentialStatusUnsupported, result.Outcome)
+		require.Contains(t, result.Error, "CredentialStatusList2017")
+
+		result = check([]interface{}{statusList2021Entry(revocationURL, statusPurposeRevocation, 2), "urn:status"})
+		require.Equal(t, credentialStatusInvalid, result.Outcome)
+		require.True(t, result.rejected())
+
+		require.Equal(t, credentialStatusNone, check([]interface{}{}).Outcome)
+	})
+
+	t.Run("unreachable status list", func(t *testing.T) {
+		for _, listURL := range []string{lists.url + "/missing", "http://127.0.0.1:1/status/1"} {
+			result := check(statusList2021Entry(listURL, statusPurposeRevocation, 1))
+			require.Equal(t, credentialStatusUnreachable, result.Outcome)
+			require.Contains(t, result.Error, "failed to fetch status list")
+			require.True(t, result.rejected())
+		}
+	})
+
+	t.Run("status list that can't be trusted", func(t *testing.T) {
+		signed := signedStatusList(t, didKey, statusPurposeRevocation)
+		parts := strings.Split(signed, ".")
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+
+		signature[0] ^= 0xff
+
+		for path, test := range map[string]struct {
+			list string
+			err  string
+		}{
+			"/unsigned": {
+				list: fmt.Sprintf(`{"@context": ["https://www.w3.org/2018/credentials/v1",
+						"https://w3id.org/vc/status-list/2021/v1"],
+					"id": "urn:uuid:list", "type": ["VerifiableCredential", "StatusList2021Credential"],
+					"issuer": "%s", "issuanceDate": "2022-01-01T00:00:00Z",
+					"credentialSubject": {"id": "urn:uuid:list#list", "type": "StatusList2021",
+						"statusPurpose": "revocation", "encodedList": "%s"}}`, didKey, encodeStatusList(t)),
+				err: "missing proof",
+			},
+			"/tampered": {
+				list: strings.Join([]string{parts[0], parts[1], base64.RawURLEncoding.EncodeToString(signature)}, "."),
+				err:  "failed to verify status list credential",
+			},
+			"/other-issuer": {
+				list: signedStatusList(t, "did:example:other", statusPurposeRevocation),
+				err:  "status list issued by 'did:example:other'",
+			},
+		} {
+			result := check(statusList2021Entry(lists.add(t, path, test.list), statusPurposeRevocation, 1))
+			require.Equal(t, credentialStatusInvalid, result.Outcome, path)
+			require.Contains(t, result.Error, test.err, path)
+			require.True(t, result.rejected())
+		}
+	})
+
+	t.Run("credential without status", func(t *testing.T) {
+		require.Equal(t, credentialStatusNone, checker.checkCredential([]byte(`{"id": "urn:uuid:credential-2"}`)).Outcome)
+	})
+}
+
+// testStatusLists serves status list credentials, counting fetches of each.
+type testStatusLists struct {
+	url string
+
+	mu      sync.Mutex
+	lists   map[string]string
+	fetched map[string]int
+}
+
+func newTestStatusLists(t *testing.T) *testStatusLists {
+	t.Helper()
+
+	lists := &testStatusLists{lists: map[string]string{}, fetched: map[string]int{}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists.mu.Lock()
+		list, ok := lists.lists[r.URL.Path]
+		lists.fetched[r.URL.Path]++
+		lists.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, err := w.Write([]byte(list))
+		require.NoError(t, err)
+	}))
+
+	t.Cleanup(server.Close)
+
+	lists.url = server.URL
+
+	return lists
+}
+
+// add serves the list at given path and returns its URL.
+func (l *testStatusLists) add(t *testing.T, path, list string) string {
+	t.Helper()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lists[path] = list
+
+	return l.url + path
+}
+
+func (l *testStatusLists) fetches(path string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.fetched[path]
+}
+
+// signedStatusList returns JWT status list credential with given bits set, signed with the adapter's did:key
+// whatever the issuer.
+func signedStatusList(t *testing.T, issuer, purpose string, setBits ...int) string {
+	t.Helper()
+
+	vc := &verifiable.Credential{
+		Context: []string{"https://www.w3.org/2018/credentials/v1", "https://w3id.org/vc/status-list/2021/v1"},
+		ID:      "urn:uuid:status-list",
+		Types:   []string{"VerifiableCredential", "StatusList2021Credential"},
+		Issuer:  verifiable.Issuer{ID: issuer},
+		Issued:  util.NewTime(time.Now()),
+		Subject: verifiable.Subject{
+			ID: "urn:uuid:status-list#list",
+			CustomFields: map[string]interface{}{
+				"type":          "StatusList2021",
+				"statusPurpose": purpose,
+				"encodedList":   encodeStatusList(t, setBits...),
+			},
+		},
+	}
+
+	claims, err := vc.JWTClaims(false)
+	require.NoError(t, err)
+
+	jws, err := signJWTCredentialWithED25519(claims)
+	require.NoError(t, err)
+
+	return jws
+}
+
+// encodeStatusList returns encoded 16 bit list with given bits set.
+func encodeStatusList(t *testing.T, setBits ...int) string {
+	t.Helper()
+
+	bitstring := make([]byte, 2)
+
+	for _, bit := range setBits {
+		bitstring[bit/8] |= 1 << (7 - uint(bit%8))
+	}
+
+	var compressed bytes.Buffer
+
+	writer := gzip.NewWriter(&compressed)
+
+	_, err := writer.Write(bitstring)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return base64.RawURLEncoding.EncodeToString(compressed.Bytes())
+}
@@ -0,0 +1,537 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go-ext/component/storage/mongodb"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	databaseTypeMemOption     = "mem"
+	databaseTypeLevelDBOption = "leveldb"
+	databaseTypeMongoDBOption = "mongodb"
+
+	defaultLevelDBPath = "data"
+
+	adapterStorePrefix = "adapter_"
+
+	levelDBDataPrefix   = "d/"
+	levelDBConfigPrefix = "c/"
+)
+
+var errEmptyStoreKey = errors.New("key cannot be empty")
+
+// createStoreProvider creates storage provider of the configured database type. The same provider backs the aries
+// agent, its JSON-LD context store and the adapter's own stores.
+func createStoreProvider(cfg *adapterConfig) (storage.Provider, error) {
+	dbURL := cfg.DatabaseURL
+
+	switch dbType := cfg.DatabaseType; dbType {
+	case "", databaseTypeMemOption:
+		return mem.NewProvider(), nil
+	case databaseTypeLevelDBOption:
+		if dbURL == "" {
+			dbURL = defaultLevelDBPath
+		}
+
+		return newLevelDBProvider(dbURL)
+	case databaseTypeMongoDBOption:
+		if dbURL == "" {
+			return nil, fmt.Errorf("%s is required for database type '%s'", databaseURLFlagName, dbType)
+		}
+
+		return mongodb.NewProvider(dbURL, mongodb.WithDBPrefix(cfg.DatabasePrefix))
+	default:
+		return nil, fmt.Errorf("unsupported database type '%s', expected one of %s, %s, %s", dbType,
+			databaseTypeMemOption, databaseTypeLevelDBOption, databaseTypeMongoDBOption)
+	}
+}
+
+// prefixedStoreProvider opens stores of the wrapped provider under prefixed names, so that components which use
+// fixed store names, like the KMS, don't share stores with the aries agent.
+type prefixedStoreProvider struct {
+	storage.Provider
+	prefix string
+}
+
+func newPrefixedStoreProvider(prov storage.Provider, prefix string) *prefixedStoreProvider {
+	return &prefixedStoreProvider{Provider: prov, prefix: prefix}
+}
+
+func (p *prefixedStoreProvider) OpenStore(name string) (storage.Store, error) {
+	return p.Provider.OpenStore(p.prefix + name)
+}
+
+func (p *prefixedStoreProvider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	return p.Provider.SetStoreConfig(p.prefix+name, config)
+}
+
+func (p *prefixedStoreProvider) GetStoreConfig(name string) (storage.StoreConfiguration, error) {
+	return p.Provider.GetStoreConfig(p.prefix + name)
+}
+
+// levelDBProvider is an embedded on-disk storage provider. All stores share one LevelDB database, with
+// store name used as key prefix.
+type levelDBProvider struct {
+	db *leveldb.DB
+
+	mu     sync.RWMutex
+	stores map[string]*levelDBStore
+}
+
+type levelDBEntry struct {
+	Value []byte        `json:"value"`
+	Tags  []storage.Tag `json:"tags,omitempty"`
+}
+
+func newLevelDBProvider(path string) (*levelDBProvider, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if leveldberrors.IsCorrupted(err) {
+		db, err = leveldb.RecoverFile(path, nil)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leveldb at '%s' : %w", path, err)
+	}
+
+	return &levelDBProvider{db: db, stores: map[string]*levelDBStore{}}, nil
+}
+
+func (p *levelDBProvider) OpenStore(name string) (storage.Store, error) {
+	if name == "" {
+		return nil, fmt.Errorf("store name cannot be empty")
+	}
+
+	name = strings.ToLower(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if store, ok := p.stores[name]; ok {
+		return store, nil
+	}
+
+	store := &levelDBStore{name: name, db: p.db, prefix: levelDBDataPrefix + name + "/", close: p.removeStore}
+	p.stores[name] = store
+
+	return store, nil
+}
+
+func (p *levelDBProvider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	for _, tagName := range config.TagNames {
+		if strings.Contains(tagName, ":") {
+			return fmt.Errorf("invalid tag name '%s' : must not contain ':'", tagName)
+		}
+	}
+
+	name = strings.ToLower(name)
+
+	p.mu.RLock()
+	_, ok := p.stores[name]
+	p.mu.RUnlock()
+
+	if !ok {
+		return storage.ErrStoreNotFound
+	}
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal store config : %w", err)
+	}
+
+	return p.db.Put([]byte(levelDBConfigPrefix+name), configBytes, nil)
+}
+
+func (p *levelDBProvider) GetStoreConfig(name string) (storage.StoreConfiguration, error) {
+	name = strings.ToLower(name)
+
+	p.mu.RLock()
+	_, ok := p.stores[name]
+	p.mu.RUnlock()
+
+	if !ok {
+		return storage.StoreConfiguration{}, storage.ErrStoreNotFound
+	}
+
+	configBytes, err := p.db.Get([]byte(levelDBConfigPrefix+name), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return storage.StoreConfiguration{}, nil
+	}
+
+	if err != nil {
+		return storage.StoreConfiguration{}, fmt.Errorf("failed to get store config : %w", err)
+	}
+
+	var config storage.StoreConfiguration
+
+	return config, json.Unmarshal(configBytes, &config)
+}
+
+func (p *levelDBProvider) GetOpenStores() []storage.Store {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stores := make([]storage.Store, 0, len(p.stores))
+	for _, store := range p.stores {
+		stores = append(stores, store)
+	}
+
+	return stores
+}
+
+func (p *levelDBProvider) Close() error {
+	p.mu.Lock()
+	p.stores = map[string]*levelDBStore{}
+	p.mu.Unlock()
+
+	return p.db.Close()
+}
+
+func (p *levelDBProvider) removeStore(name string) {
+	p.mu.Lock()
+	delete(p.stores, name)
+	p.mu.Unlock()
+}
+
+type levelDBStore struct {
+	name   string
+	prefix string
+	db     *leveldb.DB
+	close  func(name string)
+}
+
+func (s *levelDBStore) Put(key string, value []byte, tags ...storage.Tag) error {
+	entry, err := newLevelDBEntry(key, value, tags)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Put([]byte(s.prefix+key), entry, nil)
+}
+
+func (s *levelDBStore) Get(key string) ([]byte, error) {
+	entry, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Value, nil
+}
+
+func (s *levelDBStore) GetTags(key string) ([]storage.Tag, error) {
+	entry, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.Tags, nil
+}
+
+func (s *levelDBStore) GetBulk(keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keys slice must contain at least one key")
+	}
+
+	values := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		entry, err := s.get(key)
+		if errors.Is(err, storage.ErrDataNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = entry.Value
+	}
+
+	return values, nil
+}
+
+// Query returns entries matching all "TagName" or "TagName:TagValue" expressions joined with "&&".
+func (s *levelDBStore) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	if expression == "" {
+		return nil, errors.New("invalid expression format. it must be in the following format: TagName:TagValue")
+	}
+
+	var filters []storage.Tag
+
+	for _, exp := range strings.Split(expression, "&&") {
+		parts := strings.Split(exp, ":")
+		if len(parts) > 2 { //nolint:gomnd
+			return nil, fmt.Errorf("invalid expression format '%s'", exp)
+		}
+
+		filter := storage.Tag{Name: parts[0]}
+		if len(parts) == 2 { //nolint:gomnd
+			filter.Value = parts[1]
+		}
+
+		filters = append(filters, filter)
+	}
+
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(s.prefix)), nil)
+	defer iter.Release()
+
+	result := &levelDBIterator{current: -1}
+
+	for iter.Next() {
+		var entry levelDBEntry
+
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal entry : %w", err)
+		}
+
+		if matchesAllTags(entry.Tags, filters) {
+			result.keys = append(result.keys, strings.TrimPrefix(string(iter.Key()), s.prefix))
+			result.entries = append(result.entries, &entry)
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("failed to query store : %w", err)
+	}
+
+	result.applyOptions(options)
+
+	return result, nil
+}
+
+func (s *levelDBStore) Delete(key string) error {
+	if key == "" {
+		return errEmptyStoreKey
+	}
+
+	return s.db.Delete([]byte(s.prefix+key), nil)
+}
+
+func (s *levelDBStore) Batch(operations []storage.Operation) error {
+	if len(operations) == 0 {
+		return errors.New("batch requires at least one operation")
+	}
+
+	batch := new(leveldb.Batch)
+
+	for _, op := range operations {
+		if op.Key == "" {
+			return errEmptyStoreKey
+		}
+
+		if op.Value == nil {
+			batch.Delete([]byte(s.prefix + op.Key))
+
+			continue
+		}
+
+		entry, err := newLevelDBEntry(op.Key, op.Value, op.Tags)
+		if err != nil {
+			return err
+		}
+
+		batch.Put([]byte(s.prefix+op.Key), entry)
+	}
+
+	return s.db.Write(batch, nil)
+}
+
+// Flush is a no-op, writes are not queued.
+func (s *levelDBStore) Flush() error {
+	return nil
+}
+
+// Close removes the store from open stores. Unlike the in-memory store, data is kept.
+func (s *levelDBStore) Close() error {
+	s.close(s.name)
+
+	return nil
+}
+
+func (s *levelDBStore) get(key string) (*levelDBEntry, error) {
+	if key == "" {
+		return nil, errEmptyStoreKey
+	}
+
+	entryBytes, err := s.db.Get([]byte(s.prefix+key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, storage.ErrDataNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get '%s' : %w", key, err)
+	}
+
+	var entry levelDBEntry
+
+	return &entry, json.Unmarshal(entryBytes, &entry)
+}
+
+func newLevelDBEntry(key string, value []byte, tags []storage.Tag) ([]byte, error) {
+	if key == "" {
+		return nil, errEmptyStoreKey
+	}
+
+	if value == nil {
+		return nil, errors.New("value cannot be nil")
+	}
+
+	for _, tag := range tags {
+		if strings.Contains(tag.Name, ":") || strings.Contains(tag.Value, ":") {
+			return nil, fmt.Errorf("invalid tag '%s:%s' : must not contain ':'", tag.Name, tag.Value)
+		}
+	}
+
+	return json.Marshal(&levelDBEntry{Value: value, Tags: tags})
+}
+
+func matchesAllTags(tags, filters []storage.Tag) bool {
+	for _, filter := range filters {
+		found := false
+
+		for _, tag := range tags {
+			if tag.Name == filter.Name && (filter.Value == "" || tag.Value == filter.Value) {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// levelDBIterator iterates over a snapshot of query results.
+type levelDBIterator struct {
+	keys       []string
+	entries    []*levelDBEntry
+	current    int
+	totalItems int
+}
+
+func (i *levelDBIterator) applyOptions(options []storage.QueryOption) {
+	var opts storage.QueryOptions
+
+	for _, option := range options {
+		option(&opts)
+	}
+
+	i.totalItems = len(i.keys)
+
+	if opts.SortOptions != nil {
+		order := make([]int, len(i.keys))
+		for idx := range order {
+			order[idx] = idx
+		}
+
+		tagValue := func(entry *levelDBEntry) string {
+			for _, tag := range entry.Tags {
+				if tag.Name == opts.SortOptions.TagName {
+					return tag.Value
+				}
+			}
+
+			return ""
+		}
+
+		sort.SliceStable(order, func(a, b int) bool {
+			va, vb := tagValue(i.entries[order[a]]), tagValue(i.entries[order[b]])
+			if opts.SortOptions.Order == storage.SortDescending {
+				va, vb = vb, va
+			}
+
+			return lessTagValue(va, vb)
+		})
+
+		keys, entries := make([]string, len(order)), make([]*levelDBEntry, len(order))
+		for idx, o := range order {
+			keys[idx], entries[idx] = i.keys[o], i.entries[o]
+		}
+
+		i.keys, i.entries = keys, entries
+	}
+
+	if skip := opts.InitialPageNum * opts.PageSize; skip > 0 {
+		if skip > len(i.keys) {
+			skip = len(i.keys)
+		}
+
+		i.keys, i.entries = i.keys[skip:], i.entries[skip:]
+	}
+}
+
+// lessTagValue compares tag values numerically when both are numbers, lexicographically otherwise.
+func lessTagValue(a, b string) bool {
+	na, errA := strconv.ParseFloat(a, 64)
+	nb, errB := strconv.ParseFloat(b, 64)
+
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+
+	return a < b
+}
+
+func (i *levelDBIterator) Next() (bool, error) {
+	if i.current+1 >= len(i.keys) {
+		return false, nil
+	}
+
+	i.current++
+
+	return true, nil
+}
+
+func (i *levelDBIterator) Key() (string, error) {
+	if i.current < 0 || i.current >= len(i.keys) {
+		return "", errors.New("iterator is exhausted")
+	}
+
+	return i.keys[i.current], nil
+}
+
+func (i *levelDBIterator) Value() ([]byte, error) {
+	if i.current < 0 || i.current >= len(i.keys) {
+		return nil, errors.New("iterator is exhausted")
+	}
+
+	return i.entries[i.current].Value, nil
+}
+
+func (i *levelDBIterator) Tags() ([]storage.Tag, error) {
+	if i.current < 0 || i.current >= len(i.keys) {
+		return nil, errors.New("iterator is exhausted")
+	}
+
+	return i.entries[i.current].Tags, nil
+}
+
+// TotalItems returns the number of entries matching the query, regardless of the initial page.
+func (i *levelDBIterator) TotalItems() (int, error) {
+	return i.totalItems, nil
+}
+
+func (i *levelDBIterator) Close() error {
+	return nil
+}
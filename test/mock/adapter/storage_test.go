@@ -0,0 +1,49 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	storagetest "github.com/hyperledger/aries-framework-go/test/component/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelDBProvider(t *testing.T) {
+	provider, err := newLevelDBProvider(t.TempDir())
+	require.NoError(t, err)
+
+	storagetest.TestAll(t, provider)
+}
+
+func TestPrefixedStoreProvider(t *testing.T) {
+	provider, err := newLevelDBProvider(t.TempDir())
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, provider.Close())
+	}()
+
+	agentStore, err := provider.OpenStore("kms")
+	require.NoError(t, err)
+
+	adapterStore, err := newPrefixedStoreProvider(provider, adapterStorePrefix).OpenStore("kms")
+	require.NoError(t, err)
+
+	require.NoError(t, adapterStore.Put("key", []byte("adapter")))
+
+	_, err = agentStore.Get("key")
+	require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+	prefixedStore, err := provider.OpenStore(adapterStorePrefix + "kms")
+	require.NoError(t, err)
+
+	value, err := prefixedStore.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("adapter"), value)
+}
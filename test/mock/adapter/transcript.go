@@ -0,0 +1,614 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	transcriptEntryHTTP    = "http"
+	transcriptEntryDIDComm = "didcomm"
+	transcriptEntryToken   = "token"
+	transcriptEntryFault   = "fault"
+
+	transcriptFormatJSON = "json"
+	transcriptFormatHAR  = "har"
+
+	maxTranscripts          = 200
+	maxTranscriptEntries    = 1000
+	maxTranscriptBodyLength = 64 * 1024
+
+	redactedValue = "[REDACTED]"
+	// secrets shorter than this, e.g. empty values, are not redacted to keep the rest of the transcript readable.
+	minRedactedSecretLength = 4
+)
+
+// secretParameters are names of query, form and JSON parameters which carry secrets.
+var secretParameters = map[string]bool{ //nolint:gochecknoglobals
+	"pin":                 true,
+	"user_pin":            true,
+	"code":                true,
+	"pre-authorized_code": true,
+	"access_token":        true,
+	"refresh_token":       true,
+	"id_token":            true,
+	"vp_token":            true,
+	"c_nonce":             true,
+	"client_secret":       true,
+}
+
+// transcriptEntry is an HTTP exchange, DIDComm message, token or injected fault recorded for a session.
+type transcriptEntry struct {
+	Kind    string         `json:"kind"`
+	Time    time.Time      `json:"time"`
+	HTTP    *httpExchange  `json:"http,omitempty"`
+	Message *loggedMessage `json:"message,omitempty"`
+	Token   *issuedToken   `json:"token,omitempty"`
+	Fault   *injectedFault `json:"fault,omitempty"`
+}
+
+// httpExchange is an HTTP request handled by the adapter, with its response.
+type httpExchange struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	Proto           string      `json:"proto"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+	Truncated       bool        `json:"truncated,omitempty"`
+	DurationMillis  int64       `json:"duration_ms"`
+}
+
+// issuedToken is a code, PIN or token the adapter issued in a session.
+type issuedToken struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// transcript is everything recorded for a WACI, OIDC or OpenID4VC session, oldest first.
+type transcript struct {
+	ID      string             `json:"id"`
+	Created time.Time          `json:"created"`
+	Entries []*transcriptEntry `json:"entries"`
+}
+
+// transcriptRecorder groups recorded entries by session. Sessions are identified by invitation ID, OIDC state or
+// issuer ID; thread IDs, codes and tokens used later in the session are linked to it as aliases. Entries recorded
+// under a key which is not linked yet are kept, and merged into the session once the key is linked.
+type transcriptRecorder struct {
+	mu          sync.Mutex
+	transcripts map[string]*transcript
+	aliases     map[string]string
+	// recordings, if set, saves every change of a transcript, and bodies are not truncated so that it can be replayed.
+	recordings    *recordingStore
+	maxBodyLength int
+}
+
+func newTranscriptRecorder() *transcriptRecorder {
+	return &transcriptRecorder{
+		transcripts:   map[string]*transcript{},
+		aliases:       map[string]string{},
+		maxBodyLength: maxTranscriptBodyLength,
+	}
+}
+
+// recordTo saves transcripts to the store as they are recorded, with whole bodies.
+func (t *transcriptRecorder) recordTo(recordings *recordingStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recordings = recordings
+	t.maxBodyLength = 0
+}
+
+// link makes entries recorded under alias part of the session.
+func (t *transcriptRecorder) link(alias, sessionID string) {
+	if alias == "" || sessionID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sessionID = t.resolve(sessionID)
+	if t.resolve(alias) == sessionID {
+		return
+	}
+
+	t.aliases[alias] = sessionID
+
+	for a, id := range t.aliases {
+		if id == alias {
+			t.aliases[a] = sessionID
+		}
+	}
+
+	pending, ok := t.transcripts[alias]
+	if !ok {
+		return
+	}
+
+	delete(t.transcripts, alias)
+
+	session := t.session(sessionID)
+	session.Entries = append(session.Entries, pending.Entries...)
+
+	sort.SliceStable(session.Entries, func(i, j int) bool {
+		return session.Entries[i].Time.Before(session.Entries[j].Time)
+	})
+
+	t.trim(session)
+
+	if t.recordings != nil {
+		t.recordings.remove(alias)
+		t.recordings.save(session)
+	}
+}
+
+func (t *transcriptRecorder) record(key string, entry *transcriptEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session := t.session(t.resolve(key))
+	session.Entries = append(session.Entries, entry)
+
+	t.trim(session)
+
+	if t.recordings != nil {
+		t.recordings.save(session)
+	}
+}
+
+// recordMessage records DIDComm message on its thread. Threads started on an invitation are linked to it.
+func (t *transcriptRecorder) recordMessage(msg *loggedMessage) {
+	key := msg.ThreadID
+	if key == "" {
+		key = msg.ID
+	}
+
+	if key == "" {
+		return
+	}
+
+	t.link(key, msg.ParentThreadID)
+	t.record(key, &transcriptEntry{Kind: transcriptEntryDIDComm, Time: msg.Time, Message: msg})
+}
+
+func (t *transcriptRecorder) recordToken(sessionID, tokenType, value string) {
+	t.record(sessionID, &transcriptEntry{
+		Kind:  transcriptEntryToken,
+		Time:  time.Now(),
+		Token: &issuedToken{Type: tokenType, Value: value},
+	})
+}
+
+// sameSession tells whether the keys belong to the same session.
+func (t *transcriptRecorder) sameSession(key, other string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.resolve(key) == t.resolve(other)
+}
+
+// known tells whether entries were recorded for the session or key.
+func (t *transcriptRecorder) known(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.transcripts[t.resolve(key)]
+
+	return ok
+}
+
+// get returns a copy of the session's transcript.
+func (t *transcriptRecorder) get(key string) (*transcript, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, ok := t.transcripts[t.resolve(key)]
+	if !ok {
+		return nil, false
+	}
+
+	return &transcript{
+		ID:      session.ID,
+		Created: session.Created,
+		Entries: append([]*transcriptEntry{}, session.Entries...),
+	}, true
+}
+
+func (t *transcriptRecorder) resolve(key string) string {
+	if id, ok := t.aliases[key]; ok {
+		return id
+	}
+
+	return key
+}
+
+func (t *transcriptRecorder) session(id string) *transcript {
+	session, ok := t.transcripts[id]
+	if ok {
+		return session
+	}
+
+	session = &transcript{ID: id, Created: time.Now()}
+	t.transcripts[id] = session
+
+	if len(t.transcripts) > maxTranscripts {
+		t.evictOldest()
+	}
+
+	return session
+}
+
+func (t *transcriptRecorder) trim(session *transcript) {
+	if len(session.Entries) > maxTranscriptEntries {
+		session.Entries = session.Entries[len(session.Entries)-maxTranscriptEntries:]
+	}
+}
+
+func (t *transcriptRecorder) evictOldest() {
+	oldestID := ""
+
+	for id, session := range t.transcripts {
+		if oldestID == "" || session.Created.Before(t.transcripts[oldestID].Created) {
+			oldestID = id
+		}
+	}
+
+	delete(t.transcripts, oldestID)
+
+	for alias, id := range t.aliases {
+		if id == oldestID {
+			delete(t.aliases, alias)
+		}
+	}
+}
+
+type transcriptSessionKey struct{}
+
+// transcriptSession is set in request context, for handlers to tell which session the request belongs to.
+type transcriptSession struct {
+	id string
+}
+
+// setTranscriptSession records the request and its response in the session's transcript.
+func setTranscriptSession(r *http.Request, sessionID string) {
+	if session, ok := r.Context().Value(transcriptSessionKey{}).(*transcriptSession); ok {
+		session.id = sessionID
+	}
+}
+
+// transcriptSessionID returns the session set by the request's handler, if any.
+func transcriptSessionID(r *http.Request) string {
+	if session, ok := r.Context().Value(transcriptSessionKey{}).(*transcriptSession); ok {
+		return session.id
+	}
+
+	return ""
+}
+
+// middleware records HTTP exchanges of sessions. Requests are matched to sessions by the handler, or by IDs,
+// states, codes and tokens they carry. Admin requests are not recorded.
+func (t *transcriptRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		start := time.Now()
+
+		var requestBody []byte
+
+		if r.Body != nil {
+			var err error
+
+			requestBody, err = io.ReadAll(r.Body)
+			if err != nil {
+				logger.Errorf("failed to read request body for transcript : %s", err)
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		session := &transcriptSession{}
+		t.mu.Lock()
+		maxBodyLength := t.maxBodyLength
+		t.mu.Unlock()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK, maxBodyLength: maxBodyLength}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), transcriptSessionKey{}, session)))
+
+		key := session.id
+		if key == "" {
+			key = t.requestSession(r, requestBody)
+		}
+
+		if key == "" {
+			return
+		}
+
+		exchange := &httpExchange{
+			Method:          r.Method,
+			URL:             r.URL.String(),
+			Proto:           r.Proto,
+			RequestHeaders:  r.Header.Clone(),
+			Status:          recorder.status,
+			ResponseHeaders: recorder.Header().Clone(),
+			ResponseBody:    recorder.body.String(),
+			Truncated:       recorder.truncated,
+			DurationMillis:  time.Since(start).Milliseconds(),
+		}
+
+		exchange.RequestBody, exchange.Truncated = truncateBody(requestBody, maxBodyLength, exchange.Truncated)
+
+		t.record(key, &transcriptEntry{Kind: transcriptEntryHTTP, Time: start, HTTP: exchange})
+	})
+}
+
+// requestSession returns session of the request by the first of its IDs which is known to belong to one.
+func (t *transcriptRecorder) requestSession(r *http.Request, requestBody []byte) string {
+	for _, candidate := range requestSessionKeys(r, requestBody) {
+		if t.known(candidate) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// requestSessionKeys returns IDs, states, codes and tokens the request carries, which may identify its session.
+func requestSessionKeys(r *http.Request, requestBody []byte) []string {
+	candidates := []string{mux.Vars(r)["id"]}
+
+	params := r.URL.Query()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(string(requestBody)); err == nil {
+			for k, v := range form {
+				params[k] = append(params[k], v...)
+			}
+		}
+	}
+
+	for _, name := range []string{"state", "pre-authorized_code", "code"} {
+		candidates = append(candidates, params[name]...)
+	}
+
+	if cookie, err := r.Cookie("state"); err == nil {
+		candidates = append(candidates, cookie.Value)
+	}
+
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		candidates = append(candidates, token)
+	}
+
+	keys := make([]string, 0, len(candidates))
+
+	for _, candidate := range candidates {
+		if candidate != "" {
+			keys = append(keys, candidate)
+		}
+	}
+
+	return keys
+}
+
+// responseRecorder captures status and the beginning of the body of the response, or all of it if maxBodyLength is
+// zero.
+type responseRecorder struct {
+	http.ResponseWriter
+	status        int
+	body          bytes.Buffer
+	maxBodyLength int
+	truncated     bool
+	wroteHeader   bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+
+	if room := r.maxBodyLength - r.body.Len(); r.maxBodyLength > 0 && room < len(b) {
+		r.body.Write(b[:room])
+		r.truncated = true
+	} else {
+		r.body.Write(b)
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+func truncateBody(body []byte, maxBodyLength int, truncated bool) (string, bool) {
+	if maxBodyLength > 0 && len(body) > maxBodyLength {
+		return string(body[:maxBodyLength]), true
+	}
+
+	return string(body), truncated
+}
+
+// sessionTranscript downloads transcript of the session as JSON or HAR, optionally with secrets redacted.
+func (v *adapterApp) sessionTranscript(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	session, ok := v.transcripts.get(id)
+	if !ok {
+		handleError(w, http.StatusNotFound, fmt.Sprintf("no transcript for session %s", id))
+
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transcriptFormatJSON
+	}
+
+	var doc interface{}
+
+	switch format {
+	case transcriptFormatJSON:
+		doc = session
+	case transcriptFormatHAR:
+		doc = newHAR(session)
+	default:
+		handleError(w, http.StatusBadRequest, fmt.Sprintf("unsupported transcript format %s", format))
+
+		return
+	}
+
+	docBytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, fmt.Sprintf("failed to marshal transcript : %s", err))
+
+		return
+	}
+
+	if redact, _ := strconv.ParseBool(r.URL.Query().Get("redact")); redact { //nolint:errcheck
+		docBytes = redactSecrets(docBytes, transcriptSecrets(session))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=transcript-%s.%s", session.ID, format))
+
+	if _, err = w.Write(docBytes); err != nil {
+		logger.Errorf("failed to write transcript : %s", err)
+	}
+}
+
+// transcriptSecrets collects secret values found in the transcript: issued tokens, credentials in headers and
+// values of secret parameters.
+func transcriptSecrets(session *transcript) []string {
+	var secrets []string
+
+	for _, entry := range session.Entries {
+		switch {
+		case entry.Token != nil:
+			secrets = append(secrets, entry.Token.Value)
+		case entry.HTTP != nil:
+			secrets = append(secrets, httpSecrets(entry.HTTP)...)
+		}
+	}
+
+	return secrets
+}
+
+func httpSecrets(exchange *httpExchange) []string {
+	var secrets []string
+
+	if auth := exchange.RequestHeaders.Get("Authorization"); auth != "" {
+		secrets = append(secrets, strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	for _, cookie := range (&http.Request{Header: exchange.RequestHeaders}).Cookies() {
+		secrets = append(secrets, cookie.Value)
+	}
+
+	for _, cookie := range (&http.Response{Header: exchange.ResponseHeaders}).Cookies() {
+		secrets = append(secrets, cookie.Value)
+	}
+
+	urls := []string{exchange.URL, exchange.ResponseHeaders.Get("Location")}
+	for _, u := range urls {
+		if parsed, err := url.Parse(u); err == nil {
+			secrets = append(secrets, paramSecrets(parsed.Query())...)
+		}
+	}
+
+	for _, body := range []string{exchange.RequestBody, exchange.ResponseBody} {
+		if form, err := url.ParseQuery(body); err == nil {
+			secrets = append(secrets, paramSecrets(form)...)
+		}
+
+		var doc interface{}
+		if json.Unmarshal([]byte(body), &doc) == nil {
+			secrets = append(secrets, jsonSecrets(doc)...)
+		}
+	}
+
+	return secrets
+}
+
+func paramSecrets(params url.Values) []string {
+	var secrets []string
+
+	for name, values := range params {
+		if secretParameters[name] {
+			secrets = append(secrets, values...)
+		}
+	}
+
+	return secrets
+}
+
+func jsonSecrets(doc interface{}) []string {
+	var secrets []string
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range d {
+			if s, ok := v.(string); ok && secretParameters[k] {
+				secrets = append(secrets, s)
+
+				continue
+			}
+
+			secrets = append(secrets, jsonSecrets(v)...)
+		}
+	case []interface{}:
+		for _, v := range d {
+			secrets = append(secrets, jsonSecrets(v)...)
+		}
+	}
+
+	return secrets
+}
+
+// redactSecrets replaces every occurrence of the secrets, longest first, as is and URL encoded.
+func redactSecrets(doc []byte, secrets []string) []byte {
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	for _, secret := range secrets {
+		if len(secret) < minRedactedSecretLength {
+			continue
+		}
+
+		doc = bytes.ReplaceAll(doc, []byte(secret), []byte(redactedValue))
+
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			doc = bytes.ReplaceAll(doc, []byte(escaped), []byte(redactedValue))
+		}
+	}
+
+	return doc
+}
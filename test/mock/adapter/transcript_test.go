@@ -0,0 +1,132 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscriptRecorder_Link(t *testing.T) {
+	recorder := newTranscriptRecorder()
+
+	// message attached to invitation is recorded before the invitation is created.
+	recorder.recordMessage(&loggedMessage{ID: "req-1", ThreadID: "thread-1", Time: time.Now()})
+	recorder.link("thread-1", "inv-1")
+
+	recorder.recordMessage(&loggedMessage{ID: "presentation-1", ThreadID: "thread-1", Time: time.Now()})
+	// thread started by the wallet on the invitation.
+	recorder.recordMessage(&loggedMessage{ID: "propose-1", ThreadID: "thread-2", ParentThreadID: "inv-1",
+		Time: time.Now()})
+
+	session, ok := recorder.get("thread-2")
+	require.True(t, ok)
+	require.Equal(t, "inv-1", session.ID)
+	require.Len(t, session.Entries, 3)
+	require.Equal(t, "req-1", session.Entries[0].Message.ID)
+	require.Equal(t, "propose-1", session.Entries[2].Message.ID)
+
+	_, ok = recorder.get("unknown")
+	require.False(t, ok)
+}
+
+func TestSessionTranscript(t *testing.T) {
+	app := &adapterApp{transcripts: newTranscriptRecorder()}
+
+	router := mux.NewRouter()
+	router.Use(app.transcripts.middleware)
+	router.HandleFunc("/issue", func(w http.ResponseWriter, r *http.Request) {
+		setTranscriptSession(r, "session-1")
+		app.transcripts.recordToken("session-1", "access_token", "secret-access-token")
+		app.transcripts.link("secret-access-token", "session-1")
+
+		writeJSON(w, map[string]string{"access_token": "secret-access-token"})
+	})
+	router.HandleFunc("/credential", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"credential": "vc"})
+	})
+	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{})
+	})
+	router.HandleFunc("/admin/sessions/{id}/transcript", app.sessionTranscript)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	serve(httptest.NewRequest(http.MethodPost, "/issue", nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/credential", nil)
+	req.Header.Set("Authorization", "Bearer secret-access-token")
+	serve(req)
+
+	// the wallet sends PIN in form, which is matched to the session by pre-authorized code.
+	app.transcripts.link("pre-auth-code", "session-1")
+
+	req = httptest.NewRequest(http.MethodPost, "/token",
+		strings.NewReader(url.Values{"pre-authorized_code": {"pre-auth-code"}, "user_pin": {"493536"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	serve(req)
+
+	// requests not belonging to any session are not recorded.
+	serve(httptest.NewRequest(http.MethodPost, "/credential", nil))
+
+	t.Run("json", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/admin/sessions/session-1/transcript", nil))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var session transcript
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &session))
+		require.Len(t, session.Entries, 4)
+		require.Equal(t, transcriptEntryToken, session.Entries[0].Kind)
+		require.Equal(t, "/issue", session.Entries[1].HTTP.URL)
+		require.Equal(t, "/credential", session.Entries[2].HTTP.URL)
+		require.Contains(t, session.Entries[2].HTTP.ResponseBody, "vc")
+		require.Contains(t, rr.Body.String(), "secret-access-token")
+	})
+
+	t.Run("har redacted", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/admin/sessions/session-1/transcript?format=har&redact=true",
+			nil))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NotContains(t, rr.Body.String(), "secret-access-token")
+		require.NotContains(t, rr.Body.String(), "493536")
+		require.NotContains(t, rr.Body.String(), "pre-auth-code")
+		require.Contains(t, rr.Body.String(), "Bearer "+redactedValue)
+
+		var doc har
+
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+		require.Equal(t, harVersion, doc.Log.Version)
+		require.Len(t, doc.Log.Entries, 3)
+		require.Len(t, doc.Log.Tokens, 1)
+		require.Equal(t, http.MethodPost, doc.Log.Entries[2].Request.Method)
+		require.NotNil(t, doc.Log.Entries[2].Request.PostData)
+	})
+
+	t.Run("unknown session", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/admin/sessions/unknown/transcript", nil))
+		require.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/admin/sessions/session-1/transcript?format=xml", nil))
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
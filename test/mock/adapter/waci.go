@@ -0,0 +1,454 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	issuecredentialclient "github.com/hyperledger/aries-framework-go/pkg/client/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/client/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	mediatorsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	presentproofsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/presexch"
+)
+
+const (
+	// WACI protocol steps, used to report where a DIDComm interaction failed.
+	stepDIDExchangeRequest  = "didexchange-request"
+	stepMediateRequest      = "mediate-request"
+	stepProposePresentation = "propose-presentation"
+	stepPresentation        = "presentation"
+	stepProposeCredential   = "propose-credential"
+	stepRequestCredential   = "request-credential"
+	stepUnsupported         = "unsupported"
+)
+
+// errPresentationRejected is returned when a received presentation fails verification.
+var errPresentationRejected = errors.New("presentation verification failed")
+
+// didCommFailure records why a DIDComm interaction was stopped.
+type didCommFailure struct {
+	Step        string    `json:"step"`
+	MessageType string    `json:"message_type"`
+	Reason      string    `json:"reason"`
+	Time        time.Time `json:"time"`
+}
+
+// didCommActionHandler handles one protocol step and returns the argument for action.Continue.
+type didCommActionHandler func(action service.DIDCommAction, thID string) (interface{}, error)
+
+func (v *adapterApp) listenForDIDCommMsg(actionCh chan service.DIDCommAction) {
+	for action := range actionCh {
+		logger.Infof("received action message : type=%s", action.Message.Type())
+		v.metrics.didCommAction(action.Message.Type())
+
+		v.handleDIDCommAction(action)
+	}
+}
+
+// handleDIDCommAction dispatches action to its protocol step handler, and either continues or stops the
+// action exactly once.
+func (v *adapterApp) handleDIDCommAction(action service.DIDCommAction) {
+	step, handler := v.didCommActionHandler(action.Message.Type())
+
+	thID, err := action.Message.ThreadID()
+	if err != nil {
+		v.stopDIDCommAction(action, step, "", fmt.Errorf("failed to get thread ID : %w", err))
+
+		return
+	}
+
+	if handler == nil {
+		v.stopDIDCommAction(action, step, thID,
+			fmt.Errorf("unsupported message type '%s'", action.Message.Type()))
+
+		return
+	}
+
+	args, err := handler(action, thID)
+	if err != nil {
+		v.stopDIDCommAction(action, step, thID, err)
+
+		return
+	}
+
+	action.Continue(args)
+}
+
+func (v *adapterApp) didCommActionHandler(msgType string) (string, didCommActionHandler) {
+	switch msgType {
+	case didexchange.RequestMsgType:
+		return stepDIDExchangeRequest, func(service.DIDCommAction, string) (interface{}, error) { return nil, nil }
+	case mediatorsvc.RequestMsgType:
+		return stepMediateRequest, v.handleMediateRequest
+	case presentproofsvc.ProposePresentationMsgTypeV2, presentproofsvc.ProposePresentationMsgTypeV3:
+		return stepProposePresentation, v.handleProposePresentation
+	case presentproofsvc.PresentationMsgTypeV2, presentproofsvc.PresentationMsgTypeV3:
+		return stepPresentation, v.handlePresentation
+	case issuecredential.ProposeCredentialMsgTypeV2, issuecredential.ProposeCredentialMsgTypeV3:
+		return stepProposeCredential, v.handleProposeCredential
+	case issuecredential.RequestCredentialMsgTypeV2, issuecredential.RequestCredentialMsgTypeV3:
+		return stepRequestCredential, v.handleRequestCredential
+	default:
+		return stepUnsupported, nil
+	}
+}
+
+// handleMediateRequest grants mediation, so that wallets without inbound endpoint can route messages through
+// the adapter and fetch them with message pickup.
+func (v *adapterApp) handleMediateRequest(action service.DIDCommAction, _ string) (interface{}, error) {
+	logger.Infof("granting mediation : message=%s", action.Message.ID())
+
+	return mediatorsvc.Options{}, nil
+}
+
+func (v *adapterApp) handleProposePresentation(action service.DIDCommAction, thID string) (interface{}, error) {
+	invitationID := v.invitationID(action)
+
+	pdBytes, err := v.store.Get(getPresentationDefinitionKeyPrefix(invitationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presentation definition for invitation '%s' : %w", invitationID, err)
+	}
+
+	reqPresentation, presReq, err := newWACIPresentationRequest(pdBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.saveWACIShareData(thID, pdBytes, v.readVerifierProfileID(invitationID), presReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return presentproof.WithRequestPresentation(reqPresentation), nil
+}
+
+// invitationID returns ID of the OOB invitation the interaction started from. DIDComm V2 messages carry it as
+// pthid, while over DIDComm V1 it's the invitation of the connection established by accepting it.
+func (v *adapterApp) invitationID(action service.DIDCommAction) string {
+	return v.messageInvitationID(action.Message, action.Properties)
+}
+
+// messageInvitationID returns ID of the OOB invitation the message belongs to, resolving it from the connection
+// identified by the properties when the message has no pthid.
+func (v *adapterApp) messageInvitationID(msg service.DIDCommMsg, properties interface{}) string {
+	if pthID := msg.ParentThreadID(); pthID != "" {
+		return pthID
+	}
+
+	props, ok := properties.(interface {
+		MyDID() string
+		TheirDID() string
+	})
+	if !ok || props.MyDID() == "" || props.TheirDID() == "" || v.agent.DIDExchClient == nil {
+		return ""
+	}
+
+	connections, err := v.agent.DIDExchClient.QueryConnections(&didexchange.QueryConnectionsParams{
+		MyDID:    props.MyDID(),
+		TheirDID: props.TheirDID(),
+	})
+	if err != nil || len(connections) == 0 {
+		logger.Errorf("failed to find connection of message %s : %v", msg.ID(), err)
+
+		return ""
+	}
+
+	if connections[0].ParentThreadID != "" {
+		return connections[0].ParentThreadID
+	}
+
+	return connections[0].InvitationID
+}
+
+// newWACIPresentationRequest creates request-presentation for the presentation definition with a new challenge.
+func newWACIPresentationRequest(pdBytes []byte) (*presentproof.RequestPresentation, *presentationRequest, error) {
+	pd := presexch.PresentationDefinition{}
+
+	err := json.Unmarshal(pdBytes, &pd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal presentation definition : %w", err)
+	}
+
+	presReq := &presentationRequest{Challenge: uuid.NewString(), Domain: uuid.NewString()}
+
+	return &presentproof.RequestPresentation{
+		Comment: "Request Presentation",
+		Attachments: []decorator.GenericAttachment{
+			{
+				ID:        uuid.NewString(),
+				MediaType: "application/json",
+				Data: decorator.AttachmentData{
+					JSON: struct {
+						Challenge string                           `json:"challenge"`
+						Domain    string                           `json:"domain"`
+						PD        *presexch.PresentationDefinition `json:"presentation_definition"`
+					}{
+						Challenge: presReq.Challenge,
+						Domain:    presReq.Domain,
+						PD:        &pd,
+					},
+				},
+			},
+		},
+		WillConfirm: true,
+	}, presReq, nil
+}
+
+// saveWACIShareData saves what is needed to verify presentation received on the thread.
+func (v *adapterApp) saveWACIShareData(thID string, pdBytes []byte, profileID string,
+	presReq *presentationRequest) error {
+	err := v.store.Put(thID, pdBytes)
+	if err != nil {
+		return fmt.Errorf("failed to save presentation definition : %w", err)
+	}
+
+	err = v.saveVerifierProfileID(thID, profileID)
+	if err != nil {
+		return fmt.Errorf("failed to save verifier profile : %w", err)
+	}
+
+	err = v.savePresentationRequest(thID, presReq)
+	if err != nil {
+		return fmt.Errorf("failed to save presentation request : %w", err)
+	}
+
+	return nil
+}
+
+func (v *adapterApp) handlePresentation(action service.DIDCommAction, thID string) (interface{}, error) {
+	result, err := v.verifyDIDCommPresentation(action.Message, thID)
+	if err != nil {
+		result = &verificationResult{Error: err.Error()}
+	}
+
+	err = v.saveVerificationResult(thID, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save verification result : %w", err)
+	}
+
+	v.metrics.presentationReceived(protocolWACI, result.Verified)
+
+	if !result.Verified {
+		return nil, errPresentationRejected
+	}
+
+	return presentproofsvc.WithProperties(
+		map[string]interface{}{
+			"~web-redirect": &decorator.WebRedirect{
+				Status: "OK",
+				URL:    v.cfg.ExternalURL + "/verifier/waci-share/" + thID,
+			},
+		},
+	), nil
+}
+
+func (v *adapterApp) handleProposeCredential(action service.DIDCommAction, thID string) (interface{}, error) {
+	err := v.store.Put(thID, []byte(thID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save interaction data : %w", err)
+	}
+
+	var msgData map[string]interface{}
+
+	err = action.Message.Decode(&msgData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode propose credential message : %w", err)
+	}
+
+	invitationID := v.invitationID(action)
+	if invID, ok := msgData["invitationID"].(string); ok {
+		invitationID = invID
+	}
+
+	waciData, err := readWACIIssuanceData(v.store, invitationID, thID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WACI issuance data : %w", err)
+	}
+
+	err = v.checkCredentialApplication(action.Message, thID, waciData, false)
+	if err != nil {
+		return nil, err
+	}
+
+	offerCredMsg, err := newWACICredentialOffer(waciData)
+	if err != nil {
+		return nil, err
+	}
+
+	v.metrics.offerCreated(protocolWACI)
+
+	return issuecredential.WithOfferCredential(offerCredMsg), nil
+}
+
+// newWACICredentialOffer creates offer-credential in the session's credential format, either with the credential
+// manifest and unsigned credential response, or with the unsigned credential detail.
+func newWACICredentialOffer(waciData *waciIssuanceData) (*issuecredential.OfferCredentialParams, error) {
+	if waciData.CredentialFormat == credentialFormatLDProof {
+		offerCredMsg, err := createLDProofOfferCredentialMsg(waciData.Credential)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare offer credential message : %w", err)
+		}
+
+		return offerCredMsg, nil
+	}
+
+	vp, err := createResponseVP(waciData.CredentialManifest, waciData.Credential, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare response : %w", err)
+	}
+
+	credResponseBytes, err := vp.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare response bytes : %w", err)
+	}
+
+	offerCredMsg, err := createOfferCredentialMsg(waciData.CredentialManifest, credResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare offer credential message : %w", err)
+	}
+
+	return offerCredMsg, nil
+}
+
+func (v *adapterApp) handleRequestCredential(action service.DIDCommAction, thID string) (interface{}, error) {
+	waciData, err := readWACIIssuanceData(v.store, thID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WACI issuance data : %w", err)
+	}
+
+	err = v.checkCredentialApplication(action.Message, thID, waciData, true)
+	if err != nil {
+		return nil, err
+	}
+
+	redirect := v.cfg.ExternalURL + "/issuer/waci-issuance/" + thID
+
+	if waciData.CredentialFormat == credentialFormatLDProof {
+		subjectID, e := ldProofRequestedSubjectID(action.Message)
+		if e != nil {
+			return nil, e
+		}
+
+		issueCredMsg, e := createLDProofIssueCredentialMsg(waciData.Credential, subjectID, redirect)
+		if e != nil {
+			return nil, fmt.Errorf("failed to prepare issue credential message : %w", e)
+		}
+
+		return issuecredential.WithIssueCredential(issueCredMsg), nil
+	}
+
+	vp, err := createResponseVP(waciData.CredentialManifest, waciData.Credential, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare response : %w", err)
+	}
+
+	credResponseBytes, err := vp.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare response bytes : %w", err)
+	}
+
+	issueCredMsg, err := createIssueCredentialMsg(credResponseBytes, redirect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare issue credential message : %w", err)
+	}
+
+	return issuecredential.WithIssueCredential(issueCredMsg), nil
+}
+
+// stopDIDCommAction records the failure against the session and stops the protocol. Present-proof and
+// issue-credential reply with a problem report carrying a "FAIL" web redirect to the session page, which
+// shows the recorded failure reason.
+func (v *adapterApp) stopDIDCommAction(action service.DIDCommAction, step, thID string, cause error) {
+	logger.Errorf("stopping DIDComm action : step=%s type=%s thread=%s reason=%s",
+		step, action.Message.Type(), thID, cause)
+
+	if thID != "" {
+		err := v.saveDIDCommFailure(thID, &didCommFailure{
+			Step:        step,
+			MessageType: action.Message.Type(),
+			Reason:      cause.Error(),
+			Time:        time.Now(),
+		})
+		if err != nil {
+			logger.Errorf("failed to save DIDComm failure : %s", err)
+		}
+	}
+
+	var piID string
+
+	if action.Properties != nil {
+		piID, _ = action.Properties.All()["piid"].(string) //nolint:errcheck
+	}
+
+	if piID == "" || thID == "" {
+		action.Stop(cause)
+
+		return
+	}
+
+	var err error
+
+	switch step {
+	case stepProposePresentation, stepPresentation:
+		err = v.agent.PresentProofClient.DeclinePresentation(piID, presentproof.DeclineReason(cause.Error()),
+			presentproof.DeclineRedirect(v.cfg.ExternalURL+"/verifier/waci-share/"+thID))
+	case stepProposeCredential:
+		err = v.agent.IssueCredentialClient.DeclineProposal(piID, cause.Error(),
+			issuecredentialclient.RequestRedirect(v.cfg.ExternalURL+"/issuer/waci-issuance/"+thID))
+	case stepRequestCredential:
+		err = v.agent.IssueCredentialClient.DeclineRequest(piID, cause.Error(),
+			issuecredentialclient.RequestRedirect(v.cfg.ExternalURL+"/issuer/waci-issuance/"+thID))
+	default:
+		action.Stop(cause)
+
+		return
+	}
+
+	if err != nil {
+		logger.Errorf("failed to decline DIDComm action, stopping without redirect : %s", err)
+		action.Stop(cause)
+	}
+}
+
+func (v *adapterApp) saveDIDCommFailure(thID string, failure *didCommFailure) error {
+	failureBytes, err := json.Marshal(failure)
+	if err != nil {
+		return err
+	}
+
+	return v.store.Put(getDIDCommFailureKeyPrefix(thID), failureBytes)
+}
+
+// readDIDCommFailure returns failure recorded for the session, or nil if the session did not fail.
+func (v *adapterApp) readDIDCommFailure(thID string) *didCommFailure {
+	failureBytes, err := v.store.Get(getDIDCommFailureKeyPrefix(thID))
+	if err != nil {
+		return nil
+	}
+
+	var failure didCommFailure
+
+	err = json.Unmarshal(failureBytes, &failure)
+	if err != nil {
+		return nil
+	}
+
+	return &failure
+}
+
+func getDIDCommFailureKeyPrefix(key string) string {
+	return fmt.Sprintf("didcomm_failure_%s", key)
+}
@@ -0,0 +1,307 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	issuecredentialclient "github.com/hyperledger/aries-framework-go/pkg/client/issuecredential"
+	"github.com/hyperledger/aries-framework-go/pkg/client/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	didexchangesvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/issuecredential"
+	presentproofsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/presentproof"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/defaults"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDIDCommAction_Failures(t *testing.T) {
+	app := newTestAdapterApp(t)
+
+	tests := []struct {
+		name   string
+		msg    service.DIDCommMsgMap
+		step   string
+		reason string
+	}{
+		{
+			name:   "unsupported message type",
+			msg:    service.DIDCommMsgMap{"@type": "https://didcomm.org/unknown/1.0/message"},
+			step:   stepUnsupported,
+			reason: "unsupported message type 'https://didcomm.org/unknown/1.0/message'",
+		},
+		{
+			name: "proposal for unknown invitation",
+			msg: service.DIDCommMsgMap{
+				"@type":   presentproofsvc.ProposePresentationMsgTypeV2,
+				"~thread": map[string]interface{}{"pthid": "unknown-invitation"},
+			},
+			step:   stepProposePresentation,
+			reason: "failed to get presentation definition for invitation 'unknown-invitation'",
+		},
+		{
+			name:   "presentation out of session",
+			msg:    service.DIDCommMsgMap{"@type": presentproofsvc.PresentationMsgTypeV2},
+			step:   stepPresentation,
+			reason: errPresentationRejected.Error(),
+		},
+		{
+			name: "credential proposal with invitation of another type",
+			msg: service.DIDCommMsgMap{
+				"@type":        issuecredential.ProposeCredentialMsgTypeV2,
+				"invitationID": 42,
+			},
+			step:   stepProposeCredential,
+			reason: "failed to get WACI issuance data",
+		},
+		{
+			name:   "credential request out of session",
+			msg:    service.DIDCommMsgMap{"@type": issuecredential.RequestCredentialMsgTypeV2},
+			step:   stepRequestCredential,
+			reason: "failed to get WACI issuance data",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thID := uuid.NewString()
+			test.msg["@id"] = thID
+
+			cause := handleStoppedAction(t, app, service.DIDCommAction{Message: test.msg})
+			require.Contains(t, cause.Error(), test.reason)
+
+			failure := app.readDIDCommFailure(thID)
+			require.NotNil(t, failure)
+			require.Equal(t, test.step, failure.Step)
+			require.Equal(t, test.msg.Type(), failure.MessageType)
+			require.Equal(t, cause.Error(), failure.Reason)
+		})
+	}
+
+	t.Run("message without thread", func(t *testing.T) {
+		cause := handleStoppedAction(t, app, service.DIDCommAction{
+			Message: service.DIDCommMsgMap{"@type": presentproofsvc.ProposePresentationMsgTypeV2},
+		})
+		require.Contains(t, cause.Error(), "failed to get thread ID")
+	})
+
+	t.Run("decline of unknown protocol instance falls back to stop", func(t *testing.T) {
+		thID := uuid.NewString()
+
+		cause := handleStoppedAction(t, app, service.DIDCommAction{
+			Message: service.DIDCommMsgMap{
+				"@id":     thID,
+				"@type":   presentproofsvc.ProposePresentationMsgTypeV2,
+				"~thread": map[string]interface{}{"pthid": "unknown-invitation"},
+			},
+			Properties: actionProperties{"piid": uuid.NewString()},
+		})
+		require.Contains(t, cause.Error(), "failed to get presentation definition")
+		require.Equal(t, stepProposePresentation, app.readDIDCommFailure(thID).Step)
+	})
+}
+
+func TestStopDIDCommAction_Decline(t *testing.T) {
+	app, ctx := newTestAdapterAppWithContext(t)
+
+	issueCredentialClient, err := issuecredentialclient.New(ctx)
+	require.NoError(t, err)
+
+	app.agent.IssueCredentialClient = issueCredentialClient
+
+	sent := &capturingMessenger{sent: make(chan service.DIDCommMsgMap, 1)}
+	app.agent.Messenger.setMessenger(sent)
+
+	actionCh := make(chan service.DIDCommAction)
+	defer close(actionCh)
+
+	require.NoError(t, app.agent.PresentProofClient.RegisterActionEvent(actionCh))
+	require.NoError(t, app.agent.IssueCredentialClient.RegisterActionEvent(actionCh))
+
+	go app.listenForDIDCommMsg(actionCh)
+
+	receive := func(t *testing.T, serviceName string, msg service.DIDCommMsgMap) service.DIDCommMsgMap {
+		t.Helper()
+
+		svc, err := ctx.Service(serviceName)
+		require.NoError(t, err)
+
+		handler, ok := svc.(service.InboundHandler)
+		require.True(t, ok)
+
+		_, err = handler.HandleInbound(msg,
+			service.NewDIDCommContext("did:example:adapter", "did:example:wallet", nil))
+		require.NoError(t, err)
+
+		select {
+		case reply := <-sent.sent:
+			return reply
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "problem report was not sent")
+
+			return nil
+		}
+	}
+
+	requireFailRedirect := func(t *testing.T, reply service.DIDCommMsgMap, step, thID, redirect string) {
+		t.Helper()
+
+		require.Contains(t, reply.Type(), "problem-report")
+
+		var problemReport struct {
+			WebRedirect *decorator.WebRedirect `json:"~web-redirect"`
+		}
+
+		require.NoError(t, reply.Decode(&problemReport))
+		require.NotNil(t, problemReport.WebRedirect, "problem report without web redirect")
+		require.Equal(t, "FAIL", problemReport.WebRedirect.Status)
+		require.Equal(t, app.cfg.ExternalURL+redirect+thID, problemReport.WebRedirect.URL)
+
+		failure := app.readDIDCommFailure(thID)
+		require.NotNil(t, failure)
+		require.Equal(t, step, failure.Step)
+	}
+
+	t.Run("presentation proposal", func(t *testing.T) {
+		thID := uuid.NewString()
+
+		reply := receive(t, presentproofsvc.Name, service.DIDCommMsgMap{
+			"@id":     thID,
+			"@type":   presentproofsvc.ProposePresentationMsgTypeV2,
+			"~thread": map[string]interface{}{"pthid": "unknown-invitation"},
+		})
+
+		requireFailRedirect(t, reply, stepProposePresentation, thID, "/verifier/waci-share/")
+	})
+
+	t.Run("credential proposal", func(t *testing.T) {
+		thID := uuid.NewString()
+
+		reply := receive(t, issuecredential.Name, service.DIDCommMsgMap{
+			"@id":     thID,
+			"@type":   issuecredential.ProposeCredentialMsgTypeV2,
+			"~thread": map[string]interface{}{"pthid": "unknown-invitation"},
+		})
+
+		requireFailRedirect(t, reply, stepProposeCredential, thID, "/issuer/waci-issuance/")
+	})
+}
+
+func TestMediation(t *testing.T) {
+	adapter := startHolderTestAdapter(t)
+
+	resp, err := adapter.http.Get(adapter.url + "/mediator/invitation") //nolint:noctx
+	require.NoError(t, err)
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var invitation outofband.Invitation
+
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&invitation))
+
+	// wallet connecting to the adapter and requesting it to mediate, as wallets without public endpoint do.
+	walletAddr := freeTestAddr(t)
+
+	framework, err := aries.New(aries.WithStoreProvider(mem.NewProvider()),
+		aries.WithProtocolStateStoreProvider(mem.NewProvider()),
+		defaults.WithInboundHTTPAddr(walletAddr, "http://"+walletAddr, "", ""))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, framework.Close()) })
+
+	ctx, err := framework.Context()
+	require.NoError(t, err)
+
+	didExchangeClient, err := didexchange.New(ctx)
+	require.NoError(t, err)
+
+	actions := make(chan service.DIDCommAction)
+	require.NoError(t, didExchangeClient.RegisterActionEvent(actions))
+
+	go service.AutoExecuteActionEvent(actions)
+
+	oobClient, err := outofband.New(ctx)
+	require.NoError(t, err)
+
+	connID, err := oobClient.AcceptInvitation(&invitation, "wallet")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		conn, e := didExchangeClient.GetConnection(connID)
+
+		return e == nil && conn.State == didexchangesvc.StateIDCompleted
+	}, 10*time.Second, 50*time.Millisecond, "connection with the adapter was not completed")
+
+	mediatorClient, err := mediator.New(ctx)
+	require.NoError(t, err)
+
+	// Register sends mediate-request and returns once the adapter's mediate-grant is received.
+	require.NoError(t, mediatorClient.Register(connID))
+
+	config, err := mediatorClient.GetConfig(connID)
+	require.NoError(t, err)
+	require.Equal(t, adapter.didCommURL, config.Endpoint())
+	require.NotEmpty(t, config.Keys())
+}
+
+// handleStoppedAction handles the action, which is expected to be stopped, and returns the cause it was stopped
+// with.
+func handleStoppedAction(t *testing.T, app *adapterApp, action service.DIDCommAction) error {
+	t.Helper()
+
+	var cause error
+
+	action.Continue = func(interface{}) { t.Error("action was continued") }
+	action.Stop = func(err error) { cause = err }
+
+	app.handleDIDCommAction(action)
+
+	require.Error(t, cause)
+
+	return cause
+}
+
+type actionProperties map[string]interface{}
+
+func (p actionProperties) All() map[string]interface{} {
+	return p
+}
+
+// capturingMessenger captures messages sent by protocol services.
+type capturingMessenger struct {
+	service.MessengerHandler
+	sent chan service.DIDCommMsgMap
+}
+
+func (m *capturingMessenger) capture(msg service.DIDCommMsgMap) error {
+	m.sent <- msg
+
+	return nil
+}
+
+func (m *capturingMessenger) Send(msg service.DIDCommMsgMap, _, _ string, _ ...service.Opt) error {
+	return m.capture(msg)
+}
+
+func (m *capturingMessenger) ReplyToMsg(_, msg service.DIDCommMsgMap, _, _ string, _ ...service.Opt) error {
+	return m.capture(msg)
+}
+
+func (m *capturingMessenger) ReplyToNested(msg service.DIDCommMsgMap, _ *service.NestedReplyOpts) error {
+	return m.capture(msg)
+}
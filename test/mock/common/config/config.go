@@ -0,0 +1,354 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package config resolves settings of the mock servers from command line flags, environment variables and an
+// optional JSON config file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// FileFlagName is the flag of the JSON config file.
+	FileFlagName = "config-file"
+	// FileEnvKey is the environment variable of the JSON config file.
+	FileEnvKey = "CONFIG_FILE"
+
+	// Sources of settings.
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+
+	envVarUsage = " Alternatively, this can be set with the following environment variable: "
+)
+
+// Option is a setting given as command line flag, environment variable or config file key, in this order of
+// precedence. Config file keys are the flag names.
+type Option struct {
+	Name      string
+	Env       string
+	LegacyEnv string
+	Usage     string
+	Default   string
+	Required  bool
+	Set       func(value string) error
+}
+
+// Setting is the effective value of an option and where it came from.
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+	// Legacy is set when the value came from the option's legacy environment variable.
+	Legacy bool
+}
+
+// AddFlags adds the config file flag and a flag per option to the command.
+func AddFlags(cmd *cobra.Command, options []*Option) {
+	cmd.Flags().String(FileFlagName, "", "JSON file with settings keyed by flag name."+envVarUsage+FileEnvKey)
+
+	for _, option := range options {
+		cmd.Flags().String(option.Name, option.Default, option.Usage+envVarUsage+option.Env)
+	}
+}
+
+// Load resolves and sets all options. It returns the effective settings along with every missing or invalid
+// value, so that callers can add checks across options and report all problems at once with Invalid.
+func Load(cmd *cobra.Command, options []*Option) ([]*Setting, []string, error) {
+	fileValues, err := readFile(cmd, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		settings []*Setting
+		errs     []string
+	)
+
+	for _, option := range options {
+		setting, e := resolve(cmd, option, fileValues)
+		if e != nil {
+			errs = append(errs, e.Error())
+
+			continue
+		}
+
+		if setting == nil {
+			continue
+		}
+
+		if e = option.Set(setting.Value); e != nil {
+			errs = append(errs, fmt.Sprintf("invalid value '%s' for %s : %s", RedactValue(setting.Value),
+				option.Name, e))
+
+			continue
+		}
+
+		settings = append(settings, setting)
+	}
+
+	return settings, errs, nil
+}
+
+// Invalid returns error listing the problems of the configuration, or nil if there are none.
+func Invalid(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+}
+
+// Redacted returns the settings with passwords in URLs redacted, one setting per line.
+func Redacted(settings []*Setting) string {
+	lines := make([]string, 0, len(settings))
+
+	for _, s := range settings {
+		lines = append(lines, fmt.Sprintf("  %s=%s (%s)", s.Name, RedactValue(s.Value), s.Source))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// RedactValue redacts password of the value if it's a URL.
+func RedactValue(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+
+	return u.Redacted()
+}
+
+// resolve returns the value of the option with the highest precedence, or nil if the option is not set.
+func resolve(cmd *cobra.Command, option *Option, fileValues map[string]string) (*Setting, error) {
+	if cmd.Flags().Changed(option.Name) {
+		value, err := cmd.Flags().GetString(option.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s flag : %w", option.Name, err)
+		}
+
+		return &Setting{Name: option.Name, Value: value, Source: SourceFlag}, nil
+	}
+
+	if value := os.Getenv(option.Env); value != "" {
+		return &Setting{Name: option.Name, Value: value, Source: SourceEnv + " " + option.Env}, nil
+	}
+
+	if value := os.Getenv(option.LegacyEnv); option.LegacyEnv != "" && value != "" {
+		return &Setting{Name: option.Name, Value: value, Source: SourceEnv + " " + option.LegacyEnv, Legacy: true}, nil
+	}
+
+	if value, ok := fileValues[option.Name]; ok {
+		return &Setting{Name: option.Name, Value: value, Source: SourceFile}, nil
+	}
+
+	if option.Default != "" {
+		return &Setting{Name: option.Name, Value: option.Default, Source: SourceDefault}, nil
+	}
+
+	if option.Required {
+		return nil, fmt.Errorf("%s is required, set --%s flag, %s environment variable or '%s' in config file",
+			option.Name, option.Name, option.Env, option.Name)
+	}
+
+	return nil, nil //nolint:nilnil // option is not set
+}
+
+// readFile reads JSON object of settings keyed by flag name. Arrays are joined with commas.
+func readFile(cmd *cobra.Command, options []*Option) (map[string]string, error) {
+	path, err := cmd.Flags().GetString(FileFlagName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s flag : %w", FileFlagName, err)
+	}
+
+	if path == "" {
+		path = os.Getenv(FileEnvKey)
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	fileBytes, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file : %w", err)
+	}
+
+	var raw map[string]interface{}
+
+	err = json.Unmarshal(fileBytes, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s : %w", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, option := range options {
+		known[option.Name] = true
+	}
+
+	values := map[string]string{}
+
+	for key, val := range raw {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown setting '%s' in config file %s", key, path)
+		}
+
+		switch v := val.(type) {
+		case string:
+			values[key] = v
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+
+			values[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return values, nil
+}
+
+// String sets the value as is.
+func String(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+
+		return nil
+	}
+}
+
+// List sets comma-separated values, ignoring empty ones.
+func List(target *[]string) func(string) error {
+	return func(value string) error {
+		*target = nil
+
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+
+		return nil
+	}
+}
+
+// Bool sets true or false.
+func Bool(target *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("expected true or false")
+		}
+
+		*target = b
+
+		return nil
+	}
+}
+
+// Port sets port number.
+func Port(target *string) func(string) error {
+	return func(value string) error {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return errors.New("expected port number")
+		}
+
+		*target = value
+
+		return nil
+	}
+}
+
+// HostPort sets address to listen on, with optional host.
+func HostPort(target *string) func(string) error {
+	return func(value string) error {
+		_, port, err := net.SplitHostPort(value)
+		if err != nil {
+			return err
+		}
+
+		if err = Port(new(string))(port); err != nil {
+			return err
+		}
+
+		*target = value
+
+		return nil
+	}
+}
+
+// URL sets absolute URL with one of the schemes, trailing slash is removed so that paths can be appended.
+func URL(target *string, schemes ...string) func(string) error {
+	return func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+
+		if u.Host == "" || !contains(schemes, u.Scheme) {
+			return fmt.Errorf("expected absolute URL with scheme %s", strings.Join(schemes, " or "))
+		}
+
+		*target = strings.TrimSuffix(value, "/")
+
+		return nil
+	}
+}
+
+// Duration sets duration, e.g. 30s or 5m.
+func Duration(target *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		*target = d
+
+		return nil
+	}
+}
+
+// OneOf sets one of the allowed values.
+func OneOf(target *string, allowed ...string) func(string) error {
+	return func(value string) error {
+		if !contains(allowed, value) {
+			return fmt.Errorf("expected one of %s", strings.Join(allowed, ", "))
+		}
+
+		*target = value
+
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
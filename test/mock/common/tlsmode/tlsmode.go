@@ -0,0 +1,316 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package tlsmode serves the mock servers over plain HTTP, TLS from certificate files or TLS from a certificate
+// generated by a local CA.
+package tlsmode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TLS modes.
+const (
+	Plain = "plain"
+	File  = "file"
+	Auto  = "auto"
+)
+
+const (
+	// DefaultAutoDir is the default directory of the local CA and certificate generated in auto mode.
+	DefaultAutoDir = "tls"
+	// CACertFile is the file name of the local CA certificate clients need to trust in auto mode.
+	CACertFile = "ca.pem"
+
+	caKeyFile      = "ca-key.pem"
+	leafCertFile   = "cert.pem"
+	leafKeyFile    = "key.pem"
+	caValidity     = 10 * 365 * 24 * time.Hour
+	certValidity   = 365 * 24 * time.Hour
+	reloadInterval = 10 * time.Second
+)
+
+// Logger logs certificate reloads.
+type Logger interface {
+	Infof(msg string, args ...interface{})
+	Errorf(msg string, args ...interface{})
+}
+
+// ListenAndServe serves HTTP in the given TLS mode. In file and auto modes, the certificate of the server is
+// reloaded when its files change, so that renewed certificates are picked up without restart. Servers which load
+// the certificate files themselves, like the adapter's DIDComm inbound transports, need a restart instead.
+func ListenAndServe(srv *http.Server, mode, certFile, keyFile string, logger Logger) error {
+	if mode == Plain {
+		return srv.ListenAndServe()
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile, logger)
+	if err != nil {
+		return err
+	}
+
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: reloader.getCertificate}
+
+	return srv.ListenAndServeTLS("", "")
+}
+
+// certReloader serves the certificate from files, checking for changes at most once per reload interval.
+type certReloader struct {
+	certFile       string
+	keyFile        string
+	reloadInterval time.Duration
+	logger         Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger Logger) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, reloadInterval: reloadInterval, logger: logger}
+
+	err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < r.reloadInterval {
+		return r.cert, nil
+	}
+
+	r.checked = time.Now()
+
+	modTime, err := r.filesModTime()
+	if err != nil {
+		r.logger.Errorf("failed to check TLS certificate files, keeping loaded certificate : %s", err)
+
+		return r.cert, nil
+	}
+
+	if modTime.After(r.modTime) {
+		err = r.reload()
+		if err != nil {
+			r.logger.Errorf("failed to reload TLS certificate, keeping loaded certificate : %s", err)
+		} else {
+			r.logger.Infof("reloaded TLS certificate from %s", r.certFile)
+		}
+	}
+
+	return r.cert, nil
+}
+
+func (r *certReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate : %w", err)
+	}
+
+	r.cert, r.modTime = &cert, modTime
+
+	return nil
+}
+
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s : %w", file, err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// GenerateAuto issues a certificate for the hostnames from a local CA kept in dir, creating the CA with the given
+// name on first use. Clients need to trust the CA written to dir/ca.pem.
+func GenerateAuto(dir, caName string, hostnames []string) (string, string, error) {
+	if len(hostnames) == 0 {
+		return "", "", errors.New("hostnames are required to generate TLS certificate")
+	}
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create TLS directory : %w", err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir, caName)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate TLS key : %w", err)
+	}
+
+	template, err := certificateTemplate(hostnames[0], certValidity)
+	if err != nil {
+		return "", "", err
+	}
+
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	for _, host := range hostnames {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create TLS certificate : %w", err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, leafCertFile), filepath.Join(dir, leafKeyFile)
+
+	err = writeKeyPEM(keyPath, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = writeCertPEM(certPath, certDER)
+	if err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+func loadOrCreateCA(dir, name string) (*x509.Certificate, crypto.Signer, error) {
+	caCertPath, caKeyPath := filepath.Join(dir, CACertFile), filepath.Join(dir, caKeyFile)
+
+	pair, err := tls.LoadX509KeyPair(caCertPath, caKeyPath)
+	if err == nil {
+		caCert, e := x509.ParseCertificate(pair.Certificate[0])
+		if e != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate : %w", e)
+		}
+
+		caKey, ok := pair.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, nil, errors.New("unsupported CA key")
+		}
+
+		return caCert, caKey, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to load CA : %w", err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key : %w", err)
+	}
+
+	template, err := certificateTemplate(name, caValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	caDER, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate : %w", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate : %w", err)
+	}
+
+	err = writeKeyPEM(caKeyPath, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writeCertPEM(caCertPath, caDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return caCert, caKey, nil
+}
+
+func certificateTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128)) //nolint:gomnd
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number : %w", err)
+	}
+
+	now := time.Now()
+
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+func writeCertPEM(file string, der []byte) error {
+	err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to write %s : %w", file, err)
+	}
+
+	return nil
+}
+
+func writeKeyPEM(file string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key : %w", err)
+	}
+
+	err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write %s : %w", file, err)
+	}
+
+	return nil
+}
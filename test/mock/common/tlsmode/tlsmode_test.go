@@ -0,0 +1,166 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlsmode
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCAName = "mock server test CA"
+
+func TestGenerateAuto(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+
+	certFile, keyFile, err := GenerateAuto(dir, testCAName, []string{"adapter.example.com", "localhost", "127.0.0.1"})
+	require.NoError(t, err)
+
+	caBytes, err := os.ReadFile(filepath.Join(dir, CACertFile))
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caBytes))
+
+	reloader, err := newCertReloader(certFile, keyFile, testLogger{t})
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(reloader.cert.Certificate[0])
+	require.NoError(t, err)
+
+	for _, host := range []string{"adapter.example.com", "localhost", "127.0.0.1"} {
+		_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		require.NoError(t, err, host)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "other.example.com", Roots: roots})
+	require.Error(t, err)
+
+	// CA is kept across restarts, so that clients trusting it keep working.
+	_, _, err = GenerateAuto(dir, testCAName, []string{"adapter.example.com"})
+	require.NoError(t, err)
+
+	reloadedCA, err := os.ReadFile(filepath.Join(dir, CACertFile))
+	require.NoError(t, err)
+	require.Equal(t, caBytes, reloadedCA)
+
+	_, _, err = GenerateAuto(dir, testCAName, nil)
+	require.Error(t, err)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+
+	certFile, keyFile, err := GenerateAuto(dir, testCAName, []string{"localhost"})
+	require.NoError(t, err)
+
+	reloader, err := newCertReloader(certFile, keyFile, testLogger{t})
+	require.NoError(t, err)
+
+	reloader.reloadInterval = 0
+
+	initial, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+
+	// unchanged files are not reloaded.
+	same, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Same(t, initial, same)
+
+	_, _, err = GenerateAuto(dir, testCAName, []string{"localhost"})
+	require.NoError(t, err)
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	renewed, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, initial.Certificate[0], renewed.Certificate[0])
+
+	// broken files keep the loaded certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	kept, err := reloader.getCertificate(nil)
+	require.NoError(t, err)
+	require.Same(t, renewed, kept)
+
+	_, err = newCertReloader(certFile, keyFile, testLogger{t})
+	require.Error(t, err)
+}
+
+func TestListenAndServe_Auto(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tls")
+
+	certFile, keyFile, err := GenerateAuto(dir, testCAName, []string{"localhost", "127.0.0.1"})
+	require.NoError(t, err)
+
+	caBytes, err := os.ReadFile(filepath.Join(dir, CACertFile))
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caBytes))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})}
+
+	go func() {
+		_ = ListenAndServe(srv, Auto, certFile, keyFile, testLogger{t}) //nolint:errcheck
+	}()
+
+	t.Cleanup(func() { require.NoError(t, srv.Close()) })
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+	}}
+
+	require.Eventually(t, func() bool {
+		resp, e := client.Get("https://" + addr) //nolint:noctx
+		if e != nil {
+			return false
+		}
+
+		defer resp.Body.Close() //nolint:errcheck
+
+		body, e := io.ReadAll(resp.Body)
+
+		return e == nil && string(body) == "ok"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	err = ListenAndServe(&http.Server{}, File, filepath.Join(dir, "missing.pem"), keyFile, testLogger{t})
+	require.Error(t, err)
+}
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Infof(msg string, args ...interface{}) {
+	l.t.Logf(msg, args...)
+}
+
+func (l testLogger) Errorf(msg string, args ...interface{}) {
+	l.t.Logf(msg, args...)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/trustbloc/wallet/test/mock/common/config"
+	"github.com/trustbloc/wallet/test/mock/common/tlsmode"
+)
+
+// consentConfig is the configuration of the consent server, validated at startup.
+type consentConfig struct {
+	AdminURL          string
+	ServePort         string
+	TLSMode           string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSHostnames      []string
+	TLSAutoDir        string
+	TLSSystemCertPool bool
+	TLSCACerts        []string
+
+	settings []*config.Setting
+}
+
+func (c *consentConfig) options() []*config.Option {
+	return []*config.Option{
+		{
+			Name: adminURLFlagName, Env: adminURLEnvKey, Required: true,
+			Usage: "URL of hydra admin API.",
+			Set:   config.URL(&c.AdminURL, "http", "https"),
+		},
+		{
+			Name: servePortFlagName, Env: servePortEnvKey, Required: true,
+			Usage: "Port to serve login and consent pages on.",
+			Set:   config.Port(&c.ServePort),
+		},
+		{
+			Name: tlsModeFlagName, Env: tlsModeEnvKey, Default: tlsmode.Plain,
+			Usage: fmt.Sprintf("TLS mode of the login and consent pages: %s serves plain HTTP, %s uses the "+
+				"certificate files and %s generates certificate from a local CA.", tlsmode.Plain, tlsmode.File,
+				tlsmode.Auto),
+			Set: config.OneOf(&c.TLSMode, tlsmode.Plain, tlsmode.File, tlsmode.Auto),
+		},
+		{
+			Name: tlsCertFileFlagName, Env: tlsCertFileEnvKey,
+			Usage: "TLS certificate file of the login and consent pages, required in file TLS mode.",
+			Set:   config.String(&c.TLSCertFile),
+		},
+		{
+			Name: tlsKeyFileFlagName, Env: tlsKeyFileEnvKey,
+			Usage: "TLS private key file of the login and consent pages, required in file TLS mode.",
+			Set:   config.String(&c.TLSKeyFile),
+		},
+		{
+			Name: tlsHostnamesFlagName, Env: tlsHostnamesEnvKey, Default: "localhost,127.0.0.1",
+			Usage: "Comma-separated list of hostnames and IPs of the certificate generated in auto TLS mode.",
+			Set:   config.List(&c.TLSHostnames),
+		},
+		{
+			Name: tlsAutoDirFlagName, Env: tlsAutoDirEnvKey, Default: tlsmode.DefaultAutoDir,
+			Usage: "Directory of the local CA and certificate generated in auto TLS mode.",
+			Set:   config.String(&c.TLSAutoDir),
+		},
+		{
+			Name: tlsSystemCertPoolFlagName, Env: tlsSystemCertPoolEnvKey, Default: "false",
+			Usage: "Whether to trust system CA certificates.",
+			Set:   config.Bool(&c.TLSSystemCertPool),
+		},
+		{
+			Name: tlsCACertsFlagName, Env: tlsCACertsEnvKey,
+			Usage: "Comma-separated list of CA certificate files.",
+			Set:   config.List(&c.TLSCACerts),
+		},
+	}
+}
+
+func (c *consentConfig) addFlags(cmd *cobra.Command) {
+	config.AddFlags(cmd, c.options())
+}
+
+// load resolves and validates all options, reporting every invalid or missing value at once.
+func (c *consentConfig) load(cmd *cobra.Command) error {
+	settings, errs, err := config.Load(cmd, c.options())
+	if err != nil {
+		return err
+	}
+
+	c.settings = settings
+
+	if c.TLSMode == tlsmode.File && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Sprintf("%s and %s are required for %s '%s'", tlsCertFileFlagName,
+			tlsKeyFileFlagName, tlsModeFlagName, tlsmode.File))
+	}
+
+	if c.TLSMode != tlsmode.File && (c.TLSCertFile != "" || c.TLSKeyFile != "") {
+		errs = append(errs, fmt.Sprintf("%s and %s can only be used with %s '%s'", tlsCertFileFlagName,
+			tlsKeyFileFlagName, tlsModeFlagName, tlsmode.File))
+	}
+
+	return config.Invalid(errs)
+}
+
+// redacted returns the effective config with passwords in URLs redacted, one setting per line.
+func (c *consentConfig) redacted() string {
+	return config.Redacted(c.settings)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ory/hydra-client-go/client/admin"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	checkStatusOK           = "ok"
+	checkStatusFailed       = "failed"
+
+	hydraAdminCheck = "hydra-admin"
+)
+
+type healthStatus struct {
+	Status string                  `json:"status"`
+	Checks map[string]*checkResult `json:"checks,omitempty"`
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz reports that the process is up and serving HTTP.
+func (c *consentServer) healthz(w http.ResponseWriter, _ *http.Request) {
+	writeHealthStatus(w, http.StatusOK, &healthStatus{Status: healthStatusOK})
+}
+
+// readyz reports whether hydra admin API responds, login and consent requests can not be served without it.
+func (c *consentServer) readyz(w http.ResponseWriter, req *http.Request) {
+	status := &healthStatus{
+		Status: healthStatusOK,
+		Checks: map[string]*checkResult{hydraAdminCheck: {Status: checkStatusOK}},
+	}
+
+	code := http.StatusOK
+
+	params := admin.NewIsInstanceAliveParamsWithTimeout(timeout)
+	params.SetContext(req.Context())
+	params.SetHTTPClient(&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{ //nolint:gosec
+		InsecureSkipVerify: true,
+	}}})
+
+	_, err := c.hydraClient.Admin.IsInstanceAlive(params)
+	if err != nil {
+		c.metrics.hydraFailed(hydraIsInstanceAlive)
+
+		status.Status = healthStatusUnavailable
+		status.Checks[hydraAdminCheck] = &checkResult{
+			Status: checkStatusFailed,
+			Error:  fmt.Sprintf("hydra admin API is not available: %s", err.Error()),
+		}
+		code = http.StatusServiceUnavailable
+	}
+
+	writeHealthStatus(w, code, status)
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		fmt.Printf("failed to write health status: %s\n", err.Error())
+	}
+}
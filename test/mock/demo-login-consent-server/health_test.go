@@ -0,0 +1,65 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentServer_Health(t *testing.T) {
+	hydraAlive := true
+
+	hydra := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/health/alive", req.URL.Path)
+
+		if !hydraAlive {
+			res.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		_, err := res.Write([]byte(`{"status":"ok"}`))
+		require.NoError(t, err)
+	}))
+	defer hydra.Close()
+
+	server, err := newConsentServer(hydra.URL, false, []string{})
+	require.NoError(t, err)
+
+	serve := func(handler http.HandlerFunc, path string) (int, *healthStatus) {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		status := &healthStatus{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), status))
+
+		return rr.Code, status
+	}
+
+	code, status := serve(server.healthz, healthzPath)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, healthStatusOK, status.Status)
+
+	code, status = serve(server.readyz, readyzPath)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, healthStatusOK, status.Status)
+	require.Equal(t, checkStatusOK, status.Checks[hydraAdminCheck].Status)
+
+	hydraAlive = false
+
+	code, status = serve(server.readyz, readyzPath)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, healthStatusUnavailable, status.Status)
+	require.Equal(t, checkStatusFailed, status.Checks[hydraAdminCheck].Status)
+	require.NotEmpty(t, status.Checks[hydraAdminCheck].Error)
+}
@@ -15,7 +15,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,7 +23,9 @@ import (
 	"github.com/ory/hydra-client-go/client"
 	"github.com/ory/hydra-client-go/client/admin"
 	"github.com/ory/hydra-client-go/models"
+	"github.com/spf13/cobra"
 	tlsutils "github.com/trustbloc/edge-core/pkg/utils/tls"
+	"github.com/trustbloc/wallet/test/mock/common/tlsmode"
 )
 
 const (
@@ -31,6 +33,21 @@ const (
 	servePortEnvKey         = "SERVE_PORT"
 	tlsSystemCertPoolEnvKey = "TLS_SYSTEMCERTPOOL"
 	tlsCACertsEnvKey        = "TLS_CACERTS"
+	tlsModeEnvKey           = "TLS_MODE"
+	tlsCertFileEnvKey       = "TLS_CERT_FILE"
+	tlsKeyFileEnvKey        = "TLS_KEY_FILE"
+	tlsHostnamesEnvKey      = "TLS_HOSTNAMES"
+	tlsAutoDirEnvKey        = "TLS_AUTO_DIR"
+
+	adminURLFlagName          = "admin-url"
+	servePortFlagName         = "serve-port"
+	tlsSystemCertPoolFlagName = "tls-systemcertpool"
+	tlsCACertsFlagName        = "tls-cacerts"
+	tlsModeFlagName           = "tls-mode"
+	tlsCertFileFlagName       = "tls-cert-file"
+	tlsKeyFileFlagName        = "tls-key-file"
+	tlsHostnamesFlagName      = "tls-hostnames"
+	tlsAutoDirFlagName        = "tls-auto-dir"
 
 	loginHTML           = "./templates/login.html"
 	consentHTML         = "./templates/consent.html"
@@ -50,6 +67,8 @@ const (
 	loginTypeCookie = "loginType"
 
 	timeout = 10 * time.Second
+
+	autoTLSCAName = "mock-login-consent local CA"
 )
 
 type htmlTemplate interface {
@@ -57,53 +76,80 @@ type htmlTemplate interface {
 }
 
 func main() {
-	c, err := buildConsentServer()
+	err := newStartCmd().Execute()
 	if err != nil {
-		panic(err)
+		os.Exit(1)
 	}
+}
 
-	port := os.Getenv(servePortEnvKey)
-	if port == "" {
-		panic("port to be served not provided")
-	}
+func newStartCmd() *cobra.Command {
+	cfg := &consentConfig{}
+
+	cmd := &cobra.Command{
+		Use:   "mock-login-consent",
+		Short: "Demo hydra login and consent server",
+		Long: "Demo hydra login and consent server. Settings are read from command line flags, environment " +
+			"variables and the config file, in this order of precedence.",
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return cfg.load(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Printf("effective config:\n%s\n", cfg.redacted())
+
+			c, err := newConsentServer(cfg.AdminURL, cfg.TLSSystemCertPool, cfg.TLSCACerts)
+			if err != nil {
+				return err
+			}
 
-	// Hydra login and consent handlers
-	http.HandleFunc("/login", c.login)
-	http.HandleFunc("/consent", c.consent)
+			return c.serve(cfg)
+		},
+	}
 
-	http.Handle("/img/", http.FileServer(http.Dir("templates")))
-	http.Handle("/css/", http.FileServer(http.Dir("templates")))
+	cfg.addFlags(cmd)
 
-	fmt.Println(http.ListenAndServe(":"+port, nil))
+	return cmd
 }
 
-func buildConsentServer() (*consentServer, error) {
-	adminURL := os.Getenv(adminURLEnvKey)
-	if adminURL == "" {
-		return nil, fmt.Errorf("admin URL is required")
-	}
-
-	var tlsSystemCertPool bool
+func (c *consentServer) serve(cfg *consentConfig) error {
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
 
-	tlsSystemCertPoolVal := os.Getenv(tlsSystemCertPoolEnvKey)
-	if tlsSystemCertPoolVal != "" {
+	if cfg.TLSMode == tlsmode.Auto {
 		var err error
 
-		tlsSystemCertPool, err = strconv.ParseBool(tlsSystemCertPoolVal)
+		certFile, keyFile, err = tlsmode.GenerateAuto(cfg.TLSAutoDir, autoTLSCAName, cfg.TLSHostnames)
 		if err != nil {
-			return nil, fmt.Errorf("invalid value (%s) suppiled for `%s`, switching to default false",
-				tlsSystemCertPoolVal, tlsSystemCertPoolEnvKey)
+			return fmt.Errorf("failed to generate TLS certificate : %w", err)
 		}
+
+		fmt.Printf("generated TLS certificate %s, trust CA %s to connect\n", certFile,
+			filepath.Join(cfg.TLSAutoDir, tlsmode.CACertFile))
 	}
 
-	var tlsCACerts []string
+	// Hydra login and consent handlers
+	http.Handle("/login", c.metrics.instrument("/login", http.HandlerFunc(c.login)))
+	http.Handle("/consent", c.metrics.instrument("/consent", http.HandlerFunc(c.consent)))
 
-	tlsCACertsVal := os.Getenv(tlsCACertsEnvKey)
-	if tlsCACertsVal != "" {
-		tlsCACerts = strings.Split(tlsCACertsVal, ",")
-	}
+	http.Handle(metricsPath, c.metrics.handler())
+	http.HandleFunc(healthzPath, c.healthz)
+	http.HandleFunc(readyzPath, c.readyz)
+
+	http.Handle("/img/", http.FileServer(http.Dir("templates")))
+	http.Handle("/css/", http.FileServer(http.Dir("templates")))
+
+	return tlsmode.ListenAndServe(&http.Server{Addr: ":" + cfg.ServePort}, cfg.TLSMode, certFile, keyFile, //nolint:gosec
+		stdoutLogger{})
+}
+
+// stdoutLogger prints messages of shared server code the way the consent server prints its own.
+type stdoutLogger struct{}
+
+func (stdoutLogger) Infof(msg string, args ...interface{}) {
+	fmt.Printf(msg+"\n", args...)
+}
 
-	return newConsentServer(adminURL, tlsSystemCertPool, tlsCACerts)
+func (stdoutLogger) Errorf(msg string, args ...interface{}) {
+	fmt.Printf(msg+"\n", args...)
 }
 
 // newConsentServer returns new login consent server instance
@@ -159,6 +205,7 @@ func newConsentServer(adminURL string, tlsSystemCertPool bool, tlsCACerts []stri
 		dlUploadConsentTemplate: dlUploadConsentTemplate,
 		httpClient: &http.Client{Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}}},
+		metrics: newConsentMetrics(),
 	}, nil
 }
 
@@ -172,6 +219,7 @@ type consentServer struct {
 	dlUploadTemplate        htmlTemplate
 	dlUploadConsentTemplate htmlTemplate
 	httpClient              *http.Client
+	metrics                 *consentMetrics
 }
 
 func (c *consentServer) login(w http.ResponseWriter, req *http.Request) {
@@ -182,6 +230,7 @@ func (c *consentServer) login(w http.ResponseWriter, req *http.Request) {
 
 		resp, err := c.hydraClient.Admin.GetLoginRequest(loginReq)
 		if err != nil {
+			c.metrics.hydraFailed(hydraGetLoginRequest)
 			fmt.Fprintf(w, "Failed to fetch login request from hydra: %s", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 
@@ -307,6 +356,7 @@ func (c *consentServer) acceptLoginRequest(w http.ResponseWriter, req *http.Requ
 
 	resp, err := c.hydraClient.Admin.GetLoginRequest(loginRqstParams)
 	if err != nil {
+		c.metrics.hydraFailed(hydraGetLoginRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -325,6 +375,7 @@ func (c *consentServer) acceptLoginRequest(w http.ResponseWriter, req *http.Requ
 
 	loginOKResponse, err := c.hydraClient.Admin.AcceptLoginRequest(loginOKRequest)
 	if err != nil {
+		c.metrics.hydraFailed(hydraAcceptLoginRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -342,6 +393,7 @@ func (c *consentServer) showConsentPage(w http.ResponseWriter, req *http.Request
 
 	consentRequest, err := c.hydraClient.Admin.GetConsentRequest(consentRqstParams)
 	if err != nil {
+		c.metrics.hydraFailed(hydraGetConsentRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -436,6 +488,7 @@ func (c *consentServer) acceptConsentRequest(w http.ResponseWriter, req *http.Re
 
 	getConsentRequestResponse, err := c.hydraClient.Admin.GetConsentRequest(getConsentRequest)
 	if err != nil {
+		c.metrics.hydraFailed(hydraGetConsentRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -459,6 +512,7 @@ func (c *consentServer) acceptConsentRequest(w http.ResponseWriter, req *http.Re
 
 	consentOKResponse, err := c.hydraClient.Admin.AcceptConsentRequest(consentOKRequest)
 	if err != nil {
+		c.metrics.hydraFailed(hydraAcceptConsentRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
@@ -482,6 +536,7 @@ func (c *consentServer) rejectConsentRequest(w http.ResponseWriter, req *http.Re
 
 	consentDenyResponse, err := c.hydraClient.Admin.RejectConsentRequest(consentDeniedRequest)
 	if err != nil {
+		c.metrics.hydraFailed(hydraRejectConsentRequest)
 		fmt.Fprint(w, err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 
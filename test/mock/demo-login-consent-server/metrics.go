@@ -0,0 +1,114 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "consent_server"
+	metricsPath      = "/metrics"
+
+	// hydra admin API operations.
+	hydraGetLoginRequest      = "get_login_request"
+	hydraAcceptLoginRequest   = "accept_login_request"
+	hydraGetConsentRequest    = "get_consent_request"
+	hydraAcceptConsentRequest = "accept_consent_request"
+	hydraRejectConsentRequest = "reject_consent_request"
+	hydraIsInstanceAlive      = "is_instance_alive"
+)
+
+// consentMetrics are Prometheus metrics of the consent server, served from /metrics.
+type consentMetrics struct {
+	registry            *prometheus.Registry
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	hydraFailures       *prometheus.CounterVec
+}
+
+func newConsentMetrics() *consentMetrics {
+	m := &consentMetrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		hydraFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "hydra_admin_failures_total",
+			Help:      "Failed calls to hydra admin API, by operation.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.hydraFailures,
+	)
+
+	return m
+}
+
+// handler serves the metrics in Prometheus text format.
+func (m *consentMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// instrument counts requests of the route and observes their latency.
+func (m *consentMetrics) instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, req)
+
+		m.httpRequests.WithLabelValues(route, req.Method, strconv.Itoa(recorder.status)).Inc()
+		m.httpRequestDuration.WithLabelValues(route, req.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *consentMetrics) hydraFailed(operation string) {
+	m.hydraFailures.WithLabelValues(operation).Inc()
+}
+
+// statusRecorder captures status of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+
+	return r.ResponseWriter.Write(b)
+}
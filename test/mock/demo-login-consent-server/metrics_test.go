@@ -0,0 +1,35 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentMetrics(t *testing.T) {
+	metrics := newConsentMetrics()
+
+	handler := metrics.instrument("/consent", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/consent", nil))
+	metrics.hydraFailed(hydraAcceptConsentRequest)
+
+	rr := httptest.NewRecorder()
+	metrics.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	require.Contains(t, body, `consent_server_http_requests_total{method="PUT",route="/consent",status="405"} 1`)
+	require.Contains(t, body, `consent_server_http_request_duration_seconds_count{method="PUT",route="/consent"} 1`)
+	require.Contains(t, body, `consent_server_hydra_admin_failures_total{operation="accept_consent_request"} 1`)
+}